@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+)
+
+// Tool policies decide whether a tool call runs without asking the user
+const (
+	policyAllow = "allow"
+	policyAsk   = "ask"
+	policyDeny  = "deny"
+
+	// defaultToolPolicy applies to tools no policy is configured for
+	defaultToolPolicy = policyAsk
+)
+
+var (
+	// policyConfig holds the tool policies of the loaded config
+	policyConfig *MCPConfig
+
+	// alwaysAllowed holds the namespaced names of the tools the user
+	// allowed for the rest of the run
+	alwaysAllowed = make(map[string]bool)
+)
+
+// validateToolPolicies checks that all configured tool policies and
+// patterns are valid
+func (c *MCPConfig) validateToolPolicies() error {
+	if err := validatePolicy(c.ToolPolicy); err != nil {
+		return fmt.Errorf("toolPolicy: %w", err)
+	}
+
+	for name, server := range c.MCPServers {
+		if err := validatePolicy(server.ToolPolicy); err != nil {
+			return fmt.Errorf("server %s: toolPolicy: %w", name, err)
+		}
+		for pattern, policy := range server.ToolPolicies {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("server %s: invalid tool pattern %q: %w", name, pattern, err)
+			}
+			if err := validatePolicy(policy); err != nil {
+				return fmt.Errorf("server %s: toolPolicies[%q]: %w", name, pattern, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func validatePolicy(policy string) error {
+	switch policy {
+	case "", policyAllow, policyAsk, policyDeny:
+		return nil
+	default:
+		return fmt.Errorf("invalid policy %q, expected allow, ask or deny", policy)
+	}
+}
+
+// toolPolicy returns the policy for a tool. An exact tool name in the
+// server's toolPolicies wins over glob patterns, of which the longest
+// matching one wins. Otherwise the server's and then the global toolPolicy
+// apply.
+func toolPolicy(config *MCPConfig, serverName, toolName string) string {
+	if config == nil {
+		return defaultToolPolicy
+	}
+
+	server := config.MCPServers[serverName]
+	if policy, ok := server.ToolPolicies[toolName]; ok {
+		return policy
+	}
+
+	var matched, policy string
+	for pattern, p := range server.ToolPolicies {
+		if ok, _ := path.Match(pattern, toolName); ok && len(pattern) > len(matched) {
+			matched, policy = pattern, p
+		}
+	}
+	if matched != "" {
+		return policy
+	}
+
+	if server.ToolPolicy != "" {
+		return server.ToolPolicy
+	}
+	if config.ToolPolicy != "" {
+		return config.ToolPolicy
+	}
+	return defaultToolPolicy
+}
+
+// approveToolCall applies the tool policy to a call, asking the user if
+// needed. If the call is not approved, the reason is returned so it can be
+// sent back to the model.
+func approveToolCall(serverName, toolName string, args map[string]interface{}) (bool, string) {
+	namespacedName := fmt.Sprintf("%s__%s", serverName, toolName)
+
+	switch toolPolicy(policyConfig, serverName, toolName) {
+	case policyAllow:
+		return true, ""
+	case policyDeny:
+		return false, fmt.Sprintf("Tool call %s was denied by policy", namespacedName)
+	}
+
+	if alwaysAllowed[namespacedName] {
+		return true, ""
+	}
+
+	if nonInteractive {
+		return false, fmt.Sprintf(
+			"Tool call %s requires approval, which is not possible in non-interactive mode",
+			namespacedName,
+		)
+	}
+
+	choice, err := askToolApproval(namespacedName, args)
+	if errors.Is(err, huh.ErrUserAborted) {
+		// Ctrl+C in the form stops the turn like it does elsewhere
+		interruptTurn()
+		return false, fmt.Sprintf("Tool call %s was cancelled by the user", namespacedName)
+	}
+	if err != nil {
+		return false, fmt.Sprintf("Tool call %s was not approved: %v", namespacedName, err)
+	}
+
+	switch choice {
+	case "always":
+		alwaysAllowed[namespacedName] = true
+		return true, ""
+	case "approve":
+		return true, ""
+	default:
+		return false, fmt.Sprintf("Tool call %s was denied by the user", namespacedName)
+	}
+}
+
+// askToolApproval shows a tool call and asks whether to run it
+func askToolApproval(namespacedName string, args map[string]interface{}) (string, error) {
+	prettyArgs, err := json.MarshalIndent(args, "", "  ")
+	if err != nil {
+		prettyArgs = []byte(fmt.Sprintf("%v", args))
+	}
+
+	var markdown strings.Builder
+	markdown.WriteString(fmt.Sprintf("**Tool call:** `%s`\n\n", namespacedName))
+	markdown.WriteString("```json\n" + string(prettyArgs) + "\n```\n")
+
+	if err := updateRenderer(); err == nil {
+		if rendered, err := renderer.Render(markdown.String()); err == nil {
+			fmt.Print(rendered)
+		} else {
+			fmt.Print(markdown.String())
+		}
+	} else {
+		fmt.Print(markdown.String())
+	}
+
+	var choice string
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Title("Run this tool?").
+				Options(
+					huh.NewOption("Approve", "approve"),
+					huh.NewOption("Deny", "deny"),
+					huh.NewOption("Always allow "+namespacedName, "always"),
+				).
+				Value(&choice),
+		),
+	).WithWidth(getTerminalWidth()).WithTheme(huh.ThemeCharm())
+
+	if err := form.Run(); err != nil {
+		return "", err
+	}
+	return choice, nil
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToolPolicy(t *testing.T) {
+	config := &MCPConfig{
+		ToolPolicy: policyDeny,
+		MCPServers: map[string]ServerConfig{
+			"fs": {
+				ToolPolicy: policyAllow,
+				ToolPolicies: map[string]string{
+					"write_file": policyAsk,
+					"write_*":    policyDeny,
+					"*":          policyAsk,
+					"read_*":     policyAllow,
+					"read_m*":    policyDeny,
+				},
+			},
+			"git": {ToolPolicy: policyAsk},
+			"web": {},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		config *MCPConfig
+		server string
+		tool   string
+		want   string
+	}{
+		{"tool name over patterns", config, "fs", "write_file", policyAsk},
+		{"pattern", config, "fs", "write_dir", policyDeny},
+		{"longest pattern", config, "fs", "read_many", policyDeny},
+		{"shorter pattern", config, "fs", "read_file", policyAllow},
+		{"catch-all pattern over server policy", config, "fs", "list", policyAsk},
+		{"server policy over global policy", config, "git", "status", policyAsk},
+		{"global policy", config, "web", "fetch", policyDeny},
+		{"unknown server", config, "other", "fetch", policyDeny},
+		{"no global policy", &MCPConfig{MCPServers: map[string]ServerConfig{"web": {}}}, "web", "fetch", defaultToolPolicy},
+		{"no config", nil, "web", "fetch", defaultToolPolicy},
+	}
+
+	for _, tt := range tests {
+		if got := toolPolicy(tt.config, tt.server, tt.tool); got != tt.want {
+			t.Errorf("%s: toolPolicy(%s, %s) = %q, want %q", tt.name, tt.server, tt.tool, got, tt.want)
+		}
+	}
+}
+
+func TestValidateToolPolicies(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  MCPConfig
+		wantErr string
+	}{
+		{
+			name: "valid",
+			config: MCPConfig{
+				ToolPolicy: policyAsk,
+				MCPServers: map[string]ServerConfig{
+					"fs": {ToolPolicy: policyAllow, ToolPolicies: map[string]string{"write_*": policyDeny, "read_[a-z]?": policyAllow}},
+				},
+			},
+		},
+		{
+			name:    "invalid global policy",
+			config:  MCPConfig{ToolPolicy: "yes"},
+			wantErr: `toolPolicy: invalid policy "yes"`,
+		},
+		{
+			name:    "invalid server policy",
+			config:  MCPConfig{MCPServers: map[string]ServerConfig{"fs": {ToolPolicy: "Allow"}}},
+			wantErr: `server fs: toolPolicy: invalid policy "Allow"`,
+		},
+		{
+			name:    "invalid tool policy",
+			config:  MCPConfig{MCPServers: map[string]ServerConfig{"fs": {ToolPolicies: map[string]string{"write_*": "never"}}}},
+			wantErr: `server fs: toolPolicies["write_*"]: invalid policy "never"`,
+		},
+		{
+			name:    "invalid pattern",
+			config:  MCPConfig{MCPServers: map[string]ServerConfig{"fs": {ToolPolicies: map[string]string{"write_[": policyDeny}}}},
+			wantErr: `server fs: invalid tool pattern "write_["`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.validateToolPolicies()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApproveToolCallNonInteractive(t *testing.T) {
+	savedConfig, savedAllowed, savedNonInteractive := policyConfig, alwaysAllowed, nonInteractive
+	defer func() { policyConfig, alwaysAllowed, nonInteractive = savedConfig, savedAllowed, savedNonInteractive }()
+
+	policyConfig = &MCPConfig{MCPServers: map[string]ServerConfig{
+		"fs": {ToolPolicies: map[string]string{"read_*": policyAllow, "delete_*": policyDeny}},
+	}}
+	alwaysAllowed = map[string]bool{"fs__list": true}
+	nonInteractive = true
+
+	tests := []struct {
+		tool       string
+		want       bool
+		wantReason string
+	}{
+		{"read_file", true, ""},
+		{"delete_file", false, "Tool call fs__delete_file was denied by policy"},
+		// Nobody can be asked, so asking denies the call
+		{"write_file", false, "Tool call fs__write_file requires approval, which is not possible in non-interactive mode"},
+		{"list", true, ""},
+	}
+	for _, tt := range tests {
+		approved, reason := approveToolCall("fs", tt.tool, nil)
+		if approved != tt.want || reason != tt.wantReason {
+			t.Errorf("approveToolCall(%s) = %v, %q, want %v, %q", tt.tool, approved, reason, tt.want, tt.wantReason)
+		}
+	}
+}
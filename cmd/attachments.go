@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/vincent-pli/mcphost/pkg/history"
+	"github.com/vincent-pli/mcphost/pkg/llm"
+)
+
+// maxAttachmentSize is the largest file that can be attached
+const maxAttachmentSize = 10 * 1024 * 1024
+
+// imageTypes are the image formats all vision models accept
+var imageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+var (
+	// pendingAttachments are attached with /attach and sent with the next
+	// prompt
+	pendingAttachments []history.ContentBlock
+
+	// attachmentPattern matches @path and @"path with spaces" in a prompt
+	attachmentPattern = regexp.MustCompile(`(?:^|\s)@(?:"([^"]+)"|(\S+))`)
+
+	attachmentStyle = lipgloss.NewStyle().
+			Foreground(tokyoCyan).
+			PaddingLeft(2)
+)
+
+// readAttachment reads a file into an image or document block. Text files
+// become documents holding the text, PDFs documents holding the data.
+func readAttachment(path string) (history.ContentBlock, error) {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(homeDir, rest)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return history.ContentBlock{}, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	if info.IsDir() {
+		return history.ContentBlock{}, fmt.Errorf("cannot attach %s: it is a directory", path)
+	}
+	if info.Size() > maxAttachmentSize {
+		return history.ContentBlock{}, fmt.Errorf(
+			"cannot attach %s: file is larger than %d MB", path, maxAttachmentSize/1024/1024)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return history.ContentBlock{}, fmt.Errorf("error reading %s: %w", path, err)
+	}
+
+	name := filepath.Base(path)
+	mediaType, _, _ := strings.Cut(http.DetectContentType(data), ";")
+	switch {
+	case imageTypes[mediaType]:
+		return history.ContentBlock{
+			Type:      "image",
+			Name:      name,
+			MediaType: mediaType,
+			Data:      base64.StdEncoding.EncodeToString(data),
+		}, nil
+	case mediaType == "application/pdf":
+		return history.ContentBlock{
+			Type:      "document",
+			Name:      name,
+			MediaType: mediaType,
+			Data:      base64.StdEncoding.EncodeToString(data),
+		}, nil
+	case utf8.Valid(data) && !bytes.ContainsRune(data, 0):
+		return history.ContentBlock{
+			Type:      "document",
+			Name:      name,
+			MediaType: "text/plain",
+			Text:      string(data),
+		}, nil
+	default:
+		return history.ContentBlock{}, fmt.Errorf(
+			"cannot attach %s: unsupported file type %s", path, mediaType)
+	}
+}
+
+// checkAttachment returns an error if the model, given as provider:model,
+// cannot take the attached file
+func checkAttachment(provider llm.Provider, modelString string, block history.ContentBlock) error {
+	_, model, _ := strings.Cut(modelString, ":")
+	if block.Type == "image" && !llm.SupportsVision(model) {
+		return fmt.Errorf("cannot attach %s: model %s does not support images", block.Name, model)
+	}
+	if block.Type == "document" && block.Data != "" &&
+		(provider.Name() == "ollama" || !llm.SupportsVision(model)) {
+		return fmt.Errorf("cannot attach %s: model %s does not support %s files",
+			block.Name, model, block.MediaType)
+	}
+	return nil
+}
+
+// promptAttachments reads the files referenced with @path in a prompt.
+// References to files that do not exist are left as text, they may be
+// meant as mentions.
+func promptAttachments(provider llm.Provider, prompt string) ([]history.ContentBlock, error) {
+	var attachments []history.ContentBlock
+	for _, match := range attachmentPattern.FindAllStringSubmatch(prompt, -1) {
+		path := match[1]
+		if path == "" {
+			// Punctuation after an unquoted path is not part of it
+			path = match[2]
+			if _, err := os.Stat(path); err != nil {
+				path = strings.TrimRight(path, ".,;:!?)")
+			}
+		}
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+
+		block, err := readAttachment(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkAttachment(provider, modelFlag, block); err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, block)
+	}
+	return attachments, nil
+}
+
+// handleAttachCommand attaches a file to the next prompt, or lists the
+// pending attachments
+func handleAttachCommand(args []string, provider llm.Provider) {
+	if len(args) == 0 {
+		if len(pendingAttachments) == 0 {
+			fmt.Printf("\n%s\n\n", responseStyle.Render("No files attached. Usage: /attach <path>"))
+			return
+		}
+		fmt.Printf("\n%s\n\n", attachmentStyle.Render(
+			"Attached to the next prompt: "+attachmentNames(pendingAttachments),
+		))
+		return
+	}
+
+	block, err := readAttachment(strings.Join(args, " "))
+	if err == nil {
+		err = checkAttachment(provider, modelFlag, block)
+	}
+	if err != nil {
+		fmt.Printf("\n%s\n\n", errorStyle.Render(err.Error()))
+		return
+	}
+
+	pendingAttachments = append(pendingAttachments, block)
+	fmt.Printf("\n%s\n\n", attachmentStyle.Render(
+		fmt.Sprintf("📎 Attached %s (%s), it will be sent with the next prompt", block.Name, block.MediaType),
+	))
+}
+
+// attachmentNames lists the file names of attachments
+func attachmentNames(attachments []history.ContentBlock) string {
+	names := make([]string, len(attachments))
+	for i, block := range attachments {
+		names[i] = block.Name
+	}
+	return strings.Join(names, ", ")
+}
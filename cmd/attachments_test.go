@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vincent-pli/mcphost/pkg/llm"
+)
+
+// namedProvider is a provider of which only the name is used
+type namedProvider struct {
+	llm.Provider
+	name string
+}
+
+func (p namedProvider) Name() string { return p.name }
+
+func TestPromptAttachments(t *testing.T) {
+	saved := modelFlag
+	defer func() { modelFlag = saved }()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"notes.md":            "# Notes",
+		"with space/todo.txt": "buy milk",
+		"chart.png":           "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR",
+		"report.pdf":          "%PDF-1.4\n",
+		"data.bin":            "\x00\x01\x02\xff",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	tests := []struct {
+		name     string
+		provider string
+		model    string
+		prompt   string
+		want     string
+		wantErr  string
+	}{
+		{
+			name:   "text file",
+			model:  "openai:gpt-4o",
+			prompt: "Summarize @" + path("notes.md"),
+			want:   "document:text/plain:notes.md",
+		},
+		{
+			name:   "quoted path and trailing punctuation",
+			model:  "openai:gpt-4o",
+			prompt: `Compare @"` + path("with space/todo.txt") + `" with @` + path("notes.md") + ".",
+			want:   "document:text/plain:todo.txt document:text/plain:notes.md",
+		},
+		{
+			name:   "mentions",
+			model:  "openai:gpt-4o",
+			prompt: "Ask @alice about " + path("notes.md") + " or mail a@b.c",
+			want:   "",
+		},
+		{
+			name:   "image and pdf",
+			model:  "anthropic:claude-3-5-sonnet-latest",
+			prompt: "@" + path("chart.png") + " @" + path("report.pdf"),
+			want:   "image:image/png:chart.png document:application/pdf:report.pdf",
+		},
+		{
+			name:    "image for a text model",
+			model:   "ollama:qwen2.5:3b",
+			prompt:  "@" + path("chart.png"),
+			wantErr: "does not support images",
+		},
+		{
+			name:     "pdf for ollama",
+			provider: "ollama",
+			model:    "ollama:llava",
+			prompt:   "@" + path("report.pdf"),
+			wantErr:  "does not support application/pdf files",
+		},
+		{
+			name:    "binary file",
+			model:   "openai:gpt-4o",
+			prompt:  "@" + path("data.bin"),
+			wantErr: "unsupported file type",
+		},
+	}
+	for _, tt := range tests {
+		modelFlag = tt.model
+		provider, _, _ := strings.Cut(tt.model, ":")
+		if tt.provider != "" {
+			provider = tt.provider
+		}
+
+		attachments, err := promptAttachments(namedProvider{name: provider}, tt.prompt)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		var got []string
+		for _, block := range attachments {
+			got = append(got, block.Type+":"+block.MediaType+":"+block.Name)
+		}
+		if strings.Join(got, " ") != tt.want {
+			t.Errorf("%s: attachments = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
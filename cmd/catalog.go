@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/vincent-pli/mcphost/pkg/llm"
+)
+
+// toolCatalog holds the tools offered to the model. Servers can change
+// their tools while mcphost runs, so the catalog is refreshed when they
+// notify mcphost, and every turn uses the tools of the moment it starts.
+type toolCatalog struct {
+	// loadMu keeps refreshes of the same list from overtaking each other
+	loadMu sync.Mutex
+
+	mu          sync.Mutex
+	clients     map[string]mcpclient.MCPClient
+	serverTools map[string][]llm.Tool
+	// hostTools are the tools mcphost provides itself
+	hostTools []llm.Tool
+	// readOnly holds the namespaced names of the tools that are
+	// annotated as read-only
+	readOnly map[string]bool
+}
+
+var catalog = &toolCatalog{
+	serverTools: make(map[string][]llm.Tool),
+	readOnly:    make(map[string]bool),
+}
+
+// setClients sets the clients whose tools the catalog holds
+func (c *toolCatalog) setClients(clients map[string]mcpclient.MCPClient) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clients = clients
+}
+
+func (c *toolCatalog) client(serverName string) (mcpclient.MCPClient, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	client, ok := c.clients[serverName]
+	return client, ok
+}
+
+// loadServerTools lists the tools of a server and replaces the ones the
+// catalog held for it. It returns the number of tools.
+func (c *toolCatalog) loadServerTools(serverName string) (int, error) {
+	client, ok := c.client(serverName)
+	if !ok {
+		return 0, fmt.Errorf("server not found: %s", serverName)
+	}
+
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	toolsResult, err := client.ListTools(ctx, mcp.ListToolsRequest{})
+	cancel()
+	if err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, tool := range c.serverTools[serverName] {
+		delete(c.readOnly, tool.Name)
+	}
+	c.serverTools[serverName] = mcpToolsToAnthropicTools(serverName, toolsResult.Tools)
+	for _, tool := range toolsResult.Tools {
+		hint := tool.Annotations.ReadOnlyHint
+		c.readOnly[fmt.Sprintf("%s__%s", serverName, tool.Name)] = hint != nil && *hint
+	}
+	return len(toolsResult.Tools), nil
+}
+
+// loadResourceTool creates the read_resource tool for the resources the
+// servers offer at the moment, and reports whether there are any
+func (c *toolCatalog) loadResourceTool() bool {
+	c.mu.Lock()
+	clients := c.clients
+	c.mu.Unlock()
+
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+
+	tool, ok := resourceTool(clients)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hostTools = nil
+	if ok {
+		// Reading a resource has no side effects
+		c.hostTools = append(c.hostTools, tool)
+		c.readOnly[readResourceTool] = true
+	}
+	return ok
+}
+
+// tools returns the tools of all servers, ordered by server so that the
+// tools sent to the model only change when a server changes them
+func (c *toolCatalog) tools() []llm.Tool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	servers := make([]string, 0, len(c.serverTools))
+	for serverName := range c.serverTools {
+		servers = append(servers, serverName)
+	}
+	sort.Strings(servers)
+
+	var tools []llm.Tool
+	for _, serverName := range servers {
+		tools = append(tools, c.serverTools[serverName]...)
+	}
+	return append(tools, c.hostTools...)
+}
+
+// isReadOnly reports whether a tool is annotated as read-only
+func (c *toolCatalog) isReadOnly(namespacedName string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.readOnly[namespacedName]
+}
+
+// handleServerNotification handles a notification of a server by its
+// method. It runs on the reader of the server's connection, so requests
+// to the server are sent from another goroutine.
+func handleServerNotification(serverName string, notification mcp.JSONRPCNotification) {
+	switch notification.Method {
+	case "notifications/message":
+		// https://modelcontextprotocol.io/specification/2025-03-26/server/utilities/logging
+		message := notification.Notification.Params.AdditionalFields
+		log.Info("📩 from server",
+			"name", serverName,
+			"logger", message["logger"],
+			"level", message["level"],
+			"message", message["data"],
+		)
+	case mcp.MethodNotificationToolsListChanged:
+		go func() {
+			count, err := catalog.loadServerTools(serverName)
+			if err != nil {
+				log.Error("Error refreshing tools", "server", serverName, "error", err)
+				return
+			}
+			log.Info("Tools updated", "server", serverName, "count", count)
+		}()
+	case mcp.MethodNotificationResourcesListChanged:
+		if !resourceToolFlag {
+			return
+		}
+		go func() {
+			catalog.loadResourceTool()
+			log.Info("Resources updated", "server", serverName)
+		}()
+	case mcp.MethodNotificationPromptsListChanged:
+		go func() {
+			client, ok := catalog.client(serverName)
+			if !ok {
+				return
+			}
+			count, err := loadPrompts(serverName, client)
+			if err != nil {
+				log.Error("Error refreshing prompts", "server", serverName, "error", err)
+				return
+			}
+			log.Info("Prompts updated", "server", serverName, "count", count)
+		}()
+	default:
+		log.Debug("Notification from server", "name", serverName, "method", notification.Method)
+	}
+}
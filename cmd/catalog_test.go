@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/vincent-pli/mcphost/pkg/llm"
+)
+
+// waitFor polls condition until it holds, as notifications are handled in
+// the background
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func notification(method string) mcp.JSONRPCNotification {
+	return mcp.JSONRPCNotification{
+		JSONRPC:      mcp.JSONRPC_VERSION,
+		Notification: mcp.Notification{Method: method},
+	}
+}
+
+// useTestCatalog replaces the catalog with an empty one for the test
+func useTestCatalog(t *testing.T, clients map[string]mcpclient.MCPClient) {
+	t.Helper()
+	saved := catalog
+	catalog = &toolCatalog{
+		serverTools: make(map[string][]llm.Tool),
+		readOnly:    make(map[string]bool),
+	}
+	catalog.setClients(clients)
+	t.Cleanup(func() { catalog = saved })
+}
+
+func catalogToolNames() string {
+	var names []string
+	for _, tool := range catalog.tools() {
+		names = append(names, tool.Name)
+	}
+	return strings.Join(names, " ")
+}
+
+func TestToolsListChanged(t *testing.T) {
+	mcpServer := newTestMCPServer()
+	useTestCatalog(t, map[string]mcpclient.MCPClient{
+		"fs":  startInProcessClient(t, "fs", mcpServer),
+		"git": startInProcessClient(t, "git", newTestMCPServer()),
+	})
+	for _, name := range []string{"fs", "git"} {
+		if _, err := catalog.loadServerTools(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := catalogToolNames(); got != "fs__echo git__echo" {
+		t.Fatalf("tools = %s", got)
+	}
+
+	// The catalog keeps the tools it has until the server notifies it
+	mcpServer.AddTool(
+		mcp.NewTool("read", mcp.WithReadOnlyHintAnnotation(true)),
+		func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return mcp.NewToolResultText("contents"), nil
+		},
+	)
+	mcpServer.DeleteTools("echo")
+	if got := catalogToolNames(); got != "fs__echo git__echo" {
+		t.Errorf("tools = %s before the notification", got)
+	}
+
+	handleServerNotification("fs", notification(mcp.MethodNotificationToolsListChanged))
+	waitFor(t, "the tools of fs to be refreshed", func() bool {
+		return catalogToolNames() == "fs__read git__echo"
+	})
+	if !catalog.isReadOnly("fs__read") || catalog.isReadOnly("fs__echo") {
+		t.Errorf("read-only fs__read = %v, fs__echo = %v, want true, false",
+			catalog.isReadOnly("fs__read"), catalog.isReadOnly("fs__echo"))
+	}
+}
+
+func TestResourcesListChanged(t *testing.T) {
+	savedFlag := resourceToolFlag
+	defer func() { resourceToolFlag = savedFlag }()
+	resourceToolFlag = true
+
+	mcpServer := newResourceServer("docs", 1)
+	useTestCatalog(t, map[string]mcpclient.MCPClient{"docs": startInProcessClient(t, "docs", mcpServer)})
+	if !catalog.loadResourceTool() {
+		t.Fatal("no read_resource tool")
+	}
+
+	mcpServer.AddResource(
+		mcp.NewResource("file:///docs/new.txt", "new.txt"),
+		func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+			return nil, nil
+		},
+	)
+	handleServerNotification("docs", notification(mcp.MethodNotificationResourcesListChanged))
+	waitFor(t, "the new resource to be listed", func() bool {
+		tools := catalog.tools()
+		return len(tools) == 1 && strings.Contains(tools[0].Description, "file:///docs/new.txt")
+	})
+	if !catalog.isReadOnly(readResourceTool) {
+		t.Error("read_resource tool is not read-only")
+	}
+}
+
+func TestPromptsListChanged(t *testing.T) {
+	mcpServer := newPromptServer()
+	useTestCatalog(t, loadTestPrompts(t, mcpServer))
+
+	mcpServer.DeletePrompts("greet")
+	handleServerNotification("code", notification(mcp.MethodNotificationPromptsListChanged))
+	waitFor(t, "the prompts of code to be refreshed", func() bool {
+		_, _, ok := findServerPrompt("/code:greet")
+		return !ok
+	})
+	if _, _, ok := findServerPrompt("/code:review"); !ok {
+		t.Error("prompt review was removed")
+	}
+}
@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/vincent-pli/mcphost/pkg/history"
+	"github.com/vincent-pli/mcphost/pkg/llm"
+)
+
+// compactMode replaces messages that no longer fit the window with a
+// summary instead of dropping them
+var compactMode bool
+
+// maxTranscriptToolResult limits how much of a single tool result is
+// included in the transcript sent for summarization
+const maxTranscriptToolResult = 2000
+
+const summarizationPrompt = `Summarize the conversation below so that it can replace the original messages in a continuing conversation. Keep everything needed to carry on the work: the user's goals, decisions that were made and why, important facts, file names, commands and values, tool results that are still relevant, and open questions or next steps. Leave out greetings and anything that was superseded. Write the summary as plain notes, without addressing the user.
+
+<conversation>
+%s
+</conversation>`
+
+// summaryPrefix introduces the summary message in the history
+const summaryPrefix = "Summary of the earlier conversation:\n\n"
+
+// compactOrPrune brings the history back within the message window and
+// token budget. In compact mode older messages are summarized, otherwise
+// or if summarization fails they are dropped.
+func compactOrPrune(
+	ctx context.Context,
+	provider llm.Provider,
+	messages []history.HistoryMessage,
+) []history.HistoryMessage {
+	if len(messages) == 0 {
+		return messages
+	}
+
+	if compactMode && exceedsWindow(messages) {
+		compacted, err := compactMessages(ctx, provider, messages, compactionSplit(messages))
+		if err != nil {
+			log.Warn("Failed to compact conversation, dropping older messages",
+				"error", err)
+		} else {
+			messages = compacted
+		}
+	}
+
+	return pruneMessages(messages)
+}
+
+// exceedsWindow reports whether pruneMessages would drop messages
+func exceedsWindow(messages []history.HistoryMessage) bool {
+	if _, rest := splitSystemMessage(messages); len(rest) > messageWindow {
+		return true
+	}
+	return tokenBudget > 0 && estimateHistoryTokens(messages) > tokenBudget
+}
+
+func estimateHistoryTokens(messages []history.HistoryMessage) int {
+	total := 0
+	for i := range messages {
+		total += messages[i].EstimateTokens()
+	}
+	return total
+}
+
+// turnStarts returns the indexes of the user messages that start a turn,
+// i.e. messages with a prompt rather than tool results
+func turnStarts(messages []history.HistoryMessage) []int {
+	var starts []int
+	for i := range messages {
+		if messages[i].Role == "user" && !messages[i].Summary &&
+			!messages[i].IsToolResponse() {
+			starts = append(starts, i)
+		}
+	}
+	return starts
+}
+
+// compactionSplit returns the index of the first message that is kept as is
+// when compacting automatically. The most recent turns are kept as long as
+// they fit half of the message window and token budget, so that compaction
+// does not run again on the next prompt.
+func compactionSplit(messages []history.HistoryMessage) int {
+	starts := turnStarts(messages)
+	if len(starts) == 0 {
+		return len(messages)
+	}
+
+	split := starts[len(starts)-1]
+	for i := len(starts) - 2; i >= 0; i-- {
+		tail := messages[starts[i]:]
+		if len(tail) > messageWindow/2 ||
+			(tokenBudget > 0 && estimateHistoryTokens(tail) > tokenBudget/2) {
+			break
+		}
+		split = starts[i]
+	}
+	return split
+}
+
+// compactMessages summarizes messages[:split] with the provider and returns
+// the history with those messages replaced by a single summary message.
+// A leading system prompt is kept as it is.
+func compactMessages(
+	ctx context.Context,
+	provider llm.Provider,
+	messages []history.HistoryMessage,
+	split int,
+) ([]history.HistoryMessage, error) {
+	system, _ := splitSystemMessage(messages)
+	older := messages[len(system):max(split, len(system))]
+	if len(older) == 0 || (len(older) == 1 && older[0].Summary) {
+		return nil, fmt.Errorf("nothing to compact")
+	}
+
+	prompt := fmt.Sprintf(summarizationPrompt, formatTranscript(older))
+
+	var response llm.Message
+	var err error
+	runWithSpinner("Compacting conversation...", func() {
+		response, err = provider.CreateMessage(ctx, prompt, nil, nil)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error summarizing conversation: %w", err)
+	}
+
+	summary := strings.TrimSpace(response.GetContent())
+	if summary == "" {
+		return nil, fmt.Errorf("error summarizing conversation: empty summary")
+	}
+
+	log.Info("Conversation compacted",
+		"summarized", len(older),
+		"kept", len(messages)-split)
+
+	compacted := make([]history.HistoryMessage, 0, len(system)+len(messages)-split+1)
+	compacted = append(compacted, system...)
+	compacted = append(compacted, history.HistoryMessage{
+		Role: "user",
+		Content: []history.ContentBlock{{
+			Type: "text",
+			Text: summaryPrefix + summary,
+		}},
+		Summary: true,
+	})
+	return append(compacted, messages[split:]...), nil
+}
+
+// formatTranscript renders messages as plain text for summarization
+func formatTranscript(messages []history.HistoryMessage) string {
+	var transcript strings.Builder
+	for _, msg := range messages {
+		role := "User"
+		if msg.Role == "assistant" {
+			role = "Assistant"
+		}
+
+		for _, block := range msg.Content {
+			switch block.Type {
+			case "text":
+				fmt.Fprintf(&transcript, "%s: %s\n\n", role, block.Text)
+			case "image", "document":
+				fmt.Fprintf(&transcript, "%s attached file %s\n\n", role, block.Name)
+			case "tool_use":
+				fmt.Fprintf(&transcript, "%s called tool %s with %s\n\n",
+					role, block.Name, string(block.Input))
+			case "tool_result":
+				result := toolResultText(block)
+				if len(result) > maxTranscriptToolResult {
+					result = result[:maxTranscriptToolResult] + "... (truncated)"
+				}
+				label := "Tool result"
+				if block.IsError {
+					label = "Tool error"
+				}
+				fmt.Fprintf(&transcript, "%s: %s\n\n", label, result)
+			}
+		}
+	}
+	return strings.TrimSpace(transcript.String())
+}
+
+// toolResultText returns the text of a tool_result block
+func toolResultText(block history.ContentBlock) string {
+	if block.Text != "" {
+		return block.Text
+	}
+
+	switch content := block.Content.(type) {
+	case string:
+		return content
+	case []history.ContentBlock:
+		var texts []string
+		for _, b := range content {
+			if b.Type == "text" {
+				texts = append(texts, b.Text)
+			}
+		}
+		return strings.Join(texts, "\n")
+	case []mcp.Content:
+		var texts []string
+		for _, c := range content {
+			if text, ok := c.(mcp.TextContent); ok {
+				texts = append(texts, text.Text)
+			}
+		}
+		return strings.Join(texts, "\n")
+	case nil:
+		return ""
+	default:
+		data, _ := json.Marshal(content)
+		return string(data)
+	}
+}
+
+func handleCompactCommand(provider llm.Provider, messages *[]history.HistoryMessage) {
+	// Everything before the latest turn is summarized
+	starts := turnStarts(*messages)
+	split := 0
+	if len(starts) > 0 {
+		split = starts[len(starts)-1]
+	}
+
+	ctx, endTurn := beginTurn()
+	defer endTurn()
+
+	compacted, err := compactMessages(ctx, provider, *messages, split)
+	if err != nil {
+		fmt.Printf("\n%s\n\n", errorStyle.Render(fmt.Sprintf("Error compacting conversation: %v", err)))
+		return
+	}
+
+	fmt.Printf("\n%s\n\n", responseStyle.Render(
+		fmt.Sprintf("Compacted %d messages into a summary", len(*messages)-len(compacted)+1),
+	))
+	*messages = compacted
+}
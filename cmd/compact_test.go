@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/vincent-pli/mcphost/pkg/history"
+)
+
+func TestCompactionSplit(t *testing.T) {
+	savedWindow, savedBudget := messageWindow, tokenBudget
+	defer func() { messageWindow, tokenBudget = savedWindow, savedBudget }()
+
+	text := func(role, text string) history.HistoryMessage {
+		return history.HistoryMessage{Role: role, Content: []history.ContentBlock{{Type: "text", Text: text}}}
+	}
+	summary := text("user", "Summary of the earlier conversation")
+	summary.Summary = true
+
+	// The turns start at 2, 4 and 8
+	messages := []history.HistoryMessage{
+		text("system", "You are a helpful assistant."),
+		summary,
+		text("user", "What is in data.csv?"),
+		text("assistant", "Two columns."),
+		text("user", "Sum the first column."),
+		{Role: "assistant", Content: []history.ContentBlock{{Type: "tool_use", ID: "toolu_1", Name: "calc__sum"}}},
+		{Role: "user", Content: []history.ContentBlock{{Type: "tool_result", ToolUseID: "toolu_1", Content: "3"}}},
+		text("assistant", "The sum is 3."),
+		text("user", "And the second?"),
+		text("assistant", "Also 3."),
+	}
+
+	tests := []struct {
+		name     string
+		window   int
+		budget   int
+		messages []history.HistoryMessage
+		want     int
+	}{
+		{"all turns fit", 100, 0, messages, 2},
+		{"older turns over half the window", 12, 0, messages, 4},
+		{"only the last turn fits", 4, 0, messages, 8},
+		{"last turn over the window", 2, 0, messages, 8},
+		{"older turns over half the budget", 100, 2 * estimateHistoryTokens(messages[8:]), messages, 8},
+		{"no turn", 100, 0, messages[:2], 2},
+	}
+	for _, tt := range tests {
+		messageWindow, tokenBudget = tt.window, tt.budget
+		if got := compactionSplit(tt.messages); got != tt.want {
+			t.Errorf("%s: compactionSplit = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/vincent-pli/mcphost/pkg/llm"
+)
+
+var (
+	temperatureFlag float64
+	maxTokensFlag   int
+	topPFlag        float64
+	stopFlag        []string
+
+	thinkingBudgetFlag int
+
+	// flagOptions holds the options set with flags
+	flagOptions llm.GenerationOptions
+
+	// generationOptions are the options of the current model, resolved
+	// from the config and flags and changed with /set
+	generationOptions llm.GenerationOptions
+)
+
+// generationFlagOptions returns the options of the flags that were set
+func generationFlagOptions(flags *pflag.FlagSet) llm.GenerationOptions {
+	var options llm.GenerationOptions
+	if flags.Changed("temperature") {
+		options.Temperature = &temperatureFlag
+	}
+	if flags.Changed("max-tokens") {
+		options.MaxTokens = &maxTokensFlag
+	}
+	if flags.Changed("top-p") {
+		options.TopP = &topPFlag
+	}
+	if flags.Changed("stop") {
+		options.Stop = stopFlag
+	}
+	if flags.Changed("thinking-budget") {
+		options.ThinkingBudget = &thinkingBudgetFlag
+	}
+	return options
+}
+
+// resolveGenerationOptions returns the options for a model. Entries in the
+// config's models section apply to a provider, e.g. "ollama", or to a
+// model, e.g. "ollama:qwen2.5:3b". Flags take precedence over both.
+func resolveGenerationOptions(config *MCPConfig, modelString string) (llm.GenerationOptions, error) {
+	providerName, _, _ := strings.Cut(modelString, ":")
+	options := config.Models[providerName].
+		Merge(config.Models[modelString]).
+		Merge(flagOptions)
+
+	if err := validateGenerationOptions(options); err != nil {
+		return llm.GenerationOptions{}, err
+	}
+	return options, nil
+}
+
+// minThinkingBudget is the smallest thinking budget Anthropic accepts
+const minThinkingBudget = 1024
+
+func validateGenerationOptions(options llm.GenerationOptions) error {
+	if options.Temperature != nil && *options.Temperature < 0 {
+		return fmt.Errorf("invalid temperature %v, must not be negative", *options.Temperature)
+	}
+	if options.MaxTokens != nil && *options.MaxTokens <= 0 {
+		return fmt.Errorf("invalid max tokens %d, must be positive", *options.MaxTokens)
+	}
+	if options.TopP != nil && (*options.TopP <= 0 || *options.TopP > 1) {
+		return fmt.Errorf("invalid top-p %v, must be greater than 0 and at most 1", *options.TopP)
+	}
+	if options.ThinkingBudget != nil && *options.ThinkingBudget < minThinkingBudget {
+		return fmt.Errorf("invalid thinking budget %d, must be at least %d", *options.ThinkingBudget, minThinkingBudget)
+	}
+	return nil
+}
+
+// validateModels checks the generation options of the config
+func (c *MCPConfig) validateModels() error {
+	for name, options := range c.Models {
+		if err := validateGenerationOptions(options); err != nil {
+			return fmt.Errorf("models[%q]: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// applyGenerationOptions passes the current options to the provider
+func applyGenerationOptions(provider llm.Provider) {
+	if configurable, ok := provider.(llm.ConfigurableProvider); ok {
+		configurable.SetGenerationOptions(generationOptions)
+	}
+}
+
+// handleSetCommand shows or changes a generation option, e.g.
+// "/set temperature 0.2". The value "default" unsets an option.
+func handleSetCommand(args []string, provider llm.Provider) {
+	if len(args) == 0 {
+		showGenerationOptions()
+		return
+	}
+	if len(args) < 2 {
+		fmt.Printf("\n%s\n\n", errorStyle.Render("Usage: /set <temperature|max-tokens|top-p|stop|thinking-budget> <value|default>"))
+		return
+	}
+
+	name, value := strings.ToLower(args[0]), args[1]
+	options := generationOptions
+	reset := value == "default"
+
+	switch name {
+	case "temperature":
+		options.Temperature = nil
+		if !reset {
+			temperature, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				fmt.Printf("\n%s\n\n", errorStyle.Render(fmt.Sprintf("Invalid temperature: %s", value)))
+				return
+			}
+			options.Temperature = &temperature
+		}
+	case "max-tokens":
+		options.MaxTokens = nil
+		if !reset {
+			maxTokens, err := strconv.Atoi(value)
+			if err != nil {
+				fmt.Printf("\n%s\n\n", errorStyle.Render(fmt.Sprintf("Invalid max tokens: %s", value)))
+				return
+			}
+			options.MaxTokens = &maxTokens
+		}
+	case "top-p":
+		options.TopP = nil
+		if !reset {
+			topP, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				fmt.Printf("\n%s\n\n", errorStyle.Render(fmt.Sprintf("Invalid top-p: %s", value)))
+				return
+			}
+			options.TopP = &topP
+		}
+	case "stop":
+		// Every argument is a stop sequence
+		options.Stop = nil
+		if !reset {
+			options.Stop = args[1:]
+		}
+	case "thinking-budget":
+		options.ThinkingBudget = nil
+		if !reset {
+			budget, err := strconv.Atoi(value)
+			if err != nil {
+				fmt.Printf("\n%s\n\n", errorStyle.Render(fmt.Sprintf("Invalid thinking budget: %s", value)))
+				return
+			}
+			options.ThinkingBudget = &budget
+		}
+	default:
+		fmt.Printf("\n%s\n\n", errorStyle.Render(fmt.Sprintf(
+			"Unknown option: %s. Options are temperature, max-tokens, top-p, stop and thinking-budget", name)))
+		return
+	}
+
+	if err := validateGenerationOptions(options); err != nil {
+		fmt.Printf("\n%s\n\n", errorStyle.Render(err.Error()))
+		return
+	}
+
+	generationOptions = options
+	applyGenerationOptions(provider)
+	fmt.Printf("\n%s\n\n", responseStyle.Render(
+		fmt.Sprintf("Set %s to %s", name, formatOption(name, options)),
+	))
+}
+
+func showGenerationOptions() {
+	if err := updateRenderer(); err != nil {
+		fmt.Printf(
+			"\n%s\n",
+			errorStyle.Render(fmt.Sprintf("Error updating renderer: %v", err)),
+		)
+		return
+	}
+
+	var markdown strings.Builder
+	markdown.WriteString("# Generation Options\n\n")
+	markdown.WriteString("| Option | Value |\n")
+	markdown.WriteString("|--------|-------|\n")
+	for _, name := range []string{"temperature", "max-tokens", "top-p", "stop", "thinking-budget"} {
+		markdown.WriteString(fmt.Sprintf("| %s | %s |\n", name, formatOption(name, generationOptions)))
+	}
+
+	rendered, err := renderer.Render(markdown.String())
+	if err != nil {
+		fmt.Printf(
+			"\n%s\n",
+			errorStyle.Render(fmt.Sprintf("Error rendering options: %v", err)),
+		)
+		return
+	}
+
+	fmt.Print("\n" + rendered + "\n")
+}
+
+// formatOption returns the value of an option, or "default" if it is unset
+func formatOption(name string, options llm.GenerationOptions) string {
+	switch {
+	case name == "temperature" && options.Temperature != nil:
+		return strconv.FormatFloat(*options.Temperature, 'g', -1, 64)
+	case name == "max-tokens" && options.MaxTokens != nil:
+		return strconv.Itoa(*options.MaxTokens)
+	case name == "top-p" && options.TopP != nil:
+		return strconv.FormatFloat(*options.TopP, 'g', -1, 64)
+	case name == "stop" && options.Stop != nil:
+		quoted := make([]string, len(options.Stop))
+		for i, stop := range options.Stop {
+			quoted[i] = strconv.Quote(stop)
+		}
+		return strings.Join(quoted, ", ")
+	case name == "thinking-budget" && options.ThinkingBudget != nil:
+		return strconv.Itoa(*options.ThinkingBudget)
+	}
+	return "default"
+}
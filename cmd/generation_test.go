@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/vincent-pli/mcphost/pkg/llm"
+)
+
+func TestResolveGenerationOptions(t *testing.T) {
+	var config MCPConfig
+	if err := json.Unmarshal([]byte(`{"models": {
+		"ollama": {"temperature": 0.2, "maxTokens": 2048, "stop": ["END"]},
+		"ollama:qwen2.5:3b": {"temperature": 0, "topP": 0.9},
+		"anthropic": {"thinkingBudget": 2048}
+	}}`), &config); err != nil {
+		t.Fatal(err)
+	}
+
+	temperature, maxTokens, topP := 0.7, 512, 0.0
+	tests := []struct {
+		name    string
+		model   string
+		flags   llm.GenerationOptions
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "provider entry",
+			model: "ollama:llama3",
+			want:  `{"temperature":0.2,"maxTokens":2048,"stop":["END"]}`,
+		},
+		{
+			name:  "model entry over provider entry",
+			model: "ollama:qwen2.5:3b",
+			want:  `{"temperature":0,"maxTokens":2048,"topP":0.9,"stop":["END"]}`,
+		},
+		{
+			name:  "flags over entries",
+			model: "ollama:qwen2.5:3b",
+			flags: llm.GenerationOptions{Temperature: &temperature, MaxTokens: &maxTokens, Stop: []string{}},
+			want:  `{"temperature":0.7,"maxTokens":512,"topP":0.9}`,
+		},
+		{
+			name:  "no entry",
+			model: "openai:gpt-4o",
+			want:  `{}`,
+		},
+		{
+			name:  "other provider",
+			model: "anthropic:claude-3-5-sonnet-latest",
+			want:  `{"thinkingBudget":2048}`,
+		},
+		{
+			name:    "invalid flag",
+			model:   "openai:gpt-4o",
+			flags:   llm.GenerationOptions{TopP: &topP},
+			wantErr: "invalid top-p",
+		},
+	}
+
+	saved := flagOptions
+	defer func() { flagOptions = saved }()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flagOptions = tt.flags
+			options, err := resolveGenerationOptions(&config, tt.model)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := json.Marshal(options); string(got) != tt.want {
+				t.Errorf("options = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
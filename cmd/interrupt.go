@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+// errTurnInterrupted is returned by runPrompt when the user pressed Ctrl+C
+var errTurnInterrupted = errors.New("turn interrupted")
+
+var (
+	// cancelTurn cancels the running turn, it is nil between turns
+	cancelTurn   context.CancelFunc
+	cancelTurnMu sync.Mutex
+
+	// closeServers closes the MCP clients once they are created. The
+	// deferred close of runMCPHost does not run on os.Exit, so a second
+	// Ctrl+C calls it itself.
+	closeServers = func() {}
+)
+
+// serverShutdownTimeout is how long a second Ctrl+C waits for the MCP
+// servers to close before their processes are killed
+const serverShutdownTimeout = 2 * time.Second
+
+// beginTurn returns a context for one turn of the conversation. The first
+// Ctrl+C cancels it, a second Ctrl+C before the turn is over exits
+// mcphost. endTurn must be called once the turn is over.
+func beginTurn() (ctx context.Context, endTurn func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	cancelTurnMu.Lock()
+	cancelTurn = cancel
+	cancelTurnMu.Unlock()
+
+	interrupts := make(chan os.Signal, 2)
+	signal.Notify(interrupts, os.Interrupt)
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-interrupts:
+		case <-done:
+			return
+		}
+
+		cancel()
+		fmt.Fprintf(os.Stderr, "\n%s\n",
+			errorStyle.Render("Interrupted. Press Ctrl+C again to exit."))
+
+		select {
+		case <-interrupts:
+			fmt.Println("\nGoodbye!")
+			exitInterrupted()
+		case <-done:
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(interrupts)
+		close(done)
+		cancel()
+
+		cancelTurnMu.Lock()
+		cancelTurn = nil
+		cancelTurnMu.Unlock()
+	}
+}
+
+// exitInterrupted closes the MCP servers and exits. Stdio servers run in
+// their own process group, which Ctrl+C does not reach, so the ones that do
+// not close in time are killed rather than left running.
+func exitInterrupted() {
+	closed := make(chan struct{})
+	go func() {
+		closeServers()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(serverShutdownTimeout):
+		killServerProcesses()
+	}
+	os.Exit(130)
+}
+
+// interruptTurn cancels the running turn as if Ctrl+C was pressed. Forms
+// read Ctrl+C as a key press, so they call it when the user aborts them.
+func interruptTurn() {
+	cancelTurnMu.Lock()
+	defer cancelTurnMu.Unlock()
+	if cancelTurn != nil {
+		cancelTurn()
+	}
+}
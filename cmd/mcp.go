@@ -5,8 +5,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/charmbracelet/huh/spinner"
@@ -17,6 +20,7 @@ import (
 	"strings"
 
 	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/vincent-pli/mcphost/pkg/history"
 	"github.com/vincent-pli/mcphost/pkg/llm"
@@ -61,13 +65,54 @@ var (
 )
 
 type MCPConfig struct {
-	MCPServers map[string]ServerConfig `json:"mcpServers"`
+	MCPServers   map[string]ServerConfig `json:"mcpServers"`
+	SystemPrompt string                  `json:"systemPrompt,omitempty"`
+
+	// ToolPolicy is the policy for tools without a server or tool policy:
+	// "allow", "ask" (default) or "deny"
+	ToolPolicy string `json:"toolPolicy,omitempty"`
+
+	// Providers are profiles for OpenAI-compatible endpoints by name
+	Providers map[string]ProviderConfig `json:"providers,omitempty"`
+
+	// Models holds generation options by provider or provider:model
+	Models map[string]llm.GenerationOptions `json:"models,omitempty"`
+
+	// SamplingModel answers sampling requests of the servers, e.g. a
+	// cheaper model than the one of the conversation
+	SamplingModel string `json:"samplingModel,omitempty"`
+
+	// Roots are the directories the servers are told they may work in
+	Roots []string `json:"roots,omitempty"`
 }
 
+const (
+	transportStdio          = "stdio"
+	transportSSE            = "sse"
+	transportStreamableHTTP = "streamable-http"
+)
+
 type ServerConfig struct {
-	Command string            `json:"command"`
-	Args    []string          `json:"args"`
-	Env     map[string]string `json:"env,omitempty"`
+	// Transport is one of "stdio" (default), "sse" or "streamable-http"
+	Transport string            `json:"transport,omitempty"`
+	Command   string            `json:"command,omitempty"`
+	Args      []string          `json:"args,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+	URL       string            `json:"url,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+
+	// ToolPolicy applies to all tools of the server, ToolPolicies to the
+	// tools matching a name or glob pattern
+	ToolPolicy   string            `json:"toolPolicy,omitempty"`
+	ToolPolicies map[string]string `json:"toolPolicies,omitempty"`
+}
+
+// transport returns the configured transport, defaulting to stdio
+func (s ServerConfig) transport() string {
+	if s.Transport == "" {
+		return transportStdio
+	}
+	return s.Transport
 }
 
 func mcpToolsToAnthropicTools(
@@ -86,6 +131,7 @@ func mcpToolsToAnthropicTools(
 				Type:       tool.InputSchema.Type,
 				Properties: tool.InputSchema.Properties,
 				Required:   tool.InputSchema.Required,
+				Defs:       tool.InputSchema.Defs,
 			},
 		}
 	}
@@ -141,25 +187,166 @@ func loadMCPConfig() (*MCPConfig, error) {
 		return nil, fmt.Errorf("error parsing config file: %w", err)
 	}
 
+	if err := config.validateToolPolicies(); err != nil {
+		return nil, fmt.Errorf("error in config file: %w", err)
+	}
+	if err := config.validateProviders(); err != nil {
+		return nil, fmt.Errorf("error in config file: %w", err)
+	}
+	if err := config.validateModels(); err != nil {
+		return nil, fmt.Errorf("error in config file: %w", err)
+	}
+
 	return &config, nil
 }
 
-func createMCPClients(
-	config *MCPConfig,
-	debugMode bool,
-) (map[string]*mcpclient.StdioMCPClient, error) {
-	clients := make(map[string]*mcpclient.StdioMCPClient)
+var (
+	// serverCommands are the commands of the stdio servers, kept to kill
+	// servers that do not close when mcphost exits
+	serverCommands   []*exec.Cmd
+	serverCommandsMu sync.Mutex
+)
+
+// serverCommand creates the command for a stdio server. Servers run in
+// their own process group, so that Ctrl+C only interrupts the current turn
+// and does not stop them.
+func serverCommand(
+	ctx context.Context,
+	command string,
+	env []string,
+	args []string,
+) (*exec.Cmd, error) {
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Env = append(os.Environ(), env...)
+	detachProcessGroup(cmd)
+
+	serverCommandsMu.Lock()
+	serverCommands = append(serverCommands, cmd)
+	serverCommandsMu.Unlock()
+	return cmd, nil
+}
+
+// killServerProcesses kills the process groups of the stdio servers
+func killServerProcesses() {
+	serverCommandsMu.Lock()
+	defer serverCommandsMu.Unlock()
+	for _, cmd := range serverCommands {
+		killProcessGroup(cmd)
+	}
+}
+
+// closeMCPClients closes the clients, which stops stdio servers and ends
+// the sessions of HTTP servers
+func closeMCPClients(clients map[string]mcpclient.MCPClient) {
+	log.Info("Shutting down MCP servers...")
+	for name, client := range clients {
+		if err := client.Close(); err != nil {
+			log.Error("Failed to close server", "name", name, "error", err)
+		} else {
+			log.Info("Server closed", "name", name)
+		}
+	}
+}
+
+// newMCPClient creates a client for the transport configured for the server.
+// The options set handlers for requests of the server.
+func newMCPClient(server ServerConfig, options ...mcpclient.ClientOption) (*mcpclient.Client, error) {
+	switch server.transport() {
+	case transportStdio:
+		if server.Command == "" {
+			return nil, fmt.Errorf("command is required for the stdio transport")
+		}
 
-	for name, server := range config.MCPServers {
 		var env []string
 		for k, v := range server.Env {
 			env = append(env, fmt.Sprintf("%s=%s", k, v))
 		}
+		return mcpclient.NewClient(
+			samplingTransport{transport.NewStdioWithOptions(
+				server.Command,
+				env,
+				server.Args,
+				transport.WithCommandFunc(serverCommand),
+			)},
+			options...,
+		), nil
+
+	case transportSSE:
+		if server.URL == "" {
+			return nil, fmt.Errorf("url is required for the sse transport")
+		}
+
+		sse, err := transport.NewSSE(server.URL, transport.WithHeaders(server.Headers))
+		if err != nil {
+			return nil, err
+		}
+		// The SSE transport cannot receive requests of the server, so the
+		// client does not offer to handle them
+		return mcpclient.NewClient(sse), nil
+
+	case transportStreamableHTTP:
+		if server.URL == "" {
+			return nil, fmt.Errorf("url is required for the streamable-http transport")
+		}
+
+		// Continuous listening keeps a GET stream open, over which the
+		// server sends notifications and requests while no call is in
+		// flight. The transport stops listening if the server refuses it.
+		streamable, err := transport.NewStreamableHTTP(
+			server.URL,
+			transport.WithHTTPHeaders(server.Headers),
+			transport.WithContinuousListening(),
+			transport.WithHTTPLogger(transportLogger{}),
+		)
+		if err != nil {
+			return nil, err
+		}
+		return mcpclient.NewClient(samplingTransport{streamable}, options...), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported transport: %s", server.Transport)
+	}
+}
+
+// serverStderr returns the stderr of a stdio server
+func serverStderr(client *mcpclient.Client) (io.Reader, bool) {
+	if t, ok := client.GetTransport().(samplingTransport); ok {
+		if stdio, ok := t.BidirectionalInterface.(*transport.Stdio); ok {
+			return stdio.Stderr(), true
+		}
+	}
+	return mcpclient.GetStderr(client)
+}
+
+// transportLogger sends the messages of the streamable HTTP transport to the
+// debug log, as its listening stream reconnects in the background
+type transportLogger struct{}
+
+func (transportLogger) Infof(format string, v ...any) {
+	log.Debug(fmt.Sprintf(format, v...))
+}
+
+func (transportLogger) Errorf(format string, v ...any) {
+	log.Debug(fmt.Sprintf(format, v...))
+}
+
+func createMCPClients(
+	config *MCPConfig,
+	debugMode bool,
+) (map[string]mcpclient.MCPClient, error) {
+	clients := make(map[string]mcpclient.MCPClient)
 
-		client, err := mcpclient.NewStdioMCPClient(
-			server.Command,
-			env,
-			server.Args...)
+	for name, server := range config.MCPServers {
+		client, err := newMCPClient(
+			server,
+			mcpclient.WithSamplingHandler(&samplingHandler{serverName: name, config: config}),
+			mcpclient.WithRootsHandler(rootsHandler{}),
+		)
+		if err == nil {
+			// The transport outlives this function, so it is started
+			// without the initialization timeout
+			err = client.Start(context.Background())
+		}
 		if err != nil {
 			for _, c := range clients {
 				c.Close()
@@ -174,7 +361,7 @@ func createMCPClients(
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
-		log.Info("Initializing server...", "name", name)
+		log.Info("Initializing server...", "name", name, "transport", server.transport())
 		initRequest := mcp.InitializeRequest{}
 		initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
 		initRequest.Params.ClientInfo = mcp.Implementation{
@@ -183,7 +370,7 @@ func createMCPClients(
 		}
 		initRequest.Params.Capabilities = mcp.ClientCapabilities{}
 
-		_, err = client.Initialize(ctx, initRequest)
+		initResult, err := client.Initialize(ctx, initRequest)
 		if err != nil {
 			client.Close()
 			for _, c := range clients {
@@ -196,15 +383,10 @@ func createMCPClients(
 			)
 		}
 
+		serverCapabilities[name] = initResult.Capabilities
+
 		client.OnNotification(func(notification mcp.JSONRPCNotification) {
-			// https://modelcontextprotocol.io/specification/2025-03-26/server/utilities/logging
-			message := notification.Notification.Params.AdditionalFields
-			log.Info("📩 from server",
-				"name", name,
-				"logger", message["logger"],
-				"level", message["level"],
-				"message", message["data"],
-			)
+			handleServerNotification(name, notification)
 		})
 
 		request := mcp.SetLevelRequest{}
@@ -215,22 +397,24 @@ func createMCPClients(
 		}
 		client.SetLevel(ctx, request)
 
-		stderr := client.Stderr()
-		reader := bufio.NewReader(stderr)
-		go func() {
-			for {
-				line, err := reader.ReadString('\n')
-				if err != nil {
-					continue
+		// Only stdio servers have a stderr stream to forward
+		if stderr, ok := serverStderr(client); ok {
+			reader := bufio.NewReader(stderr)
+			go func() {
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						continue
 
+					}
+					line = strings.TrimSpace(line)
+					if line != "" {
+						log.Error("👻 from server", "name", name, "message", line)
+						log.Error("👻 please fix the server issue and restart mcphost")
+					}
 				}
-				line = strings.TrimSpace(line)
-				if line != "" {
-					log.Error("👻 from server", "name", name, "message", line)
-					log.Error("👻 please fix the server issue and restart mcphost")
-				}
-			}
-		}()
+			}()
+		}
 
 		clients[name] = client
 	}
@@ -240,15 +424,19 @@ func createMCPClients(
 
 func handleSlashCommand(
 	prompt string,
+	provider llm.Provider,
 	mcpConfig *MCPConfig,
-	mcpClients map[string]*mcpclient.StdioMCPClient,
-	messages interface{},
+	mcpClients map[string]mcpclient.MCPClient,
+	messages *[]history.HistoryMessage,
 ) (bool, error) {
 	if !strings.HasPrefix(prompt, "/") {
 		return false, nil
 	}
 
-	switch strings.ToLower(strings.TrimSpace(prompt)) {
+	fields := strings.Fields(prompt)
+	args := fields[1:]
+
+	switch strings.ToLower(fields[0]) {
 	case "/tools":
 		handleToolsCommand(mcpClients)
 		return true, nil
@@ -256,7 +444,37 @@ func handleSlashCommand(
 		handleHelpCommand()
 		return true, nil
 	case "/history":
-		handleHistoryCommand(messages.([]history.HistoryMessage))
+		handleHistoryCommand(*messages)
+		return true, nil
+	case "/save":
+		handleSaveCommand(args, *messages)
+		return true, nil
+	case "/load":
+		handleLoadCommand(args, messages)
+		return true, nil
+	case "/compact":
+		handleCompactCommand(provider, messages)
+		return true, nil
+	case "/set":
+		handleSetCommand(args, provider)
+		return true, nil
+	case "/thinking":
+		handleThinkingCommand()
+		return true, nil
+	case "/attach":
+		handleAttachCommand(args, provider)
+		return true, nil
+	case "/roots":
+		handleRootsCommand(args, mcpConfig, mcpClients)
+		return true, nil
+	case "/resources":
+		handleResourcesCommand(mcpClients)
+		return true, nil
+	case "/resource":
+		handleResourceCommand(args, provider, mcpClients)
+		return true, nil
+	case "/sessions":
+		handleSessionsCommand()
 		return true, nil
 	case "/servers":
 		handleServersCommand(mcpConfig)
@@ -288,8 +506,24 @@ func handleHelpCommand() {
 	markdown.WriteString("- **/tools**: List all available tools\n")
 	markdown.WriteString("- **/servers**: List configured MCP servers\n")
 	markdown.WriteString("- **/history**: Display conversation history\n")
+	markdown.WriteString("- **/save [name]**: Save the conversation as a session\n")
+	markdown.WriteString("- **/load <name>**: Replace the conversation with a saved session\n")
+	markdown.WriteString("- **/sessions**: List saved sessions\n")
+	markdown.WriteString("- **/compact**: Summarize the conversation before the latest exchange\n")
+	markdown.WriteString("- **/set [option value]**: Show or change temperature, max-tokens, top-p, stop or thinking-budget\n")
+	markdown.WriteString("- **/thinking**: Show the model's thinking in the last response\n")
+	markdown.WriteString("- **/attach [path]**: Attach an image, PDF or text file to the next prompt, or list attached files\n")
+	markdown.WriteString("- **/roots [add|remove <path>]**: List the directories shared with the servers, or add or remove one\n")
+	markdown.WriteString("- **/resources**: List the resources of all servers\n")
+	markdown.WriteString("- **/resource [server] <uri>**: Attach a server resource to the next prompt\n")
 	markdown.WriteString("- **/quit**: Exit the application\n")
-	markdown.WriteString("\nYou can also press Ctrl+C at any time to quit.\n")
+	markdown.WriteString("\nPress Ctrl+C to stop the current response, or at the prompt to quit.\n")
+
+	if commands := promptCommands(); len(commands) > 0 {
+		markdown.WriteString("\n## Server Prompts\n\n")
+		markdown.WriteString("Prompts of the servers send their messages to the model, missing arguments are asked for:\n\n")
+		markdown.WriteString(strings.Join(commands, "\n") + "\n")
+	}
 
 	markdown.WriteString("\n## Available Models\n\n")
 	markdown.WriteString("Specify models using the --model or -m flag:\n\n")
@@ -331,6 +565,16 @@ func handleServersCommand(config *MCPConfig) {
 		} else {
 			for name, server := range config.MCPServers {
 				markdown.WriteString(fmt.Sprintf("# %s\n\n", name))
+				markdown.WriteString("*Transport*\n")
+				markdown.WriteString(fmt.Sprintf("`%s`\n\n", server.transport()))
+
+				if server.transport() != transportStdio {
+					markdown.WriteString("*URL*\n")
+					markdown.WriteString(fmt.Sprintf("`%s`\n", server.URL))
+					markdown.WriteString("\n") // Add spacing between servers
+					continue
+				}
+
 				markdown.WriteString("*Command*\n")
 				markdown.WriteString(fmt.Sprintf("`%s`\n\n", server.Command))
 
@@ -368,7 +612,7 @@ func handleServersCommand(config *MCPConfig) {
 	fmt.Print("\n" + containerStyle.Render(rendered) + "\n")
 }
 
-func handleToolsCommand(mcpClients map[string]*mcpclient.StdioMCPClient) {
+func handleToolsCommand(mcpClients map[string]mcpclient.MCPClient) {
 	// Get terminal width for proper wrapping
 	width := getTerminalWidth()
 
@@ -393,6 +637,11 @@ func handleToolsCommand(mcpClients map[string]*mcpclient.StdioMCPClient) {
 
 	action := func() {
 		for serverName, mcpClient := range mcpClients {
+			if serverCapabilities[serverName].Tools == nil {
+				results[serverName] = serverTools{}
+				continue
+			}
+
 			ctx, cancel := context.WithTimeout(
 				context.Background(),
 				10*time.Second,
@@ -494,7 +743,9 @@ func displayMessageHistory(messages []history.HistoryMessage) {
 
 	for _, msg := range messages {
 		roleTitle := "## User"
-		if msg.Role == "assistant" {
+		if msg.Summary {
+			roleTitle = "## Summary"
+		} else if msg.Role == "assistant" {
 			roleTitle = "## Assistant"
 		} else if msg.Role == "system" {
 			roleTitle = "## System"
@@ -507,6 +758,18 @@ func displayMessageHistory(messages []history.HistoryMessage) {
 				markdown.WriteString("### Text\n")
 				markdown.WriteString(block.Text + "\n\n")
 
+			case "thinking":
+				markdown.WriteString("### Thinking\n")
+				markdown.WriteString("> " + strings.ReplaceAll(block.Thinking, "\n", "\n> ") + "\n\n")
+
+			case "redacted_thinking":
+				markdown.WriteString("### Thinking\n")
+				markdown.WriteString("*Redacted by the provider*\n\n")
+
+			case "image", "document":
+				markdown.WriteString("### Attachment\n")
+				markdown.WriteString(fmt.Sprintf("📎 %s (%s)\n\n", block.Name, block.MediaType))
+
 			case "tool_use":
 				markdown.WriteString("### Tool Use\n")
 				markdown.WriteString(
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+type authorizationKey struct{}
+
+// withAuthorization passes the Authorization header of a request to the
+// tool handlers
+func withAuthorization(ctx context.Context, r *http.Request) context.Context {
+	return context.WithValue(ctx, authorizationKey{}, r.Header.Get("Authorization"))
+}
+
+// newTestMCPServer returns a server with a tool that echoes its argument and
+// the Authorization header of the request
+func newTestMCPServer() *server.MCPServer {
+	mcpServer := server.NewMCPServer("test", "1.0.0", server.WithToolCapabilities(true))
+	mcpServer.AddTool(
+		mcp.NewTool("echo", mcp.WithString("text", mcp.Required())),
+		func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			authorization, _ := ctx.Value(authorizationKey{}).(string)
+			return mcp.NewToolResultText(request.GetString("text", "") + " " + authorization), nil
+		},
+	)
+	return mcpServer
+}
+
+// startInProcessClient connects a client to mcpServer in the same process
+// and registers the server's capabilities under name, like
+// createMCPClients does
+func startInProcessClient(t *testing.T, name string, mcpServer *server.MCPServer) *mcpclient.Client {
+	t.Helper()
+	client, err := mcpclient.NewInProcessClient(mcpServer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := client.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	initRequest := mcp.InitializeRequest{}
+	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	initRequest.Params.ClientInfo = mcp.Implementation{Name: "mcphost-test", Version: "0.1.0"}
+	initResult, err := client.Initialize(ctx, initRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverCapabilities[name] = initResult.Capabilities
+	t.Cleanup(func() {
+		client.Close()
+		delete(serverCapabilities, name)
+	})
+	return client
+}
+
+func TestMCPClientRoundTrip(t *testing.T) {
+	tests := []struct {
+		transport string
+		serve     func(*server.MCPServer) *httptest.Server
+		path      string
+	}{
+		{
+			transport: transportSSE,
+			serve: func(s *server.MCPServer) *httptest.Server {
+				return server.NewTestServer(s, server.WithSSEContextFunc(withAuthorization))
+			},
+			path: "/sse",
+		},
+		{
+			transport: transportStreamableHTTP,
+			serve: func(s *server.MCPServer) *httptest.Server {
+				return server.NewTestStreamableHTTPServer(s, server.WithHTTPContextFunc(withAuthorization))
+			},
+			path: "/mcp",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.transport, func(t *testing.T) {
+			mcpServer := newTestMCPServer()
+			httpServer := tt.serve(mcpServer)
+			defer httpServer.Close()
+
+			client, err := newMCPClient(ServerConfig{
+				Transport: tt.transport,
+				URL:       httpServer.URL + tt.path,
+				Headers:   map[string]string{"Authorization": "Bearer secret"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := client.Start(ctx); err != nil {
+				t.Fatal(err)
+			}
+			initRequest := mcp.InitializeRequest{}
+			initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+			initRequest.Params.ClientInfo = mcp.Implementation{Name: "mcphost-test", Version: "0.1.0"}
+			if _, err := client.Initialize(ctx, initRequest); err != nil {
+				t.Fatal(err)
+			}
+
+			notifications := make(chan string, 16)
+			client.OnNotification(func(notification mcp.JSONRPCNotification) {
+				notifications <- notification.Method
+			})
+
+			tools, err := client.ListTools(ctx, mcp.ListToolsRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tools.Tools) != 1 || tools.Tools[0].Name != "echo" {
+				t.Fatalf("tools = %+v", tools.Tools)
+			}
+
+			callRequest := mcp.CallToolRequest{}
+			callRequest.Params.Name = "echo"
+			callRequest.Params.Arguments = map[string]any{"text": "hello"}
+			result, err := client.CallTool(ctx, callRequest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text := callResultText(result); text != "hello Bearer secret" {
+				t.Errorf("result = %q, want the argument and the configured header", text)
+			}
+
+			// Notifications sent while no request is in flight arrive over
+			// the listening stream, which may connect after initialization
+			ticker := time.NewTicker(50 * time.Millisecond)
+			defer ticker.Stop()
+			for {
+				mcpServer.SendNotificationToAllClients(mcp.MethodNotificationToolsListChanged, nil)
+				select {
+				case method := <-notifications:
+					if method != mcp.MethodNotificationToolsListChanged {
+						t.Errorf("notification = %s", method)
+					}
+					return
+				case <-ticker.C:
+				case <-ctx.Done():
+					t.Fatal("no notification received while idle")
+				}
+			}
+		})
+	}
+}
+
+// callResultText joins the text content of a tool result
+func callResultText(result *mcp.CallToolResult) string {
+	var text string
+	for _, content := range result.Content {
+		if textContent, ok := content.(mcp.TextContent); ok {
+			text += textContent.Text
+		}
+	}
+	return text
+}
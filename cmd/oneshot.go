@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/vincent-pli/mcphost/pkg/history"
+	"github.com/vincent-pli/mcphost/pkg/llm"
+	"golang.org/x/term"
+)
+
+// readOneShotPrompt builds the prompt for non-interactive mode from the
+// --prompt flag and stdin. It returns an empty prompt when mcphost should run
+// interactively.
+func readOneShotPrompt() (string, error) {
+	return oneShotPrompt(promptFlag, stdinFlag, os.Stdin)
+}
+
+// oneShotPrompt combines the prompt of the flag with the text read from
+// stdin. Without a prompt, stdin is read when it is piped or redirected from
+// a file. With one, a pipe is only read with --stdin: CI runners, ssh and
+// `docker run -i` leave a pipe open that is never written to, and reading it
+// would block forever.
+func oneShotPrompt(flag string, readStdin bool, stdin *os.File) (string, error) {
+	prompt := strings.TrimSpace(flag)
+
+	interactive := term.IsTerminal(int(stdin.Fd()))
+	if !readStdin && !interactive {
+		info, err := stdin.Stat()
+		if err != nil {
+			return "", fmt.Errorf("error checking stdin: %v", err)
+		}
+		mode := info.Mode()
+		readStdin = mode.IsRegular() || (prompt == "" && mode&os.ModeNamedPipe != 0)
+	}
+
+	if readStdin {
+		input, err := io.ReadAll(stdin)
+		if err != nil {
+			return "", fmt.Errorf("error reading prompt from stdin: %v", err)
+		}
+
+		// Text from stdin is appended to the flag so both can be combined,
+		// e.g. `cat main.go | mcphost -p "review this file" --stdin`
+		if text := strings.TrimSpace(string(input)); text != "" {
+			if prompt != "" {
+				prompt += "\n\n"
+			}
+			prompt += text
+		}
+	}
+
+	if prompt == "" && (readStdin || !interactive) {
+		return "", fmt.Errorf("stdin is not a terminal and no prompt was provided")
+	}
+	return prompt, nil
+}
+
+// runOneShot runs a single agentic turn and prints the final assistant text
+// to stdout. It fails if the provider or any tool call failed.
+func runOneShot(
+	provider llm.Provider,
+	mcpClients map[string]mcpclient.MCPClient,
+	tools []llm.Tool,
+	prompt string,
+	messages []history.HistoryMessage,
+) error {
+	ctx := context.Background()
+	messages = compactOrPrune(ctx, provider, messages)
+
+	// Only the answer to this prompt is reported
+	start := len(messages)
+	if err := runPrompt(ctx, provider, mcpClients, tools, prompt, &messages); err != nil {
+		return err
+	}
+
+	for i := len(messages) - 1; i >= start; i-- {
+		if messages[i].Role == "assistant" {
+			if text := messages[i].GetContent(); text != "" {
+				fmt.Println(text)
+			}
+			break
+		}
+	}
+
+	if failed := failedToolCalls(messages[start:]); len(failed) > 0 {
+		return fmt.Errorf("tool calls failed: %s", strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// failedToolCalls returns the names of the tool calls whose results were
+// reported as errors
+func failedToolCalls(messages []history.HistoryMessage) []string {
+	names := make(map[string]string)
+	var failed []string
+	for _, msg := range messages {
+		for _, block := range msg.Content {
+			switch {
+			case block.Type == "tool_use":
+				names[block.ID] = block.Name
+			case block.Type == "tool_result" && block.IsError:
+				failed = append(failed, names[block.ToolUseID])
+			}
+		}
+	}
+	return failed
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// pipeStdin returns the read end of a pipe holding input. The write end is
+// only closed when close is set, like a parent that never writes to stdin.
+func pipeStdin(t *testing.T, input string, close bool) *os.File {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		r.Close()
+		w.Close()
+	})
+	if _, err := w.WriteString(input); err != nil {
+		t.Fatal(err)
+	}
+	if close {
+		w.Close()
+	}
+	return r
+}
+
+func TestOneShotPrompt(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "input.txt")
+	if err := os.WriteFile(file, []byte("file text\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	openFile := func(t *testing.T) *os.File {
+		f, err := os.Open(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { f.Close() })
+		return f
+	}
+	openDevNull := func(t *testing.T) *os.File {
+		f, err := os.Open(os.DevNull)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { f.Close() })
+		return f
+	}
+
+	tests := []struct {
+		name      string
+		flag      string
+		readStdin bool
+		stdin     func(t *testing.T) *os.File
+		want      string
+		wantErr   string
+	}{
+		{
+			name:  "piped prompt",
+			stdin: func(t *testing.T) *os.File { return pipeStdin(t, " piped text\n", true) },
+			want:  "piped text",
+		},
+		{
+			name:  "flag with open pipe",
+			flag:  "summarize",
+			stdin: func(t *testing.T) *os.File { return pipeStdin(t, "", false) },
+			want:  "summarize",
+		},
+		{
+			name:      "flag with piped text",
+			flag:      "summarize",
+			readStdin: true,
+			stdin:     func(t *testing.T) *os.File { return pipeStdin(t, "piped text", true) },
+			want:      "summarize\n\npiped text",
+		},
+		{
+			name:  "flag with redirected file",
+			flag:  "summarize",
+			stdin: openFile,
+			want:  "summarize\n\nfile text",
+		},
+		{
+			name:  "redirected file",
+			stdin: openFile,
+			want:  "file text",
+		},
+		{
+			name:    "empty pipe",
+			stdin:   func(t *testing.T) *os.File { return pipeStdin(t, "  \n", true) },
+			wantErr: "no prompt was provided",
+		},
+		{
+			name:    "device without prompt",
+			stdin:   openDevNull,
+			wantErr: "no prompt was provided",
+		},
+		{
+			name:  "device with prompt",
+			flag:  "summarize",
+			stdin: openDevNull,
+			want:  "summarize",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := oneShotPrompt(tt.flag, tt.readStdin, tt.stdin(t))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("prompt = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestKillProcessGroup(t *testing.T) {
+	// The server starts a child of its own, which has to be killed too
+	pidFile := filepath.Join(t.TempDir(), "child.pid")
+	cmd, err := serverCommand(context.Background(), "sh", nil, []string{
+		"-c", "sleep 60 & echo $! > " + pidFile + "; wait",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	var childPid int
+	deadline := time.Now().Add(5 * time.Second)
+	for childPid == 0 && time.Now().Before(deadline) {
+		data, _ := os.ReadFile(pidFile)
+		childPid, _ = strconv.Atoi(strings.TrimSpace(string(data)))
+		time.Sleep(10 * time.Millisecond)
+	}
+	if childPid == 0 {
+		t.Fatal("the server did not start its child")
+	}
+
+	killProcessGroup(cmd)
+	if err := cmd.Wait(); err == nil {
+		t.Error("the server exited normally, want it killed")
+	}
+
+	for processRunning(childPid) {
+		if time.Now().After(deadline) {
+			syscall.Kill(childPid, syscall.SIGKILL)
+			t.Fatal("the child of the server is still running")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// processRunning reports whether the process exists and is no zombie
+// waiting to be reaped
+func processRunning(pid int) bool {
+	if syscall.Kill(pid, 0) != nil {
+		return false
+	}
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return true
+	}
+	// The state follows the command name in parentheses
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) == 0 || fields[0] != "Z"
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type toolCallState int
+
+const (
+	toolCallQueued toolCallState = iota
+	toolCallRunning
+	toolCallDone
+	toolCallFailed
+	toolCallCancelled
+)
+
+// toolCallUpdate reports a state change of the call at index
+type toolCallUpdate struct {
+	index int
+	state toolCallState
+}
+
+// toolCallsFinished is sent once all calls are done
+type toolCallsFinished struct{}
+
+// toolProgressPause hides the view and hands the terminal over while the
+// user is asked something during the calls. It runs as the command of
+// tea.Exec, which releases the terminal before Run closes released, and
+// holds it until resume is closed.
+type toolProgressPause struct {
+	released chan struct{}
+	resume   chan struct{}
+}
+
+func (p toolProgressPause) Run() error {
+	close(p.released)
+	<-p.resume
+	return nil
+}
+
+func (toolProgressPause) SetStdin(io.Reader)  {}
+func (toolProgressPause) SetStdout(io.Writer) {}
+func (toolProgressPause) SetStderr(io.Writer) {}
+
+// toolProgressResumed is sent once the terminal is back after a pause
+type toolProgressResumed struct{}
+
+var (
+	progressNameStyle  = lipgloss.NewStyle().Foreground(tokyoCyan)
+	progressMutedStyle = lipgloss.NewStyle().Foreground(tokyoGray)
+	progressDoneStyle  = lipgloss.NewStyle().Foreground(tokyoGreen)
+	progressFailStyle  = lipgloss.NewStyle().Foreground(tokyoRed)
+)
+
+// toolProgressModel shows one line per tool call of a turn with its state
+// and running time
+type toolProgressModel struct {
+	names   []string
+	states  []toolCallState
+	started []time.Time
+	elapsed []time.Duration
+	spinner spinner.Model
+	paused  bool
+}
+
+func newToolProgressModel(names []string) toolProgressModel {
+	return toolProgressModel{
+		names:   names,
+		states:  make([]toolCallState, len(names)),
+		started: make([]time.Time, len(names)),
+		elapsed: make([]time.Duration, len(names)),
+		spinner: spinner.New(
+			spinner.WithSpinner(spinner.Dot),
+			spinner.WithStyle(lipgloss.NewStyle().Foreground(tokyoPurple)),
+		),
+	}
+}
+
+func (m toolProgressModel) Init() tea.Cmd {
+	return m.spinner.Tick
+}
+
+func (m toolProgressModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case toolCallUpdate:
+		m.states[msg.index] = msg.state
+		switch msg.state {
+		case toolCallRunning:
+			m.started[msg.index] = time.Now()
+		case toolCallDone, toolCallFailed, toolCallCancelled:
+			m.elapsed[msg.index] = time.Since(m.started[msg.index])
+		}
+		return m, nil
+
+	case toolCallsFinished:
+		// The last frame shows the calls even if the resume after a
+		// pause has not arrived yet
+		m.paused = false
+		return m, tea.Quit
+
+	case toolProgressPause:
+		// The empty view is rendered before the command runs, so the
+		// terminal is released without the progress lines
+		m.paused = true
+		return m, tea.Exec(msg, func(error) tea.Msg { return toolProgressResumed{} })
+
+	case toolProgressResumed:
+		m.paused = false
+		return m, nil
+
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+	}
+
+	return m, nil
+}
+
+func (m toolProgressModel) View() string {
+	if m.paused {
+		return ""
+	}
+
+	var view strings.Builder
+	for i, name := range m.names {
+		var icon, status string
+		switch m.states[i] {
+		case toolCallQueued:
+			icon = progressMutedStyle.Render("•")
+			status = progressMutedStyle.Render("queued")
+		case toolCallRunning:
+			icon = m.spinner.View()
+			status = progressMutedStyle.Render(formatElapsed(time.Since(m.started[i])))
+		case toolCallDone:
+			icon = progressDoneStyle.Render("✓")
+			status = progressMutedStyle.Render(formatElapsed(m.elapsed[i]))
+		case toolCallFailed:
+			icon = progressFailStyle.Render("✗")
+			status = progressFailStyle.Render("failed after " + formatElapsed(m.elapsed[i]))
+		case toolCallCancelled:
+			icon = progressFailStyle.Render("✗")
+			status = progressMutedStyle.Render("cancelled")
+		}
+		fmt.Fprintf(&view, "  %s %s %s\n", icon, progressNameStyle.Render(name), status)
+	}
+	return view.String()
+}
+
+func formatElapsed(d time.Duration) string {
+	return fmt.Sprintf("%.1fs", d.Seconds())
+}
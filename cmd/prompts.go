@@ -0,0 +1,289 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/log"
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/vincent-pli/mcphost/pkg/history"
+	"github.com/vincent-pli/mcphost/pkg/llm"
+)
+
+var (
+	// serverPrompts holds the prompts of every server offering prompts.
+	// They are used as /server:prompt-name commands.
+	serverPrompts = make(map[string][]mcp.Prompt)
+
+	// promptsMu guards serverPrompts, which are refreshed when a server
+	// notifies that its prompts changed
+	promptsMu sync.Mutex
+)
+
+// loadServerPrompts lists the prompts of the servers offering prompts
+func loadServerPrompts(mcpClients map[string]mcpclient.MCPClient) {
+	for serverName, mcpClient := range mcpClients {
+		if serverCapabilities[serverName].Prompts == nil {
+			continue
+		}
+
+		count, err := loadPrompts(serverName, mcpClient)
+		if err != nil {
+			log.Error("Error fetching prompts", "server", serverName, "error", err)
+			continue
+		}
+		log.Info("Prompts loaded", "server", serverName, "count", count)
+	}
+}
+
+// loadPrompts lists the prompts of a server and returns their number
+func loadPrompts(serverName string, client mcpclient.MCPClient) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	promptsResult, err := client.ListPrompts(ctx, mcp.ListPromptsRequest{})
+	cancel()
+	if err != nil {
+		return 0, err
+	}
+
+	promptsMu.Lock()
+	defer promptsMu.Unlock()
+	serverPrompts[serverName] = promptsResult.Prompts
+	return len(promptsResult.Prompts), nil
+}
+
+// findServerPrompt returns the server and prompt of a /server:prompt-name
+// command
+func findServerPrompt(prompt string) (string, mcp.Prompt, bool) {
+	fields := strings.Fields(prompt)
+	if len(fields) == 0 {
+		return "", mcp.Prompt{}, false
+	}
+	name, ok := strings.CutPrefix(fields[0], "/")
+	if !ok {
+		return "", mcp.Prompt{}, false
+	}
+	serverName, promptName, ok := strings.Cut(name, ":")
+	if !ok {
+		return "", mcp.Prompt{}, false
+	}
+
+	promptsMu.Lock()
+	defer promptsMu.Unlock()
+	for _, prompt := range serverPrompts[serverName] {
+		if prompt.Name == promptName {
+			return serverName, prompt, true
+		}
+	}
+	return "", mcp.Prompt{}, false
+}
+
+// promptCommands lists the prompts of all servers as commands for /help
+func promptCommands() []string {
+	promptsMu.Lock()
+	defer promptsMu.Unlock()
+
+	var commands []string
+	for serverName, prompts := range serverPrompts {
+		for _, prompt := range prompts {
+			var command strings.Builder
+			fmt.Fprintf(&command, "- **/%s:%s", serverName, prompt.Name)
+			for _, arg := range prompt.Arguments {
+				if arg.Required {
+					fmt.Fprintf(&command, " \\<%s\\>", arg.Name)
+				} else {
+					fmt.Fprintf(&command, " [%s]", arg.Name)
+				}
+			}
+			command.WriteString("**")
+			if prompt.Description != "" {
+				command.WriteString(": " + prompt.Description)
+			}
+			commands = append(commands, command.String())
+		}
+	}
+	sort.Strings(commands)
+	return commands
+}
+
+// handlePromptCommand runs a /server:prompt-name command. Arguments can be
+// given as name=value, required arguments that are missing are asked for.
+// The messages of the prompt are added to the history, and true is returned
+// if they end with a user message the model should answer.
+func handlePromptCommand(
+	prompt string,
+	provider llm.Provider,
+	mcpClients map[string]mcpclient.MCPClient,
+	messages *[]history.HistoryMessage,
+) bool {
+	fields := strings.Fields(prompt)
+	serverName, serverPrompt, _ := findServerPrompt(prompt)
+
+	args := make(map[string]string)
+	for _, field := range fields[1:] {
+		name, value, ok := strings.Cut(field, "=")
+		if !ok {
+			fmt.Printf("\n%s\n\n", errorStyle.Render(
+				fmt.Sprintf("Invalid argument %q, use name=value", field),
+			))
+			return false
+		}
+		args[name] = value
+	}
+
+	if err := askPromptArguments(serverPrompt, args); err != nil {
+		if !errors.Is(err, huh.ErrUserAborted) {
+			fmt.Printf("\n%s\n\n", errorStyle.Render(
+				fmt.Sprintf("Error reading prompt arguments: %v", err),
+			))
+		}
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	req := mcp.GetPromptRequest{}
+	req.Params.Name = serverPrompt.Name
+	req.Params.Arguments = args
+	result, err := mcpClients[serverName].GetPrompt(ctx, req)
+	if err != nil {
+		fmt.Printf("\n%s\n\n", errorStyle.Render(
+			fmt.Sprintf("Error getting prompt %s from %s: %v", serverPrompt.Name, serverName, err),
+		))
+		return false
+	}
+	if len(result.Messages) == 0 {
+		fmt.Printf("\n%s\n\n", errorStyle.Render(
+			fmt.Sprintf("Prompt %s of %s has no messages", serverPrompt.Name, serverName),
+		))
+		return false
+	}
+
+	promptMessages := make([]history.HistoryMessage, 0, len(result.Messages))
+	for _, message := range result.Messages {
+		promptMessages = append(promptMessages, history.HistoryMessage{
+			Role:    string(message.Role),
+			Content: []history.ContentBlock{promptContentBlock(provider, modelFlag, message.Content)},
+		})
+	}
+
+	fmt.Printf("\n%s\n", promptStyle.Render("You: "+prompt))
+	for _, message := range promptMessages {
+		fmt.Printf("%s\n", attachmentStyle.Render(
+			fmt.Sprintf("%s: %s", message.Role, promptMessageSummary(message)),
+		))
+	}
+
+	// Providers expect the roles to alternate, so messages following one of
+	// the same role are merged into it
+	for _, message := range promptMessages {
+		last := len(*messages) - 1
+		if last >= 0 && (*messages)[last].Role == message.Role {
+			(*messages)[last].Content = append((*messages)[last].Content, message.Content...)
+			continue
+		}
+		*messages = append(*messages, message)
+	}
+
+	if promptMessages[len(promptMessages)-1].Role != string(mcp.RoleUser) {
+		fmt.Printf("\n%s\n\n", responseStyle.Render(
+			"The prompt ends with an assistant message, send a prompt to continue",
+		))
+		return false
+	}
+	return true
+}
+
+// askPromptArguments asks for the required arguments of a prompt that were
+// not given with the command
+func askPromptArguments(prompt mcp.Prompt, args map[string]string) error {
+	var fields []huh.Field
+	values := make(map[string]*string)
+	for _, arg := range prompt.Arguments {
+		if !arg.Required || args[arg.Name] != "" {
+			continue
+		}
+
+		value := new(string)
+		values[arg.Name] = value
+		fields = append(fields, huh.NewInput().
+			Title(arg.Name).
+			Description(arg.Description).
+			Validate(func(s string) error {
+				if strings.TrimSpace(s) == "" {
+					return fmt.Errorf("%s is required", arg.Name)
+				}
+				return nil
+			}).
+			Value(value))
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	form := huh.NewForm(huh.NewGroup(fields...)).
+		WithWidth(getTerminalWidth()).
+		WithTheme(huh.ThemeCharm())
+	if err := form.Run(); err != nil {
+		return err
+	}
+
+	for name, value := range values {
+		args[name] = *value
+	}
+	return nil
+}
+
+// promptContentBlock converts the content of a prompt message to a history
+// block. Images and resources become attachments if the model, given as
+// provider:model, can take them.
+func promptContentBlock(provider llm.Provider, modelString string, content mcp.Content) history.ContentBlock {
+	var block history.ContentBlock
+	var err error
+	switch c := content.(type) {
+	case mcp.TextContent:
+		return history.ContentBlock{Type: "text", Text: c.Text}
+	case mcp.ImageContent:
+		block = history.ContentBlock{
+			Type:      "image",
+			Name:      "image",
+			MediaType: c.MIMEType,
+			Data:      c.Data,
+		}
+	case mcp.EmbeddedResource:
+		block, err = resourceBlock(c.Resource)
+	default:
+		err = fmt.Errorf("unsupported content")
+	}
+	if err == nil {
+		err = checkAttachment(provider, modelString, block)
+	}
+	if err == nil {
+		return block
+	}
+
+	// Content that cannot be attached is described like in tool results
+	parts := history.ToolResultParts(history.ContentBlock{Content: []mcp.Content{content}})
+	return history.ContentBlock{Type: "text", Text: history.ToolResultText(parts)}
+}
+
+// promptMessageSummary shortens a prompt message to a line
+func promptMessageSummary(message history.HistoryMessage) string {
+	block := message.Content[0]
+	if block.IsAttachment() {
+		return "📎 " + block.Name
+	}
+
+	text := strings.Join(strings.Fields(block.Text), " ")
+	if len([]rune(text)) > 100 {
+		text = string([]rune(text)[:100]) + "..."
+	}
+	return text
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/vincent-pli/mcphost/pkg/history"
+)
+
+// newPromptServer returns a server with a review prompt, which ends with
+// two user messages, and a greet prompt, which is one assistant message
+func newPromptServer() *server.MCPServer {
+	mcpServer := server.NewMCPServer("code", "1.0.0", server.WithPromptCapabilities(false))
+	mcpServer.AddPrompt(
+		mcp.NewPrompt("review", mcp.WithArgument("file", mcp.RequiredArgument())),
+		func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+			return mcp.NewGetPromptResult("Review a file", []mcp.PromptMessage{
+				mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent("Review "+request.Params.Arguments["file"])),
+				mcp.NewPromptMessage(mcp.RoleAssistant, mcp.NewTextContent("Which part?")),
+				mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent("All of it.")),
+				mcp.NewPromptMessage(mcp.RoleUser, mcp.NewImageContent("aW1hZ2U=", "image/png")),
+			}), nil
+		},
+	)
+	mcpServer.AddPrompt(
+		mcp.NewPrompt("greet"),
+		func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+			return mcp.NewGetPromptResult("Greet", []mcp.PromptMessage{
+				mcp.NewPromptMessage(mcp.RoleAssistant, mcp.NewTextContent("Hello again.")),
+			}), nil
+		},
+	)
+	return mcpServer
+}
+
+// loadTestPrompts loads the prompts of mcpServer as server code
+func loadTestPrompts(t *testing.T, mcpServer *server.MCPServer) map[string]mcpclient.MCPClient {
+	t.Helper()
+	saved := serverPrompts
+	serverPrompts = make(map[string][]mcp.Prompt)
+	t.Cleanup(func() { serverPrompts = saved })
+
+	mcpClients := map[string]mcpclient.MCPClient{
+		"code": startInProcessClient(t, "code", mcpServer),
+		"fs":   startInProcessClient(t, "fs", newTestMCPServer()),
+	}
+	loadServerPrompts(mcpClients)
+	return mcpClients
+}
+
+func TestFindServerPrompt(t *testing.T) {
+	loadTestPrompts(t, newPromptServer())
+
+	tests := []struct {
+		prompt string
+		want   string
+	}{
+		{"/code:review file=main.go", "code:review"},
+		{"  /code:greet  ", "code:greet"},
+		{"/code:missing", ""},
+		{"/fs:review", ""},
+		{"/review", ""},
+		{"code:review", ""},
+		{"Please run /code:review", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		serverName, prompt, ok := findServerPrompt(tt.prompt)
+		got := ""
+		if ok {
+			got = serverName + ":" + prompt.Name
+		}
+		if got != tt.want {
+			t.Errorf("findServerPrompt(%q) = %q, want %q", tt.prompt, got, tt.want)
+		}
+	}
+}
+
+func TestHandlePromptCommand(t *testing.T) {
+	mcpClients := loadTestPrompts(t, newPromptServer())
+	savedModel := modelFlag
+	defer func() { modelFlag = savedModel }()
+
+	conversation := func() []history.HistoryMessage {
+		return []history.HistoryMessage{
+			{Role: "user", Content: []history.ContentBlock{{Type: "text", Text: "Hi"}}},
+			{Role: "assistant", Content: []history.ContentBlock{{Type: "text", Text: "Hello."}}},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		model    string
+		prompt   string
+		messages []history.HistoryMessage
+		want     string
+		wantText string
+		wantSend bool
+	}{
+		{
+			name:     "user messages merged",
+			model:    "anthropic:claude-3-5-sonnet-latest",
+			prompt:   "/code:review file=main.go",
+			messages: conversation(),
+			want:     "user:text assistant:text user:text assistant:text user:text,image",
+			wantText: "Hi Hello. Review main.go Which part? All of it.",
+			wantSend: true,
+		},
+		{
+			name:     "image described for a text-only model",
+			model:    "ollama:qwen2.5:3b",
+			prompt:   "/code:review file=main.go",
+			want:     "user:text assistant:text user:text,text",
+			wantText: "Review main.go Which part? All of it. " + history.ImagePlaceholder("image/png"),
+			wantSend: true,
+		},
+		{
+			name:     "assistant message merged into the last one",
+			model:    "anthropic:claude-3-5-sonnet-latest",
+			prompt:   "/code:greet",
+			messages: conversation(),
+			want:     "user:text assistant:text,text",
+			wantText: "Hi Hello. Hello again.",
+		},
+		{
+			name:     "invalid argument",
+			model:    "anthropic:claude-3-5-sonnet-latest",
+			prompt:   "/code:review main.go",
+			messages: conversation(),
+			want:     "user:text assistant:text",
+			wantText: "Hi Hello.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modelFlag = tt.model
+			provider := namedProvider{name: strings.SplitN(tt.model, ":", 2)[0]}
+			messages := tt.messages
+
+			send := handlePromptCommand(tt.prompt, provider, mcpClients, &messages)
+			if send != tt.wantSend {
+				t.Errorf("send = %v, want %v", send, tt.wantSend)
+			}
+			if got := describeMessages(messages); got != tt.want {
+				t.Errorf("messages = %s, want %s", got, tt.want)
+			}
+			var texts []string
+			for _, message := range messages {
+				if text := message.GetContent(); text != "" {
+					texts = append(texts, text)
+				}
+			}
+			if got := strings.Join(texts, " "); got != tt.wantText {
+				t.Errorf("text = %q, want %q", got, tt.wantText)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/vincent-pli/mcphost/pkg/llm"
+	"github.com/vincent-pli/mcphost/pkg/llm/openai"
+)
+
+// ProviderConfig is a named profile for an OpenAI-compatible endpoint,
+// selected with -m <name>:<model>
+type ProviderConfig struct {
+	BaseURL string `json:"baseURL"`
+
+	// APIKeyEnv is the environment variable holding the API key. Endpoints
+	// without authentication can leave it empty.
+	APIKeyEnv string `json:"apiKeyEnv,omitempty"`
+
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// DefaultModel is used when -m names only the profile
+	DefaultModel string `json:"defaultModel,omitempty"`
+
+	// Parameters are added to every request body, e.g. top_p. Generation
+	// options from the flags, the models section and /set take precedence.
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// builtinProviders are the provider names profiles cannot use
+var builtinProviders = map[string]bool{
+	"anthropic": true,
+	"openai":    true,
+	"azure":     true,
+	"ollama":    true,
+	"gemini":    true,
+	"bedrock":   true,
+}
+
+// validateProviders checks the provider profiles of the config
+func (c *MCPConfig) validateProviders() error {
+	for name, profile := range c.Providers {
+		if builtinProviders[name] {
+			return fmt.Errorf("provider %s: name is reserved for the built-in provider", name)
+		}
+		if profile.BaseURL == "" {
+			return fmt.Errorf("provider %s: baseURL is required", name)
+		}
+		for _, key := range openai.ReservedParameters {
+			if _, ok := profile.Parameters[key]; ok {
+				return fmt.Errorf("provider %s: parameter %s is set by mcphost and cannot be overridden", name, key)
+			}
+		}
+	}
+	return nil
+}
+
+// expandModelString fills in the default model of a provider profile when
+// the model string names only the profile, e.g. "deepseek" or "deepseek:"
+func expandModelString(modelString string, config *MCPConfig) string {
+	name, model, _ := strings.Cut(modelString, ":")
+	profile, ok := config.Providers[name]
+	if !ok || model != "" || profile.DefaultModel == "" {
+		return modelString
+	}
+	return name + ":" + profile.DefaultModel
+}
+
+// createProfileProvider creates an OpenAI provider from a profile
+func createProfileProvider(name string, profile ProviderConfig, model string) (llm.Provider, error) {
+	if model == "" {
+		return nil, fmt.Errorf(
+			"no model given for provider %s. Use -m %s:<model> or set defaultModel in its profile",
+			name, name,
+		)
+	}
+
+	var apiKey string
+	if profile.APIKeyEnv != "" {
+		apiKey = os.Getenv(profile.APIKeyEnv)
+		if apiKey == "" {
+			return nil, fmt.Errorf(
+				"API key for provider %s not provided. Set the %s environment variable",
+				name, profile.APIKeyEnv,
+			)
+		}
+	}
+
+	return openai.NewProviderWithOptions(apiKey, profile.BaseURL, model, openai.Options{
+		Headers:    profile.Headers,
+		Parameters: profile.Parameters,
+	}), nil
+}
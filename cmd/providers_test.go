@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateProviders(t *testing.T) {
+	tests := []struct {
+		name      string
+		providers map[string]ProviderConfig
+		wantErr   string
+	}{
+		{
+			name: "valid",
+			providers: map[string]ProviderConfig{
+				"groq": {BaseURL: "https://api.groq.com/openai/v1", Parameters: map[string]interface{}{"top_p": 0.9}},
+			},
+		},
+		{
+			name:      "built-in name",
+			providers: map[string]ProviderConfig{"openai": {BaseURL: "https://example.com"}},
+			wantErr:   "name is reserved",
+		},
+		{
+			name:      "missing base URL",
+			providers: map[string]ProviderConfig{"groq": {}},
+			wantErr:   "baseURL is required",
+		},
+		{
+			name: "reserved parameter",
+			providers: map[string]ProviderConfig{
+				"groq": {BaseURL: "https://example.com", Parameters: map[string]interface{}{"stream": false}},
+			},
+			wantErr: "parameter stream is set by mcphost",
+		},
+		{
+			name: "model parameter",
+			providers: map[string]ProviderConfig{
+				"groq": {BaseURL: "https://example.com", Parameters: map[string]interface{}{"model": "x"}},
+			},
+			wantErr: "parameter model is set by mcphost",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &MCPConfig{Providers: tt.providers}
+			err := config.validateProviders()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExpandModelString(t *testing.T) {
+	config := &MCPConfig{Providers: map[string]ProviderConfig{
+		"groq":     {BaseURL: "https://api.groq.com/openai/v1", DefaultModel: "llama-3.1-8b-instant"},
+		"lmstudio": {BaseURL: "http://localhost:1234/v1"},
+	}}
+
+	tests := []struct {
+		model string
+		want  string
+	}{
+		{"groq", "groq:llama-3.1-8b-instant"},
+		{"groq:", "groq:llama-3.1-8b-instant"},
+		{"groq:mixtral-8x7b", "groq:mixtral-8x7b"},
+		{"lmstudio", "lmstudio"},
+		{"anthropic:claude-3-5-sonnet-latest", "anthropic:claude-3-5-sonnet-latest"},
+		{"ollama:qwen2.5:3b", "ollama:qwen2.5:3b"},
+		{"unknown", "unknown"},
+	}
+	for _, tt := range tests {
+		if got := expandModelString(tt.model, config); got != tt.want {
+			t.Errorf("expandModelString(%q) = %q, want %q", tt.model, got, tt.want)
+		}
+	}
+}
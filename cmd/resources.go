@@ -0,0 +1,393 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/huh/spinner"
+	"github.com/charmbracelet/lipgloss"
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/vincent-pli/mcphost/pkg/history"
+	"github.com/vincent-pli/mcphost/pkg/llm"
+)
+
+const (
+	// hostServerName is the server part of the names of tools mcphost
+	// provides itself
+	hostServerName = "mcphost"
+
+	// readResourceTool lets the model read resources of the servers
+	readResourceTool = hostServerName + "__read_resource"
+
+	// maxListedResources limits the resources listed in the description
+	// of the read_resource tool
+	maxListedResources = 50
+)
+
+var (
+	// resourceToolFlag adds the read_resource tool
+	resourceToolFlag bool
+
+	// serverCapabilities holds the capabilities the servers reported when
+	// they were initialized
+	serverCapabilities = make(map[string]mcp.ServerCapabilities)
+)
+
+// resourceServers returns the sorted names of the servers offering
+// resources
+func resourceServers(mcpClients map[string]mcpclient.MCPClient) []string {
+	var names []string
+	for name := range mcpClients {
+		if serverCapabilities[name].Resources != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// serverResources are the resources and resource templates of a server
+type serverResources struct {
+	resources []mcp.Resource
+	templates []mcp.ResourceTemplate
+	err       error
+}
+
+// listResources fetches the resources of a server. Templates are optional,
+// servers that fail to list them are taken to have none.
+func listResources(ctx context.Context, client mcpclient.MCPClient) serverResources {
+	resourcesResult, err := client.ListResources(ctx, mcp.ListResourcesRequest{})
+	if err != nil {
+		return serverResources{err: err}
+	}
+
+	result := serverResources{resources: resourcesResult.Resources}
+	if templatesResult, err := client.ListResourceTemplates(ctx, mcp.ListResourceTemplatesRequest{}); err == nil {
+		result.templates = templatesResult.ResourceTemplates
+	}
+	return result
+}
+
+func templateURI(template mcp.ResourceTemplate) string {
+	if template.URITemplate == nil || template.URITemplate.Template == nil {
+		return ""
+	}
+	return template.URITemplate.Raw()
+}
+
+func handleResourcesCommand(mcpClients map[string]mcpclient.MCPClient) {
+	if err := updateRenderer(); err != nil {
+		fmt.Printf(
+			"\n%s\n",
+			errorStyle.Render(fmt.Sprintf("Error updating renderer: %v", err)),
+		)
+		return
+	}
+
+	servers := resourceServers(mcpClients)
+	results := make(map[string]serverResources)
+	action := func() {
+		for _, name := range servers {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			results[name] = listResources(ctx, mcpClients[name])
+			cancel()
+		}
+	}
+	_ = spinner.New().
+		Title("Fetching resources from all servers...").
+		Action(action).
+		Run()
+
+	var markdown strings.Builder
+	if len(servers) == 0 {
+		markdown.WriteString("No server offers resources.\n")
+	}
+	for _, name := range servers {
+		result := results[name]
+		markdown.WriteString(fmt.Sprintf("# %s\n\n", name))
+		if result.err != nil {
+			markdown.WriteString(fmt.Sprintf("*Error fetching resources: %v*\n\n", result.err))
+			continue
+		}
+		if len(result.resources) == 0 && len(result.templates) == 0 {
+			markdown.WriteString("*No resources*\n\n")
+			continue
+		}
+
+		for _, resource := range result.resources {
+			markdown.WriteString(fmt.Sprintf("- `%s` %s", resource.URI, resource.Name))
+			if resource.MIMEType != "" {
+				markdown.WriteString(fmt.Sprintf(" (%s)", resource.MIMEType))
+			}
+			if resource.Description != "" {
+				markdown.WriteString(": " + resource.Description)
+			}
+			markdown.WriteString("\n")
+		}
+		if len(result.templates) > 0 {
+			markdown.WriteString("\n*Templates*\n\n")
+			for _, template := range result.templates {
+				markdown.WriteString(fmt.Sprintf("- `%s` %s", templateURI(template), template.Name))
+				if template.Description != "" {
+					markdown.WriteString(": " + template.Description)
+				}
+				markdown.WriteString("\n")
+			}
+		}
+		markdown.WriteString("\n")
+	}
+	markdown.WriteString("Use `/resource <uri>` to attach a resource to the next prompt.\n")
+
+	rendered, err := renderer.Render(markdown.String())
+	if err != nil {
+		fmt.Printf(
+			"\n%s\n",
+			errorStyle.Render(fmt.Sprintf("Error rendering resources: %v", err)),
+		)
+		return
+	}
+
+	containerStyle := lipgloss.NewStyle().
+		MarginLeft(4).
+		MarginRight(4)
+	fmt.Print("\n" + containerStyle.Render(rendered) + "\n")
+}
+
+// handleResourceCommand reads a resource and attaches its contents to the
+// next prompt, e.g. "/resource file:///notes.txt". The server can be given
+// first when more than one server offers resources.
+func handleResourceCommand(
+	args []string,
+	provider llm.Provider,
+	mcpClients map[string]mcpclient.MCPClient,
+) {
+	if len(args) == 0 || len(args) > 2 {
+		fmt.Printf("\n%s\n\n", errorStyle.Render("Usage: /resource [server] <uri>"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var serverName, uri string
+	var err error
+	if len(args) == 2 {
+		serverName, uri = args[0], args[1]
+		if _, ok := mcpClients[serverName]; !ok {
+			err = fmt.Errorf("server not found: %s", serverName)
+		}
+	} else {
+		uri = args[0]
+		serverName, err = findResourceServer(ctx, mcpClients, uri)
+	}
+
+	var blocks []history.ContentBlock
+	if err == nil {
+		blocks, err = readResourceAttachments(ctx, mcpClients[serverName], uri)
+	}
+	for _, block := range blocks {
+		if err == nil {
+			err = checkAttachment(provider, modelFlag, block)
+		}
+	}
+	if err != nil {
+		fmt.Printf("\n%s\n\n", errorStyle.Render(err.Error()))
+		return
+	}
+
+	pendingAttachments = append(pendingAttachments, blocks...)
+	fmt.Printf("\n%s\n\n", attachmentStyle.Render(
+		fmt.Sprintf("📎 Attached resource %s from %s, it will be sent with the next prompt", uri, serverName),
+	))
+}
+
+// findResourceServer returns the server that lists the resource, or the
+// only server offering resources
+func findResourceServer(
+	ctx context.Context,
+	mcpClients map[string]mcpclient.MCPClient,
+	uri string,
+) (string, error) {
+	servers := resourceServers(mcpClients)
+	if len(servers) == 0 {
+		return "", fmt.Errorf("no server offers resources")
+	}
+
+	for _, name := range servers {
+		result := listResources(ctx, mcpClients[name])
+		for _, resource := range result.resources {
+			if resource.URI == uri {
+				return name, nil
+			}
+		}
+	}
+	if len(servers) == 1 {
+		return servers[0], nil
+	}
+	return "", fmt.Errorf("resource %s not found, use /resource <server> <uri>", uri)
+}
+
+// readResourceAttachments reads a resource and converts its contents to
+// attachment blocks named after the resource URI
+func readResourceAttachments(
+	ctx context.Context,
+	client mcpclient.MCPClient,
+	uri string,
+) ([]history.ContentBlock, error) {
+	req := mcp.ReadResourceRequest{}
+	req.Params.URI = uri
+	result, err := client.ReadResource(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("error reading resource %s: %w", uri, err)
+	}
+
+	var blocks []history.ContentBlock
+	for _, contents := range result.Contents {
+		block, err := resourceBlock(contents)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("resource %s has no contents", uri)
+	}
+	return blocks, nil
+}
+
+// resourceBlock converts the contents of a resource to an attachment block
+// named after its URI
+func resourceBlock(contents mcp.ResourceContents) (history.ContentBlock, error) {
+	switch c := contents.(type) {
+	case mcp.TextResourceContents:
+		mediaType := c.MIMEType
+		if mediaType == "" {
+			mediaType = "text/plain"
+		}
+		return history.ContentBlock{
+			Type:      "document",
+			Name:      c.URI,
+			MediaType: mediaType,
+			Text:      c.Text,
+		}, nil
+	case mcp.BlobResourceContents:
+		switch {
+		case imageTypes[c.MIMEType]:
+			return history.ContentBlock{
+				Type:      "image",
+				Name:      c.URI,
+				MediaType: c.MIMEType,
+				Data:      c.Blob,
+			}, nil
+		case c.MIMEType == "application/pdf":
+			return history.ContentBlock{
+				Type:      "document",
+				Name:      c.URI,
+				MediaType: c.MIMEType,
+				Data:      c.Blob,
+			}, nil
+		}
+		return history.ContentBlock{}, fmt.Errorf(
+			"cannot attach resource %s: unsupported type %s", c.URI, c.MIMEType)
+	}
+	return history.ContentBlock{}, fmt.Errorf("cannot attach resource: unknown contents %T", contents)
+}
+
+// resourceTool creates the read_resource tool for the servers offering
+// resources. Its description lists the resources, so the model knows what
+// it can read.
+func resourceTool(mcpClients map[string]mcpclient.MCPClient) (llm.Tool, bool) {
+	servers := resourceServers(mcpClients)
+	if _, ok := mcpClients[hostServerName]; ok || len(servers) == 0 {
+		return llm.Tool{}, false
+	}
+
+	var description strings.Builder
+	description.WriteString("Read a resource offered by an MCP server, e.g. a file or a database schema, by its URI.")
+
+	listed := 0
+	for _, name := range servers {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		result := listResources(ctx, mcpClients[name])
+		cancel()
+
+		for _, resource := range result.resources {
+			if listed == maxListedResources {
+				break
+			}
+			if listed == 0 {
+				description.WriteString("\n\nAvailable resources (server: uri):")
+			}
+			fmt.Fprintf(&description, "\n- %s: %s", name, resource.URI)
+			if resource.Description != "" {
+				fmt.Fprintf(&description, " (%s)", resource.Description)
+			}
+			listed++
+		}
+		for _, template := range result.templates {
+			fmt.Fprintf(&description, "\n- %s: %s (template)", name, templateURI(template))
+		}
+	}
+
+	serverEnum := make([]interface{}, len(servers))
+	for i, name := range servers {
+		serverEnum[i] = name
+	}
+
+	return llm.Tool{
+		Name:        readResourceTool,
+		Description: description.String(),
+		InputSchema: llm.Schema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"server": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the server offering the resource",
+					"enum":        serverEnum,
+				},
+				"uri": map[string]interface{}{
+					"type":        "string",
+					"description": "URI of the resource",
+				},
+			},
+			Required: []string{"server", "uri"},
+		},
+	}, true
+}
+
+// callResourceTool runs a call of the read_resource tool. Its contents are
+// returned as embedded resources, like a tool returning them.
+func callResourceTool(ctx context.Context, call pendingToolCall) (history.ContentBlock, string) {
+	uri, _ := call.args["uri"].(string)
+
+	req := mcp.ReadResourceRequest{}
+	req.Params.URI = uri
+	result, err := call.client.ReadResource(ctx, req)
+	if err != nil && ctx.Err() != nil {
+		return toolCancelledResult(call.id), ""
+	}
+	if err != nil {
+		errMsg := fmt.Sprintf("Error reading resource %s: %v", uri, err)
+		return toolErrorResult(call.id, errMsg), errMsg
+	}
+
+	content := make([]mcp.Content, len(result.Contents))
+	var texts []string
+	for i, contents := range result.Contents {
+		content[i] = mcp.NewEmbeddedResource(contents)
+		if text, ok := contents.(mcp.TextResourceContents); ok {
+			texts = append(texts, text.Text)
+		}
+	}
+
+	return history.ContentBlock{
+		Type:      "tool_result",
+		ToolUseID: call.id,
+		Content:   content,
+		Text:      strings.Join(texts, "\n"),
+	}, ""
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// newResourceServer returns a server with n text resources named
+// file:///<name>/<i>.txt, i with two digits
+func newResourceServer(name string, n int) *server.MCPServer {
+	mcpServer := server.NewMCPServer(name, "1.0.0", server.WithResourceCapabilities(false, false))
+	for i := 0; i < n; i++ {
+		uri := fmt.Sprintf("file:///%s/%02d.txt", name, i)
+		mcpServer.AddResource(
+			mcp.NewResource(uri, fmt.Sprintf("%02d.txt", i), mcp.WithResourceDescription("File "+fmt.Sprint(i))),
+			func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+				return []mcp.ResourceContents{mcp.TextResourceContents{
+					URI:      uri,
+					MIMEType: "text/plain",
+					Text:     "contents of " + uri,
+				}}, nil
+			},
+		)
+	}
+	return mcpServer
+}
+
+func TestResourceTool(t *testing.T) {
+	docs := newResourceServer("docs", 60)
+	docs.AddResourceTemplate(
+		mcp.NewResourceTemplate("file:///docs/{name}", "docs"),
+		func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+			return nil, nil
+		},
+	)
+	mcpClients := map[string]mcpclient.MCPClient{
+		"docs": startInProcessClient(t, "docs", docs),
+		"db":   startInProcessClient(t, "db", newResourceServer("db", 2)),
+		"fs":   startInProcessClient(t, "fs", newTestMCPServer()),
+	}
+
+	tool, ok := resourceTool(mcpClients)
+	if !ok {
+		t.Fatal("no read_resource tool")
+	}
+	if tool.Name != readResourceTool {
+		t.Errorf("name = %s, want %s", tool.Name, readResourceTool)
+	}
+
+	// Only servers offering resources can be chosen
+	enum := tool.InputSchema.Properties["server"].(map[string]interface{})["enum"]
+	if fmt.Sprint(enum) != "[db docs]" {
+		t.Errorf("server enum = %v, want [db docs]", enum)
+	}
+
+	// The servers are listed in order, up to the cap over all servers
+	var listed []string
+	for _, line := range strings.Split(tool.Description, "\n") {
+		if strings.HasPrefix(line, "- ") && !strings.HasSuffix(line, "(template)") {
+			listed = append(listed, line)
+		}
+	}
+	if len(listed) != maxListedResources {
+		t.Errorf("%d resources listed, want %d", len(listed), maxListedResources)
+	}
+	if want := "- db: file:///db/00.txt (File 0)"; len(listed) == 0 || listed[0] != want {
+		t.Errorf("first listed resource = %q, want %q", listed[0], want)
+	}
+	if !strings.Contains(tool.Description, "- docs: file:///docs/47.txt") ||
+		strings.Contains(tool.Description, "- docs: file:///docs/48.txt") {
+		t.Errorf("description = %q, want docs listed up to 47.txt", tool.Description)
+	}
+
+	// Templates are listed even past the cap
+	if !strings.Contains(tool.Description, "- docs: file:///docs/{name} (template)") {
+		t.Errorf("description = %q, want the template", tool.Description)
+	}
+}
+
+func TestResourceToolUnavailable(t *testing.T) {
+	tests := []struct {
+		name    string
+		servers map[string]*server.MCPServer
+	}{
+		{"no resources", map[string]*server.MCPServer{"fs": newTestMCPServer()}},
+		// A server of that name would have its tools clash with the tool
+		{"server named mcphost", map[string]*server.MCPServer{
+			"docs":         newResourceServer("docs", 1),
+			hostServerName: newTestMCPServer(),
+		}},
+	}
+	for _, tt := range tests {
+		mcpClients := make(map[string]mcpclient.MCPClient)
+		for name, mcpServer := range tt.servers {
+			mcpClients[name] = startInProcessClient(t, name, mcpServer)
+		}
+		if tool, ok := resourceTool(mcpClients); ok {
+			t.Errorf("%s: tool = %+v, want none", tt.name, tool)
+		}
+	}
+}
+
+func TestCallResourceTool(t *testing.T) {
+	client := startInProcessClient(t, "docs", newResourceServer("docs", 1))
+
+	result, errMsg := callResourceTool(context.Background(), pendingToolCall{
+		id:     "toolu_1",
+		args:   map[string]interface{}{"server": "docs", "uri": "file:///docs/00.txt"},
+		client: client,
+	})
+	if errMsg != "" || result.IsError {
+		t.Fatalf("result = %+v, %q", result, errMsg)
+	}
+	content, ok := result.Content.([]mcp.Content)
+	if !ok || len(content) != 1 {
+		t.Fatalf("content = %+v, want one embedded resource", result.Content)
+	}
+	if resource, ok := content[0].(mcp.EmbeddedResource); !ok || resource.Resource.(mcp.TextResourceContents).URI != "file:///docs/00.txt" {
+		t.Errorf("content = %+v, want the resource embedded", content[0])
+	}
+	if result.ToolUseID != "toolu_1" || result.Text != "contents of file:///docs/00.txt" {
+		t.Errorf("result = %+v", result)
+	}
+
+	result, errMsg = callResourceTool(context.Background(), pendingToolCall{
+		id:     "toolu_2",
+		args:   map[string]interface{}{"server": "docs", "uri": "file:///docs/missing.txt"},
+		client: client,
+	})
+	if !result.IsError || !strings.HasPrefix(errMsg, "Error reading resource file:///docs/missing.txt") {
+		t.Errorf("result = %+v, %q, want an error", result, errMsg)
+	}
+}
@@ -3,9 +3,11 @@ package cmd
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/charmbracelet/glamour/styles"
@@ -15,12 +17,13 @@ import (
 
 	"github.com/charmbracelet/glamour"
 	mcpclient "github.com/mark3labs/mcp-go/client"
-	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/spf13/cobra"
 	"github.com/vincent-pli/mcphost/pkg/history"
 	"github.com/vincent-pli/mcphost/pkg/llm"
 	"github.com/vincent-pli/mcphost/pkg/llm/anthropic"
 	"github.com/vincent-pli/mcphost/pkg/llm/azure"
+	"github.com/vincent-pli/mcphost/pkg/llm/bedrock"
+	"github.com/vincent-pli/mcphost/pkg/llm/gemini"
 	"github.com/vincent-pli/mcphost/pkg/llm/ollama"
 	"github.com/vincent-pli/mcphost/pkg/llm/openai"
 	"golang.org/x/term"
@@ -33,14 +36,39 @@ var (
 	modelFlag        string // New flag for model selection
 	openaiBaseURL    string // Base URL for OpenAI API
 	anthropicBaseURL string // Base URL for Anthropic API
+	geminiBaseURL    string // Base URL for Gemini API
+	bedrockBaseURL   string // Base URL for Bedrock runtime API
 	openaiAPIKey     string
 	anthropicAPIKey  string
+	geminiAPIKey     string
+	streamOutput     bool
+	promptFlag       string
+	stdinFlag        bool
+	quietMode        bool
+	contextBudget    int
+	maxSteps         int
+
+	// tokenBudget is the number of tokens the history may take up,
+	// resolved from --context-budget or the model's context window and
+	// the tools of the current turn
+	tokenBudget int
+
+	// nonInteractive is set when mcphost runs a single prompt from the
+	// --prompt flag or stdin instead of the interactive loop
+	nonInteractive bool
 )
 
 const (
 	initialBackoff = 1 * time.Second
 	maxBackoff     = 30 * time.Second
 	maxRetries     = 5 // Will reach close to max backoff
+
+	// maxRetryAfter is the longest Retry-After delay that is waited for
+	maxRetryAfter = 2 * time.Minute
+
+	// maxRepeatedToolCalls is how often the same tool call with the same
+	// arguments may be made in one turn
+	maxRepeatedToolCalls = 3
 )
 
 var rootCmd = &cobra.Command{
@@ -53,12 +81,18 @@ and provides streaming responses.
 Available models can be specified using the --model flag:
 - Anthropic Claude (default): anthropic:claude-3-5-sonnet-latest
 - OpenAI: openai:gpt-4
+- Google Gemini: gemini:gemini-2.0-flash
+- Claude on AWS Bedrock: bedrock:anthropic.claude-3-5-sonnet-20240620-v1:0
 - Ollama models: ollama:modelname
 
 Example:
   mcphost -m ollama:qwen2.5:3b
-  mcphost -m openai:gpt-4`,
+  mcphost -m openai:gpt-4
+  mcphost -p "What files are in /tmp?" --quiet`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		// Flags are valid at this point, errors are not usage errors
+		cmd.SilenceUsage = true
+		flagOptions = generationFlagOptions(cmd.Flags())
 		return runMCPHost()
 	},
 }
@@ -76,6 +110,8 @@ func init() {
 		StringVar(&configFile, "config", "", "config file (default is $HOME/mcp.json)")
 	rootCmd.PersistentFlags().
 		IntVar(&messageWindow, "message-window", 10, "number of messages to keep in context")
+	rootCmd.PersistentFlags().
+		IntVar(&contextBudget, "context-budget", 0, "number of tokens the history may use (default is derived from the model's context window)")
 	rootCmd.PersistentFlags().
 		StringVarP(&modelFlag, "model", "m", "anthropic:claude-3-5-sonnet-latest",
 			"model to use (format: provider:model, e.g. anthropic:claude-3-5-sonnet-latest or ollama:qwen2.5:3b)")
@@ -89,10 +125,34 @@ func init() {
 	flags.StringVar(&anthropicBaseURL, "anthropic-url", "", "base URL for Anthropic API (defaults to api.anthropic.com)")
 	flags.StringVar(&openaiAPIKey, "openai-api-key", "", "OpenAI API key")
 	flags.StringVar(&anthropicAPIKey, "anthropic-api-key", "", "Anthropic API key")
+	flags.StringVar(&geminiBaseURL, "gemini-url", "", "base URL for Gemini API (defaults to generativelanguage.googleapis.com)")
+	flags.StringVar(&geminiAPIKey, "gemini-api-key", "", "Gemini API key")
+	flags.StringVar(&bedrockBaseURL, "bedrock-url", "", "base URL for Bedrock runtime API (defaults to bedrock-runtime.<region>.amazonaws.com)")
+	flags.BoolVar(&streamOutput, "stream", true, "stream responses as they are generated")
+	flags.StringVarP(&promptFlag, "prompt", "p", "", "run a single prompt non-interactively and print the answer")
+	flags.BoolVar(&stdinFlag, "stdin", false, "append the text read from stdin to the prompt")
+	flags.BoolVarP(&quietMode, "quiet", "q", false, "hide spinners and informational logs")
+	flags.StringVar(&systemPromptFlag, "system-prompt", "", "system prompt to send with every request")
+	flags.StringVar(&systemPromptFile, "system-prompt-file", "", "file to read the system prompt from")
+	flags.Float64Var(&temperatureFlag, "temperature", 0, "sampling temperature (default is the provider's default)")
+	flags.IntVar(&maxTokensFlag, "max-tokens", llm.DefaultMaxTokens, "maximum number of tokens in a response")
+	flags.Float64Var(&topPFlag, "top-p", 0, "nucleus sampling probability (default is the provider's default)")
+	flags.StringSliceVar(&stopFlag, "stop", nil, "sequences that stop the response, may be repeated")
+	flags.IntVar(&thinkingBudgetFlag, "thinking-budget", 0, "enable extended thinking with this many tokens of reasoning (Anthropic models, at least 1024)")
+	flags.StringSliceVar(&rootFlags, "root", nil, "directory MCP servers may work in, may be repeated (default is the current working directory)")
+	flags.StringVar(&samplingModelFlag, "sampling-model", "", "model that answers sampling requests of MCP servers (default is the model of the conversation)")
+	flags.BoolVar(&resourceToolFlag, "resource-tool", false, "add a tool that lets the model read the resources of MCP servers")
+	flags.BoolVar(&showThinking, "show-thinking", false, "show the model's thinking in full instead of collapsed")
+	flags.IntVar(&maxSteps, "max-steps", 25, "maximum number of model calls in one turn, 0 for no limit")
+	flags.IntVar(&toolConcurrency, "tool-concurrency", 4, "maximum number of tool calls to run at the same time")
+	flags.BoolVar(&compactMode, "compact", false, "summarize older messages instead of dropping them when history exceeds the window")
+	flags.StringVar(&sessionsDir, "sessions-dir", "", "directory for saved sessions (default is $HOME/.mcphost/sessions)")
+	flags.StringVar(&resumeFlag, "resume", "", "resume a saved session by name, or the latest one if no name is given")
+	flags.Lookup("resume").NoOptDefVal = resumeLatest
 }
 
 // Add new function to create provider
-func createProvider(modelString string) (llm.Provider, error) {
+func createProvider(modelString string, config *MCPConfig) (llm.Provider, error) {
 	parts := strings.SplitN(modelString, ":", 2)
 	if len(parts) < 2 {
 		return nil, fmt.Errorf(
@@ -149,19 +209,120 @@ func createProvider(modelString string) (llm.Provider, error) {
 			)
 		}
 		return azure.NewProvider(apiKey, azureEndpoint, azureDeployment, apiVersion, model), nil
+
+	case "gemini":
+		apiKey := geminiAPIKey
+		if apiKey == "" {
+			apiKey = os.Getenv("GEMINI_API_KEY")
+		}
+		if apiKey == "" {
+			apiKey = os.Getenv("GOOGLE_API_KEY")
+		}
+
+		if apiKey == "" {
+			return nil, fmt.Errorf(
+				"Gemini API key not provided. Use --gemini-api-key flag or GEMINI_API_KEY environment variable",
+			)
+		}
+		return gemini.NewProvider(apiKey, geminiBaseURL, model), nil
+
+	case "bedrock":
+		creds, region, err := bedrock.LoadConfig()
+		if err != nil {
+			return nil, err
+		}
+		return bedrock.NewProvider(creds, region, bedrockBaseURL, model), nil
+
 	default:
+		if profile, ok := config.Providers[provider]; ok {
+			return createProfileProvider(provider, profile, model)
+		}
 		return nil, fmt.Errorf("unsupported provider: %s", provider)
 	}
 }
 
+// resolveTokenBudget returns the number of tokens the history may take up
+// for the model, leaving room for the tool definitions and the response
+func resolveTokenBudget(model string, tools []llm.Tool) int {
+	if contextBudget > 0 {
+		return contextBudget
+	}
+
+	window := llm.ContextWindow(model)
+	// Room for the response is kept free
+	budget := window - generationOptions.MaxTokensOrDefault() - llm.EstimateToolTokens(tools)
+	if budget < window/4 {
+		budget = window / 4
+	}
+	return budget
+}
+
+// updateTokenBudget resolves the token budget for the tools a turn is sent
+// with. Servers can change their tools between turns, which changes the
+// room left for the history.
+func updateTokenBudget(model string, tools []llm.Tool) {
+	budget := resolveTokenBudget(model, tools)
+	if budget != tokenBudget {
+		log.Debug("token budget resolved", "budget", budget, "tools", len(tools))
+	}
+	tokenBudget = budget
+}
+
+// pruneMessages keeps the most recent messages that fit both the message
+// window and the token budget. The system prompt is always kept.
 func pruneMessages(messages []history.HistoryMessage) []history.HistoryMessage {
-	if len(messages) <= messageWindow {
+	system, rest := splitSystemMessage(messages)
+	if len(rest) > messageWindow {
+		// Keep only the most recent messages based on window size
+		messages = append(system, dropOrphanedToolBlocks(rest[len(rest)-messageWindow:])...)
+	}
+
+	return fitTokenBudget(messages)
+}
+
+// fitTokenBudget drops the oldest messages until the history fits the
+// token budget. The system prompt and the newest message are always kept.
+func fitTokenBudget(messages []history.HistoryMessage) []history.HistoryMessage {
+	if tokenBudget <= 0 || len(messages) == 0 {
 		return messages
 	}
 
-	// Keep only the most recent messages based on window size
-	messages = messages[len(messages)-messageWindow:]
+	system, messages := splitSystemMessage(messages)
+	total := 0
+	for i := range system {
+		total += system[i].EstimateTokens()
+	}
+	start := len(messages)
+	for i := len(messages) - 1; i >= 0; i-- {
+		tokens := messages[i].EstimateTokens()
+		if total+tokens > tokenBudget && start < len(messages) {
+			break
+		}
+		total += tokens
+		start = i
+	}
+
+	if start == 0 {
+		return append(system, messages...)
+	}
+
+	if total > tokenBudget {
+		log.Warn("Latest message exceeds the token budget",
+			"estimated_tokens", total,
+			"budget", tokenBudget)
+	}
+	log.Debug("pruned history to token budget",
+		"dropped", start,
+		"kept", len(messages)-start,
+		"estimated_tokens", total,
+		"budget", tokenBudget)
+
+	return append(system, dropOrphanedToolBlocks(messages[start:])...)
+}
 
+// dropOrphanedToolBlocks removes tool_use blocks without a matching
+// tool_result and vice versa, which pruning can leave behind
+func dropOrphanedToolBlocks(messages []history.HistoryMessage) []history.HistoryMessage {
 	// Handle messages
 	toolUseIds := make(map[string]bool)
 	toolResultIds := make(map[string]bool)
@@ -233,244 +394,523 @@ func updateRenderer() error {
 	return err
 }
 
-// Method implementations for simpleMessage
+// runPrompt runs one turn of the conversation. The model is called in a
+// loop for as long as it asks for tools, up to --max-steps times.
 func runPrompt(
+	ctx context.Context,
 	provider llm.Provider,
-	mcpClients map[string]*mcpclient.StdioMCPClient,
+	mcpClients map[string]mcpclient.MCPClient,
 	tools []llm.Tool,
 	prompt string,
 	messages *[]history.HistoryMessage,
 ) error {
-	// Display the user's prompt if it's not empty (i.e., not a tool response)
+	// Display the user's prompt if it's not empty
 	if prompt != "" {
-		fmt.Printf("\n%s\n", promptStyle.Render("You: "+prompt))
+		attachments, err := promptAttachments(provider, prompt)
+		if err != nil {
+			if nonInteractive {
+				return err
+			}
+			fmt.Printf("\n%s\n\n", errorStyle.Render(err.Error()))
+			return nil
+		}
+		// Files attached with /attach are sent with this prompt
+		attachments = append(pendingAttachments, attachments...)
+		pendingAttachments = nil
+
+		if !nonInteractive {
+			fmt.Printf("\n%s\n", promptStyle.Render("You: "+prompt))
+			if len(attachments) > 0 {
+				fmt.Printf("%s\n", attachmentStyle.Render("📎 "+attachmentNames(attachments)))
+			}
+		}
 		*messages = append(
 			*messages,
 			history.HistoryMessage{
 				Role: "user",
-				Content: []history.ContentBlock{{
+				Content: append(attachments, history.ContentBlock{
 					Type: "text",
 					Text: prompt,
-				}},
+				}),
 			},
 		)
 	}
 
-	var message llm.Message
-	var err error
-	backoff := initialBackoff
-	retries := 0
-
-	// Convert MessageParam to llm.Message for provider
-	// Messages already implement llm.Message interface
-	llmMessages := make([]llm.Message, len(*messages))
-	for i := range *messages {
-		llmMessages[i] = &(*messages)[i]
-	}
+	var steps, totalInputTokens, totalOutputTokens int
+	var totalCacheCreationTokens, totalCacheReadTokens int
+	defer func() {
+		if totalInputTokens+totalOutputTokens > 0 {
+			keyvals := []interface{}{
+				"steps", steps,
+				"input_tokens", totalInputTokens,
+				"output_tokens", totalOutputTokens,
+				"total_tokens", totalInputTokens + totalOutputTokens,
+			}
+			// Cached input tokens are counted apart from input_tokens
+			if totalCacheCreationTokens+totalCacheReadTokens > 0 {
+				keyvals = append(keyvals,
+					"cache_creation_tokens", totalCacheCreationTokens,
+					"cache_read_tokens", totalCacheReadTokens)
+			}
+			log.Info("Usage statistics", keyvals...)
+		}
+	}()
 
-	for {
-		action := func() {
-			message, err = provider.CreateMessage(
-				context.Background(),
-				prompt,
-				llmMessages,
-				tools,
-			)
+	// callCounts counts identical tool calls over the whole turn
+	callCounts := make(toolCallCounts)
+	lastThinking = nil
+
+	for steps = 1; ; steps++ {
+		var message llm.Message
+		var err error
+		backoff := initialBackoff
+		retries := 0
+
+		// Tool results added during this turn can push the history over the
+		// budget, so only the part that fits is sent
+		contextMessages := fitTokenBudget(*messages)
+
+		// Convert MessageParam to llm.Message for provider
+		// Messages already implement llm.Message interface
+		llmMessages := make([]llm.Message, len(contextMessages))
+		for i := range contextMessages {
+			llmMessages[i] = &contextMessages[i]
 		}
-		_ = spinner.New().Title("Thinking...").Action(action).Run()
-		if err != nil {
-			// Check if it's an overloaded error
-			if strings.Contains(err.Error(), "overloaded_error") {
-				if retries >= maxRetries {
-					return fmt.Errorf(
-						"claude is currently overloaded. please wait a few minutes and try again",
+
+		// Text that was streamed to the terminal is not rendered again
+		streamed := false
+		// Thinking is shown while streaming, otherwise with the response
+		thinkingShown := false
+
+		for {
+			streamingProvider, ok := provider.(llm.StreamingProvider)
+			if ok && streamOutput && !nonInteractive {
+				message, streamed, err = streamMessage(
+					ctx,
+					streamingProvider,
+					"",
+					llmMessages,
+					tools,
+				)
+				thinkingShown = true
+			} else {
+				action := func() {
+					message, err = provider.CreateMessage(
+						ctx,
+						"",
+						llmMessages,
+						tools,
 					)
 				}
+				runWithSpinner("Thinking...", action)
+			}
+			if err != nil {
+				// The user pressed Ctrl+C, the request was cancelled
+				if ctx.Err() != nil {
+					return errTurnInterrupted
+				}
+
+				// Rate limits, overloaded servers and transient errors are
+				// retried with backoff, or after the delay the provider asked for
+				var providerErr *llm.ProviderError
+				if errors.As(err, &providerErr) && providerErr.Retryable() {
+					// A retry would print the answer again after the part
+					// that was already streamed
+					if streamed {
+						log.Warnf("llm response was interrupted, send the prompt again to retry: %s", err)
+						return nil
+					}
+
+					wait := max(backoff, providerErr.RetryAfter)
+					if retries >= maxRetries || wait > maxRetryAfter {
+						log.Warnf("llm request failed, giving up: %s", err)
+						if nonInteractive {
+							return err
+						}
+						// Not fatal, let user try again later
+						return nil
+					}
 
-				log.Warn("Claude is overloaded, backing off...",
-					"attempt", retries+1,
-					"backoff", backoff.String())
+					log.Warn("LLM request failed, backing off...",
+						"reason", providerErr.Kind,
+						"attempt", retries+1,
+						"backoff", wait.String())
 
-				time.Sleep(backoff)
-				backoff *= 2
-				if backoff > maxBackoff {
-					backoff = maxBackoff
+					select {
+					case <-time.After(wait):
+					case <-ctx.Done():
+						return errTurnInterrupted
+					}
+					backoff *= 2
+					if backoff > maxBackoff {
+						backoff = maxBackoff
+					}
+					retries++
+					continue
 				}
-				retries++
-				continue
-			}
-			// rate limit is not a fatal error, let user try again
-			if strings.Contains(err.Error(), "rate limit") {
-				log.Warnf("llm hit rate limit: %s", err)
-				return nil
-			}
 
-			// rate hit ,maximum context length is not a fatal error, let user try again
-			if strings.Contains(err.Error(), "maximum context length") {
-				log.Warnf("llm hit maximum context length: %s", err)
-				return nil
+				// maximum context length is not a fatal error, let user try again
+				if errors.Is(err, llm.ErrContextTooLong) {
+					log.Warnf("llm hit maximum context length: %s", err)
+					if nonInteractive {
+						return err
+					}
+					return nil
+				}
+				// Any other error is returned immediately
+				log.Errorf("Invoke LLM hit error, mcphost will shutdown, fix the error and try again: %s", err)
+				return err
 			}
-			// If it's not an overloaded error, return the error immediately
-			log.Errorf("Invoke LLM hit error, mcphost will shutdown, fix the error and try again: %s", err)
-			return err
+			// If we got here, the request succeeded
+			break
 		}
-		// If we got here, the request succeeded
-		break
-	}
 
-	var messageContent []history.ContentBlock
+		var messageContent []history.ContentBlock
 
-	toolResults := []history.ContentBlock{}
-	messageContent = []history.ContentBlock{}
+		messageContent = []history.ContentBlock{}
 
-	// Add text content
-	if message.GetContent() != "" {
-		// Handle the message response
-		if str, err := renderer.Render("\nAssistant: "); err == nil {
-			fmt.Print(str)
-		}
-		if err := updateRenderer(); err != nil {
-			return fmt.Errorf("error updating renderer: %v", err)
+		// Thinking is kept with the message, the provider needs it back
+		// when the model continues after tool results
+		if thinkingMsg, ok := message.(llm.ThinkingMessage); ok {
+			thinking := thinkingMsg.GetThinking()
+			messageContent = append(messageContent, thinkingContent(thinking)...)
+			if text := thinkingText(thinking); text != "" {
+				lastThinking = append(lastThinking, text)
+				if !thinkingShown && !nonInteractive {
+					printThinking(text)
+				}
+			}
 		}
-		str, err := renderer.Render(message.GetContent() + "\n")
-		if err != nil {
-			log.Error("Failed to render response", "error", err)
-			fmt.Print(message.GetContent() + "\n")
-		} else {
-			fmt.Print(str)
+
+		// Add text content
+		if message.GetContent() != "" && (streamed || nonInteractive) {
+			messageContent = append(messageContent, history.ContentBlock{
+				Type: "text",
+				Text: message.GetContent(),
+			})
+		} else if message.GetContent() != "" {
+			// Handle the message response
+			if str, err := renderer.Render("\nAssistant: "); err == nil {
+				fmt.Print(str)
+			}
+			if err := updateRenderer(); err != nil {
+				return fmt.Errorf("error updating renderer: %v", err)
+			}
+			str, err := renderer.Render(message.GetContent() + "\n")
+			if err != nil {
+				log.Error("Failed to render response", "error", err)
+				fmt.Print(message.GetContent() + "\n")
+			} else {
+				fmt.Print(str)
+			}
+			messageContent = append(messageContent, history.ContentBlock{
+				Type: "text",
+				Text: message.GetContent(),
+			})
 		}
-		messageContent = append(messageContent, history.ContentBlock{
-			Type: "text",
-			Text: message.GetContent(),
-		})
-	}
 
-	// Handle tool calls
-	for _, toolCall := range message.GetToolCalls() {
-		log.Info("🔧 Using tool", "name", toolCall.GetName())
+		// Handle tool calls. Each call gets a result at the same position,
+		// either an error from checking it here or the result of running it.
+		toolCalls := message.GetToolCalls()
+		toolResults := make([]history.ContentBlock, len(toolCalls))
+		var pending []pendingToolCall
+		var repeatedCall string
+		for i, toolCall := range toolCalls {
+			log.Info("🔧 Using tool", "name", toolCall.GetName())
+
+			input, _ := json.Marshal(toolCall.GetArguments())
+			toolUse := history.ContentBlock{
+				Type:  "tool_use",
+				ID:    toolCall.GetID(),
+				Name:  toolCall.GetName(),
+				Input: input,
+			}
+			if signed, ok := toolCall.(llm.SignedToolCall); ok {
+				toolUse.Signature = signed.GetSignature()
+			}
+			messageContent = append(messageContent, toolUse)
 
-		input, _ := json.Marshal(toolCall.GetArguments())
-		messageContent = append(messageContent, history.ContentBlock{
-			Type:  "tool_use",
-			ID:    toolCall.GetID(),
-			Name:  toolCall.GetName(),
-			Input: input,
-		})
+			// After Ctrl+C the remaining calls are not run, but still need a
+			// result for the history to stay valid
+			if ctx.Err() != nil {
+				toolResults[i] = toolCancelledResult(toolCall.GetID())
+				continue
+			}
+
+			parts := strings.Split(toolCall.GetName(), "__")
+			if len(parts) != 2 {
+				errMsg := fmt.Sprintf("Error: Invalid tool name format: %s", toolCall.GetName())
+				fmt.Fprintf(os.Stderr, "%s\n", errMsg)
+				toolResults[i] = toolErrorResult(toolCall.GetID(), errMsg)
+				continue
+			}
+
+			serverName, toolName := parts[0], parts[1]
+			mcpClient, ok := mcpClients[serverName]
+			missingServer := serverName
+			// The read_resource tool reads from the server in its arguments
+			if toolCall.GetName() == readResourceTool && resourceToolFlag {
+				missingServer, _ = toolCall.GetArguments()["server"].(string)
+				mcpClient, ok = mcpClients[missingServer]
+			}
+			if !ok {
+				errMsg := fmt.Sprintf("Error: Server not found: %s", missingServer)
+				fmt.Fprintf(os.Stderr, "%s\n", errMsg)
+				toolResults[i] = toolErrorResult(toolCall.GetID(), errMsg)
+				continue
+			}
+
+			// A model stuck in a loop keeps making the same call, which is
+			// not run again once it was made too often in this turn
+			if callCounts.add(toolCall.GetName(), input) {
+				repeatedCall = toolCall.GetName()
+				toolResults[i] = toolErrorResult(toolCall.GetID(), fmt.Sprintf(
+					"Tool call %s was skipped: the same call with the same arguments was already made %d times",
+					toolCall.GetName(),
+					maxRepeatedToolCalls,
+				))
+				continue
+			}
+
+			var toolArgs map[string]interface{}
+			if err := json.Unmarshal(input, &toolArgs); err != nil {
+				errMsg := fmt.Sprintf("Error parsing tool arguments: %v", err)
+				fmt.Fprintf(os.Stderr, "%s\n", errMsg)
+				toolResults[i] = toolErrorResult(toolCall.GetID(), errMsg)
+				continue
+			}
+
+			// Denied calls are reported to the model so the conversation
+			// still has a result for every tool_use
+			if approved, reason := approveToolCall(serverName, toolName, toolArgs); !approved {
+				fmt.Fprintf(os.Stderr, "%s\n", errorStyle.Render(reason))
+				toolResults[i] = toolErrorResult(toolCall.GetID(), reason)
+				continue
+			}
+
+			pending = append(pending, pendingToolCall{
+				index:      i,
+				id:         toolCall.GetID(),
+				serverName: serverName,
+				toolName:   toolName,
+				args:       toolArgs,
+				client:     mcpClient,
+			})
+		}
+
+		runToolCalls(ctx, pending, toolResults)
 
-		// Log usage statistics if available
 		inputTokens, outputTokens := message.GetUsage()
-		if inputTokens > 0 || outputTokens > 0 {
-			log.Info("Usage statistics",
-				"input_tokens", inputTokens,
-				"output_tokens", outputTokens,
-				"total_tokens", inputTokens+outputTokens)
-		}
-
-		parts := strings.Split(toolCall.GetName(), "__")
-		if len(parts) != 2 {
-			fmt.Printf(
-				"Error: Invalid tool name format: %s\n",
-				toolCall.GetName(),
-			)
-			continue
+		totalInputTokens += inputTokens
+		totalOutputTokens += outputTokens
+		if cacheMsg, ok := message.(llm.CacheUsageMessage); ok {
+			creation, read := cacheMsg.GetCacheUsage()
+			totalCacheCreationTokens += creation
+			totalCacheReadTokens += read
 		}
+		*messages = append(*messages, history.HistoryMessage{
+			Role:         message.GetRole(),
+			Content:      messageContent,
+			InputTokens:  inputTokens,
+			OutputTokens: outputTokens,
+		})
 
-		serverName, toolName := parts[0], parts[1]
-		mcpClient, ok := mcpClients[serverName]
-		if !ok {
-			fmt.Printf("Error: Server not found: %s\n", serverName)
-			continue
+		// Without tool calls the model has given its answer
+		if len(toolResults) == 0 {
+			break
 		}
 
-		var toolArgs map[string]interface{}
-		if err := json.Unmarshal(input, &toolArgs); err != nil {
-			fmt.Printf("Error parsing tool arguments: %v\n", err)
-			continue
+		*messages = append(*messages, history.HistoryMessage{
+			Role:    "user",
+			Content: toolResults,
+		})
+		if ctx.Err() != nil {
+			return errTurnInterrupted
 		}
 
-		var toolResultPtr *mcp.CallToolResult
-		action := func() {
-			req := mcp.CallToolRequest{}
-			req.Params.Name = toolName
-			req.Params.Arguments = toolArgs
-			toolResultPtr, err = mcpClient.CallTool(
-				context.Background(),
-				req,
-			)
+		if repeatedCall != "" {
+			return stopTurn(fmt.Sprintf(
+				"Stopped: %s was called repeatedly with the same arguments. Send another prompt to continue.",
+				repeatedCall,
+			))
 		}
-		_ = spinner.New().
-			Title(fmt.Sprintf("Running tool %s...", toolName)).
-			Action(action).
-			Run()
+		if stepLimitReached(steps, maxSteps) {
+			return stopTurn(fmt.Sprintf(
+				"Stopped after %d steps, the limit set by --max-steps. Send another prompt to continue.",
+				steps,
+			))
+		}
+	}
 
-		if err != nil {
-			errMsg := fmt.Sprintf(
-				"Error calling tool %s: %v",
-				toolName,
-				err,
-			)
-			fmt.Printf("\n%s\n", errorStyle.Render(errMsg))
+	if !nonInteractive {
+		fmt.Println() // Add spacing
+	}
+	return nil
+}
 
-			// Add error message as tool result
-			toolResults = append(toolResults, history.ContentBlock{
-				Type:      "tool_result",
-				ToolUseID: toolCall.GetID(),
-				Content: []history.ContentBlock{{
-					Type: "text",
-					Text: errMsg,
-				}},
-			})
-			continue
+// toolCallCounts counts the tool calls of a turn by name and arguments
+type toolCallCounts map[string]int
+
+// add counts a call and reports whether the same call was now made more
+// than maxRepeatedToolCalls times. The order of the arguments does not
+// matter.
+func (c toolCallCounts) add(name string, input json.RawMessage) bool {
+	signature := name + string(input)
+	var args interface{}
+	if err := json.Unmarshal(input, &args); err == nil {
+		// Marshaling sorts the keys of objects
+		normalized, _ := json.Marshal(args)
+		signature = name + string(normalized)
+	}
+	c[signature]++
+	return c[signature] > maxRepeatedToolCalls
+}
+
+// stepLimitReached reports whether a turn has to stop after steps model
+// calls. A limit of 0 means no limit.
+func stepLimitReached(steps, limit int) bool {
+	return limit > 0 && steps >= limit
+}
+
+// stopTurn reports that a limit stopped the turn. In non-interactive mode
+// this is an error.
+func stopTurn(reason string) error {
+	if nonInteractive {
+		return errors.New(reason)
+	}
+	fmt.Printf("\n%s\n\n", errorStyle.Render(reason))
+	return nil
+}
+
+// toolErrorResult creates a tool_result block reporting a failed tool call
+func toolErrorResult(toolUseID string, errMsg string) history.ContentBlock {
+	return history.ContentBlock{
+		Type:      "tool_result",
+		ToolUseID: toolUseID,
+		Content: []history.ContentBlock{{
+			Type: "text",
+			Text: errMsg,
+		}},
+		IsError: true,
+	}
+}
+
+// toolCancelledResult creates a tool_result block for a tool call that was
+// cancelled with Ctrl+C
+func toolCancelledResult(toolUseID string) history.ContentBlock {
+	return toolErrorResult(toolUseID, "Tool call was cancelled by the user")
+}
+
+// runWithSpinner runs action behind a spinner, or directly in quiet mode
+func runWithSpinner(title string, action func()) {
+	if quietMode {
+		action()
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		action()
+	}()
+
+	_ = spinner.New().
+		Title(title).
+		Output(os.Stderr).
+		Action(func() { <-done }).
+		Run()
+
+	// The spinner stops early on Ctrl+C, the action itself is stopped
+	// through the context of the turn
+	<-done
+}
+
+// streamMessage streams a response from the provider, printing text as it
+// arrives. It reports whether any text was printed.
+func streamMessage(
+	ctx context.Context,
+	provider llm.StreamingProvider,
+	prompt string,
+	messages []llm.Message,
+	tools []llm.Tool,
+) (llm.Message, bool, error) {
+	events, err := provider.StreamMessage(ctx, prompt, messages, tools)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Keep the spinner up until the first event arrives. Thinking that is
+	// collapsed is collected behind the spinner.
+	var event llm.StreamEvent
+	var ok bool
+	var thinking strings.Builder
+	runWithSpinner("Thinking...", func() {
+		for event, ok = <-events; ok && event.Type == llm.StreamEventThinking && !showThinking; event, ok = <-events {
+			thinking.WriteString(event.Text)
 		}
+	})
 
-		toolResult := *toolResultPtr
+	printed := false
+	thinkingPrinted := false
+	// endThinking finishes the thinking output before the response
+	endThinking := func() {
+		if thinkingPrinted {
+			fmt.Println()
+		} else if thinking.Len() > 0 {
+			printThinkingSummary(thinking.String())
+		}
+		thinkingPrinted = false
+		thinking.Reset()
+	}
 
-		if toolResult.Content != nil {
-			log.Debug("raw tool result content", "content", toolResult.Content)
+	for ; ok; event, ok = <-events {
+		if event.Type != llm.StreamEventThinking {
+			endThinking()
+		}
 
-			// Create the tool result block
-			resultBlock := history.ContentBlock{
-				Type:      "tool_result",
-				ToolUseID: toolCall.GetID(),
-				Content:   toolResult.Content,
+		switch event.Type {
+		case llm.StreamEventThinking:
+			if !showThinking {
+				thinking.WriteString(event.Text)
+				break
 			}
+			if !thinkingPrinted {
+				fmt.Printf("\n%s\n", thinkingStyle.Render("Thinking:"))
+				thinkingPrinted = true
+			}
+			fmt.Print(thinkingStyle.UnsetPaddingLeft().Render(event.Text))
 
-			// Extract text content
-			var resultText string
-			// Handle array content directly since we know it's []interface{}
-			for _, item := range toolResult.Content {
-				if content, ok := item.(mcp.TextContent); ok {
-					if content.Text != "" {
-						resultText += fmt.Sprintf("%v ", content.Text)
-					}
+		case llm.StreamEventText:
+			if !printed {
+				if str, err := renderer.Render("\nAssistant: "); err == nil {
+					fmt.Print(str)
 				}
+				printed = true
 			}
+			fmt.Print(event.Text)
 
-			resultBlock.Text = strings.TrimSpace(resultText)
-			log.Debug("created tool result block",
-				"block", resultBlock,
-				"tool_id", toolCall.GetID())
-
-			toolResults = append(toolResults, resultBlock)
-		}
-	}
+		case llm.StreamEventToolCall:
+			if event.ToolName != "" {
+				log.Debug("streaming tool call",
+					"id", event.ToolCallID,
+					"name", event.ToolName)
+			}
 
-	*messages = append(*messages, history.HistoryMessage{
-		Role:    message.GetRole(),
-		Content: messageContent,
-	})
+		case llm.StreamEventError:
+			if printed {
+				fmt.Println()
+			}
+			return nil, printed, event.Err
 
-	if len(toolResults) > 0 {
-		*messages = append(*messages, history.HistoryMessage{
-			Role:    "user",
-			Content: toolResults,
-		})
-		// Make another call to get Claude's response to the tool results
-		return runPrompt(provider, mcpClients, tools, "", messages)
+		case llm.StreamEventDone:
+			if printed {
+				fmt.Println()
+			}
+			return event.Message, printed, nil
+		}
 	}
 
-	fmt.Println() // Add spacing
-	return nil
+	return nil, printed, fmt.Errorf("stream ended without a response")
 }
 
 func runMCPHost() error {
@@ -479,26 +919,53 @@ func runMCPHost() error {
 		log.SetLevel(log.DebugLevel)
 		// Enable caller information for debug logs
 		log.SetReportCaller(true)
+	} else if quietMode {
+		log.SetLevel(log.WarnLevel)
+		log.SetReportCaller(false)
 	} else {
 		log.SetLevel(log.InfoLevel)
 		log.SetReportCaller(false)
 	}
 
+	oneShotPrompt, err := readOneShotPrompt()
+	if err != nil {
+		return err
+	}
+	nonInteractive = oneShotPrompt != ""
+
+	mcpConfig, err := loadMCPConfig()
+	if err != nil {
+		return fmt.Errorf("error loading MCP config: %v", err)
+	}
+
 	// Create the provider based on the model flag
-	provider, err := createProvider(modelFlag)
+	modelFlag = expandModelString(modelFlag, mcpConfig)
+	provider, err := createProvider(modelFlag, mcpConfig)
 	if err != nil {
 		return fmt.Errorf("error creating provider: %v", err)
 	}
 
+	generationOptions, err = resolveGenerationOptions(mcpConfig, modelFlag)
+	if err != nil {
+		return err
+	}
+	applyGenerationOptions(provider)
+
 	// Split the model flag and get just the model name
 	parts := strings.SplitN(modelFlag, ":", 2)
 	log.Info("Model loaded",
 		"provider", provider.Name(),
 		"model", parts[1])
 
-	mcpConfig, err := loadMCPConfig()
+	systemPrompt, err = resolveSystemPrompt(mcpConfig)
 	if err != nil {
-		return fmt.Errorf("error loading MCP config: %v", err)
+		return err
+	}
+	policyConfig = mcpConfig
+
+	roots, err = resolveRoots(mcpConfig)
+	if err != nil {
+		return err
 	}
 
 	mcpClients, err := createMCPClients(mcpConfig, debugMode)
@@ -506,27 +973,21 @@ func runMCPHost() error {
 		return fmt.Errorf("error creating MCP clients: %v", err)
 	}
 
-	defer func() {
-		log.Info("Shutting down MCP servers...")
-		for name, client := range mcpClients {
-			if err := client.Close(); err != nil {
-				log.Error("Failed to close server", "name", name, "error", err)
-			} else {
-				log.Info("Server closed", "name", name)
-			}
-		}
-	}()
+	closeServers = sync.OnceFunc(func() { closeMCPClients(mcpClients) })
+	defer closeServers()
 
 	for name := range mcpClients {
 		log.Info("Server connected", "name", name)
 	}
 
-	var allTools []llm.Tool
-	for serverName, mcpClient := range mcpClients {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		toolsResult, err := mcpClient.ListTools(ctx, mcp.ListToolsRequest{})
-		cancel()
+	catalog.setClients(mcpClients)
+	for serverName := range mcpClients {
+		// Servers offering only resources or prompts have no tools
+		if serverCapabilities[serverName].Tools == nil {
+			continue
+		}
 
+		count, err := catalog.loadServerTools(serverName)
 		if err != nil {
 			log.Error(
 				"Error fetching tools",
@@ -538,22 +999,36 @@ func runMCPHost() error {
 			continue
 		}
 
-		serverTools := mcpToolsToAnthropicTools(serverName, toolsResult.Tools)
-		allTools = append(allTools, serverTools...)
 		log.Info(
 			"Tools loaded",
 			"server",
 			serverName,
 			"count",
-			len(toolsResult.Tools),
+			count,
 		)
 	}
 
+	loadServerPrompts(mcpClients)
+
+	if resourceToolFlag && !catalog.loadResourceTool() {
+		log.Warn("No server offers resources, the read_resource tool is not added")
+	}
+
+	updateTokenBudget(parts[1], catalog.tools())
+
 	if err := updateRenderer(); err != nil {
 		return fmt.Errorf("error initializing renderer: %v", err)
 	}
 
-	messages := make([]history.HistoryMessage, 0)
+	messages, err := openSessions(mcpClients)
+	if err != nil {
+		return err
+	}
+	messages = withSystemPrompt(messages)
+
+	if nonInteractive {
+		return runOneShot(provider, mcpClients, catalog.tools(), oneShotPrompt, messages)
+	}
 
 	// Main interaction loop
 	for {
@@ -583,24 +1058,41 @@ func runMCPHost() error {
 			continue
 		}
 
-		// Handle slash commands
-		handled, err := handleSlashCommand(
-			prompt,
-			mcpConfig,
-			mcpClients,
-			messages,
-		)
-		if err != nil {
-			return err
-		}
-		if handled {
-			continue
+		// Prompts of the servers add their messages to the history, which
+		// are then sent instead of a typed prompt
+		if _, _, ok := findServerPrompt(prompt); ok {
+			if !handlePromptCommand(prompt, provider, mcpClients, &messages) {
+				continue
+			}
+			prompt = ""
+		} else {
+			// Handle slash commands
+			handled, err := handleSlashCommand(
+				prompt,
+				provider,
+				mcpConfig,
+				mcpClients,
+				&messages,
+			)
+			if err != nil {
+				return err
+			}
+			if handled {
+				continue
+			}
 		}
 
-		if len(messages) > 0 {
-			messages = pruneMessages(messages)
+		ctx, endTurn := beginTurn()
+		// Servers may have changed their tools since the last turn
+		tools := catalog.tools()
+		updateTokenBudget(parts[1], tools)
+		messages = compactOrPrune(ctx, provider, messages)
+		err = runPrompt(ctx, provider, mcpClients, tools, prompt, &messages)
+		endTurn()
+		if errors.Is(err, errTurnInterrupted) {
+			fmt.Println()
+			continue
 		}
-		err = runPrompt(provider, mcpClients, allTools, prompt, &messages)
 		if err != nil {
 			return err
 		}
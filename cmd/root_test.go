@@ -0,0 +1,271 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/vincent-pli/mcphost/pkg/history"
+	"github.com/vincent-pli/mcphost/pkg/llm"
+)
+
+// describeMessages writes each message as its role and the kinds of its
+// blocks, e.g. "assistant:text,tool_use"
+func describeMessages(messages []history.HistoryMessage) string {
+	var result []string
+	for _, message := range messages {
+		var kinds []string
+		for _, block := range message.Content {
+			kinds = append(kinds, block.Type)
+		}
+		result = append(result, message.Role+":"+strings.Join(kinds, ","))
+	}
+	return strings.Join(result, " ")
+}
+
+func toolConversation() []history.HistoryMessage {
+	return []history.HistoryMessage{
+		{Role: "system", Content: []history.ContentBlock{{Type: "text", Text: "You are a helpful assistant."}}},
+		{Role: "user", Content: []history.ContentBlock{{Type: "text", Text: "What is in data.csv?"}}},
+		{Role: "assistant", Content: []history.ContentBlock{
+			{Type: "tool_use", ID: "toolu_1", Name: "fs__read_file", Input: json.RawMessage(`{"path":"data.csv"}`)},
+		}},
+		{Role: "user", Content: []history.ContentBlock{
+			{Type: "tool_result", ToolUseID: "toolu_1", Content: strings.Repeat("a,b\n", 50)},
+		}},
+		{Role: "assistant", Content: []history.ContentBlock{{Type: "text", Text: "It has two columns, a and b."}}},
+		{Role: "user", Content: []history.ContentBlock{{Type: "text", Text: "Sum the first column."}}},
+	}
+}
+
+func TestFitTokenBudget(t *testing.T) {
+	saved := tokenBudget
+	defer func() { tokenBudget = saved }()
+
+	messages := toolConversation()
+	// tokens returns the tokens of the system prompt and of the newest
+	// messages, starting at index start
+	tokens := func(start int) int {
+		total := messages[0].EstimateTokens()
+		for i := start; i < len(messages); i++ {
+			total += messages[i].EstimateTokens()
+		}
+		return total
+	}
+
+	tests := []struct {
+		name   string
+		budget int
+		want   string
+	}{
+		{
+			name:   "no budget",
+			budget: 0,
+			want:   describeMessages(messages),
+		},
+		{
+			name:   "everything fits",
+			budget: tokens(1),
+			want:   describeMessages(messages),
+		},
+		{
+			name:   "oldest messages dropped",
+			budget: tokens(2),
+			want:   "system:text assistant:tool_use user:tool_result assistant:text user:text",
+		},
+		{
+			name:   "tool result without its call dropped",
+			budget: tokens(3),
+			want:   "system:text assistant:text user:text",
+		},
+		{
+			name:   "newest message kept over the budget",
+			budget: 1,
+			want:   "system:text user:text",
+		},
+	}
+	for _, tt := range tests {
+		tokenBudget = tt.budget
+		if got := describeMessages(fitTokenBudget(toolConversation())); got != tt.want {
+			t.Errorf("%s: messages = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDropOrphanedToolBlocks(t *testing.T) {
+	messages := []history.HistoryMessage{
+		{Role: "user", Content: []history.ContentBlock{
+			{Type: "tool_result", ToolUseID: "toolu_0", Content: "earlier result"},
+		}},
+		{Role: "assistant", Content: []history.ContentBlock{
+			{Type: "text", Text: "Reading both files."},
+			{Type: "tool_use", ID: "toolu_1", Name: "fs__read_file"},
+			{Type: "tool_use", ID: "toolu_2", Name: "fs__read_file"},
+		}},
+		{Role: "user", Content: []history.ContentBlock{
+			{Type: "tool_result", ToolUseID: "toolu_1", Content: "a,b"},
+		}},
+		{Role: "assistant", Content: []history.ContentBlock{
+			{Type: "tool_use", ID: "toolu_3", Name: "fs__stat"},
+		}},
+	}
+
+	got := dropOrphanedToolBlocks(messages)
+	want := "assistant:text,tool_use user:tool_result"
+	if describeMessages(got) != want {
+		t.Fatalf("messages = %s, want %s", describeMessages(got), want)
+	}
+	if got[0].Content[1].ID != "toolu_1" {
+		t.Errorf("kept tool call %s, want toolu_1", got[0].Content[1].ID)
+	}
+}
+
+// flakyProvider streams the text of its first response and then fails
+// with a transient error, later responses succeed
+type flakyProvider struct {
+	llm.Provider
+	firstText string
+	calls     int
+}
+
+func (p *flakyProvider) StreamMessage(
+	ctx context.Context,
+	prompt string,
+	messages []llm.Message,
+	tools []llm.Tool,
+) (<-chan llm.StreamEvent, error) {
+	p.calls++
+	events := make(chan llm.StreamEvent, 2)
+	if p.calls == 1 {
+		if p.firstText != "" {
+			events <- llm.StreamEvent{Type: llm.StreamEventText, Text: p.firstText}
+		}
+		events <- llm.StreamEvent{Type: llm.StreamEventError, Err: llm.NewIncompleteStreamError()}
+	} else {
+		events <- llm.StreamEvent{Type: llm.StreamEventDone, Message: &history.HistoryMessage{
+			Role:    "assistant",
+			Content: []history.ContentBlock{{Type: "text", Text: "The whole answer."}},
+		}}
+	}
+	close(events)
+	return events, nil
+}
+
+func TestRunPromptRetryAfterStreamedText(t *testing.T) {
+	savedStream, savedQuiet, savedNonInteractive := streamOutput, quietMode, nonInteractive
+	defer func() { streamOutput, quietMode, nonInteractive = savedStream, savedQuiet, savedNonInteractive }()
+	streamOutput, quietMode, nonInteractive = true, true, false
+	if err := updateRenderer(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		firstText    string
+		wantCalls    int
+		wantMessages int
+	}{
+		// Nothing was shown yet, so the request is sent again
+		{name: "nothing streamed", wantCalls: 2, wantMessages: 2},
+		// A retry would show the answer a second time
+		{name: "text streamed", firstText: "The whole", wantCalls: 1, wantMessages: 1},
+	}
+	for _, tt := range tests {
+		provider := &flakyProvider{firstText: tt.firstText}
+		var messages []history.HistoryMessage
+		if err := runPrompt(context.Background(), provider, nil, nil, "Answer", &messages); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		if provider.calls != tt.wantCalls || len(messages) != tt.wantMessages {
+			t.Errorf("%s: %d requests and %d messages, want %d and %d",
+				tt.name, provider.calls, len(messages), tt.wantCalls, tt.wantMessages)
+		}
+	}
+}
+
+func TestToolCallCounts(t *testing.T) {
+	counts := make(toolCallCounts)
+	calls := []struct {
+		name     string
+		input    string
+		repeated bool
+	}{
+		{"fs__read", `{"path":"a.txt","lines":10}`, false},
+		{"fs__read", `{"lines":10,"path":"a.txt"}`, false},
+		{"fs__read", `{"path": "b.txt", "lines": 10}`, false},
+		{"fs__list", `{"path":"a.txt","lines":10}`, false},
+		{"fs__read", `{ "lines": 10, "path": "a.txt" }`, false},
+		// The fourth identical call is one too many
+		{"fs__read", `{"path":"a.txt","lines":10}`, true},
+		{"fs__read", `{"path":"b.txt","lines":10}`, false},
+		{"fs__list", `{"lines":10,"path":"a.txt"}`, false},
+	}
+	for i, call := range calls {
+		if got := counts.add(call.name, json.RawMessage(call.input)); got != call.repeated {
+			t.Errorf("call %d: add(%s, %s) = %v, want %v", i, call.name, call.input, got, call.repeated)
+		}
+	}
+}
+
+func TestStepLimitReached(t *testing.T) {
+	tests := []struct {
+		steps, limit int
+		want         bool
+	}{
+		{1, 25, false},
+		{24, 25, false},
+		{25, 25, true},
+		{1, 1, true},
+		{1, 0, false},
+		{1000, 0, false},
+	}
+	for _, tt := range tests {
+		if got := stepLimitReached(tt.steps, tt.limit); got != tt.want {
+			t.Errorf("stepLimitReached(%d, %d) = %v, want %v", tt.steps, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestUpdateTokenBudget(t *testing.T) {
+	savedBudget, savedContext, savedOptions := tokenBudget, contextBudget, generationOptions
+	defer func() { tokenBudget, contextBudget, generationOptions = savedBudget, savedContext, savedOptions }()
+	generationOptions = llm.GenerationOptions{}
+
+	tools := []llm.Tool{{
+		Name:        "fs__read",
+		Description: strings.Repeat("Read a file. ", 100),
+		InputSchema: llm.Schema{Type: "object"},
+	}}
+	noTools := llm.ContextWindow("qwen2.5:3b") - llm.DefaultMaxTokens
+
+	// Budgets follow the tools of each turn
+	updateTokenBudget("qwen2.5:3b", nil)
+	if tokenBudget != noTools {
+		t.Errorf("budget without tools = %d, want %d", tokenBudget, noTools)
+	}
+	updateTokenBudget("qwen2.5:3b", tools)
+	if want := noTools - llm.EstimateToolTokens(tools); tokenBudget != want {
+		t.Errorf("budget with tools = %d, want %d", tokenBudget, want)
+	}
+	updateTokenBudget("qwen2.5:3b", nil)
+	if tokenBudget != noTools {
+		t.Errorf("budget after the tools were removed = %d, want %d", tokenBudget, noTools)
+	}
+
+	// A quarter of the window is always left for the history
+	for len(tools) < 100 {
+		tools = append(tools, tools[0])
+	}
+	updateTokenBudget("qwen2.5:3b", tools)
+	if want := llm.ContextWindow("qwen2.5:3b") / 4; tokenBudget != want {
+		t.Errorf("budget with many tools = %d, want %d", tokenBudget, want)
+	}
+
+	// --context-budget applies as given
+	contextBudget = 5000
+	updateTokenBudget("qwen2.5:3b", tools)
+	if tokenBudget != 5000 {
+		t.Errorf("budget = %d, want the --context-budget of 5000", tokenBudget)
+	}
+}
@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var (
+	// rootFlags are the directories given with --root
+	rootFlags []string
+
+	// roots are the directories the servers are told they may work in
+	roots   []mcp.Root
+	rootsMu sync.Mutex
+)
+
+// resolveRoots returns the roots from the --root flag, falling back to
+// roots in the config and then to the current working directory
+func resolveRoots(config *MCPConfig) ([]mcp.Root, error) {
+	paths := rootFlags
+	if len(paths) == 0 {
+		paths = config.Roots
+	}
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	resolved := make([]mcp.Root, 0, len(paths))
+	for _, path := range paths {
+		root, err := newRoot(path)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, root)
+	}
+	return resolved, nil
+}
+
+// newRoot converts the path of an existing directory to a root named after
+// the directory
+func newRoot(path string) (mcp.Root, error) {
+	dir, err := rootPath(path)
+	if err != nil {
+		return mcp.Root{}, err
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return mcp.Root{}, fmt.Errorf("error reading root %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return mcp.Root{}, fmt.Errorf("root %s is not a directory", path)
+	}
+
+	return mcp.Root{URI: rootURI(dir), Name: filepath.Base(dir)}, nil
+}
+
+// rootPath returns the absolute path of a root, with ~ expanded
+func rootPath(path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(homeDir, path[1:])
+		}
+	}
+
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("error resolving root %s: %w", path, err)
+	}
+	return dir, nil
+}
+
+func rootURI(dir string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(dir)}).String()
+}
+
+// rootsHandler answers the roots/list requests of the servers
+type rootsHandler struct{}
+
+func (rootsHandler) ListRoots(
+	ctx context.Context,
+	request mcp.ListRootsRequest,
+) (*mcp.ListRootsResult, error) {
+	rootsMu.Lock()
+	defer rootsMu.Unlock()
+
+	result := &mcp.ListRootsResult{Roots: make([]mcp.Root, len(roots))}
+	copy(result.Roots, roots)
+	return result, nil
+}
+
+// handleRootsCommand lists the roots, or adds or removes one, e.g.
+// "/roots add ~/src/app". The servers are notified of changes.
+func handleRootsCommand(
+	args []string,
+	mcpConfig *MCPConfig,
+	mcpClients map[string]mcpclient.MCPClient,
+) {
+	if len(args) == 0 {
+		showRoots()
+		return
+	}
+	if len(args) < 2 || (args[0] != "add" && args[0] != "remove") {
+		fmt.Printf("\n%s\n\n", errorStyle.Render("Usage: /roots [add|remove <path>]"))
+		return
+	}
+
+	path := strings.Join(args[1:], " ")
+	var err error
+	if args[0] == "add" {
+		err = addRoot(path)
+	} else {
+		err = removeRoot(path)
+	}
+	if err != nil {
+		fmt.Printf("\n%s\n\n", errorStyle.Render(err.Error()))
+		return
+	}
+
+	notifyRootsChanged(mcpConfig, mcpClients)
+	showRoots()
+}
+
+func addRoot(path string) error {
+	root, err := newRoot(path)
+	if err != nil {
+		return err
+	}
+
+	rootsMu.Lock()
+	defer rootsMu.Unlock()
+	for _, r := range roots {
+		if r.URI == root.URI {
+			return fmt.Errorf("%s is already a root", path)
+		}
+	}
+	roots = append(roots, root)
+	return nil
+}
+
+// removeRoot removes the root of a path. The directory does not have to
+// exist anymore.
+func removeRoot(path string) error {
+	dir, err := rootPath(path)
+	if err != nil {
+		return err
+	}
+	uri := rootURI(dir)
+
+	rootsMu.Lock()
+	defer rootsMu.Unlock()
+	for i, r := range roots {
+		if r.URI == uri {
+			roots = append(roots[:i:i], roots[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not a root", path)
+}
+
+// notifyRootsChanged tells the servers to list the roots again. Clients of
+// SSE servers do not offer roots, so those servers are not notified.
+func notifyRootsChanged(mcpConfig *MCPConfig, mcpClients map[string]mcpclient.MCPClient) {
+	for name, client := range mcpClients {
+		if mcpConfig.MCPServers[name].transport() == transportSSE {
+			continue
+		}
+
+		notifier, ok := client.(interface {
+			RootListChanges(ctx context.Context) error
+		})
+		if !ok {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := notifier.RootListChanges(ctx); err != nil {
+			log.Warn("Failed to notify server of changed roots", "name", name, "error", err)
+		}
+		cancel()
+	}
+}
+
+func showRoots() {
+	rootsMu.Lock()
+	defer rootsMu.Unlock()
+
+	if len(roots) == 0 {
+		fmt.Printf("\n%s\n\n", responseStyle.Render("No roots. Usage: /roots add <path>"))
+		return
+	}
+
+	lines := []string{"Roots shared with the servers:"}
+	for _, root := range roots {
+		lines = append(lines, "  "+root.URI)
+	}
+	fmt.Printf("\n%s\n\n", responseStyle.Render(strings.Join(lines, "\n")))
+}
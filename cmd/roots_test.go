@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestNewRoot(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := filepath.Join(home, "my app")
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(home, "notes.md")
+	if err := os.WriteFile(file, []byte("# Notes"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		wantName string
+		wantErr  string
+	}{
+		{name: "directory", path: dir, wantName: "my app"},
+		{name: "home", path: "~/my app", wantName: "my app"},
+		{name: "unclean path", path: filepath.Join(dir, "..", "my app"), wantName: "my app"},
+		{name: "file", path: file, wantErr: "is not a directory"},
+		{name: "missing", path: filepath.Join(home, "missing"), wantErr: "error reading root"},
+	}
+	for _, tt := range tests {
+		root, err := newRoot(tt.path)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		wantURI := "file://" + strings.ReplaceAll(filepath.ToSlash(dir), " ", "%20")
+		if root.URI != wantURI || root.Name != tt.wantName {
+			t.Errorf("%s: root = %+v, want URI %s and name %s", tt.name, root, wantURI, tt.wantName)
+		}
+	}
+}
+
+func TestAddAndRemoveRoot(t *testing.T) {
+	rootsMu.Lock()
+	saved := roots
+	roots = nil
+	rootsMu.Unlock()
+	defer func() {
+		rootsMu.Lock()
+		roots = saved
+		rootsMu.Unlock()
+	}()
+
+	base := t.TempDir()
+	app, lib := filepath.Join(base, "app"), filepath.Join(base, "lib")
+	for _, dir := range []string{app, lib} {
+		if err := os.Mkdir(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	listRoots := func() []mcp.Root {
+		result, err := rootsHandler{}.ListRoots(context.Background(), mcp.ListRootsRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.Roots
+	}
+
+	for _, dir := range []string{app, lib} {
+		if err := addRoot(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := addRoot(app + "/"); err == nil || !strings.Contains(err.Error(), "already a root") {
+		t.Errorf("adding a root twice: error = %v", err)
+	}
+	if got := listRoots(); len(got) != 2 || got[0].Name != "app" || got[1].Name != "lib" {
+		t.Fatalf("roots = %+v, want app and lib", got)
+	}
+
+	// A root can be removed after its directory is gone
+	if err := os.Remove(app); err != nil {
+		t.Fatal(err)
+	}
+	if err := removeRoot(app); err != nil {
+		t.Fatal(err)
+	}
+	if err := removeRoot(app); err == nil || !strings.Contains(err.Error(), "is not a root") {
+		t.Errorf("removing a root twice: error = %v", err)
+	}
+	if got := listRoots(); len(got) != 1 || got[0].Name != "lib" {
+		t.Errorf("roots = %+v, want lib", got)
+	}
+}
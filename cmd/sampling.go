@@ -0,0 +1,281 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/log"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/vincent-pli/mcphost/pkg/history"
+	"github.com/vincent-pli/mcphost/pkg/llm"
+)
+
+// samplingTimeout limits how long answering a sampling request may take
+const samplingTimeout = 2 * time.Minute
+
+var (
+	// samplingModelFlag is the model that answers sampling requests of the
+	// servers, instead of the model of the conversation
+	samplingModelFlag string
+
+	// samplingAllowed holds the servers the user allowed to sample for the
+	// rest of the run
+	samplingAllowed = make(map[string]bool)
+
+	// samplingMu lets one sampling request at a time ask for approval
+	samplingMu sync.Mutex
+)
+
+// samplingHandler answers the sampling/createMessage requests of a server
+// with a model the user configured
+type samplingHandler struct {
+	serverName string
+	config     *MCPConfig
+}
+
+// samplingModel returns the model answering sampling requests: the one of
+// --sampling-model, the config's samplingModel, or the conversation's
+func samplingModel(config *MCPConfig) string {
+	model := modelFlag
+	if samplingModelFlag != "" {
+		model = samplingModelFlag
+	} else if config.SamplingModel != "" {
+		model = config.SamplingModel
+	}
+	return expandModelString(model, config)
+}
+
+// samplingTemperatureKey holds the temperature of a sampling request in its
+// context, nil if the server did not set one
+type samplingTemperatureKey struct{}
+
+// samplingTemperature returns the temperature the server set for a request.
+// CreateMessageParams cannot tell a temperature of 0 from none, so the one
+// samplingTransport read from the raw parameters is preferred.
+func samplingTemperature(ctx context.Context, request mcp.CreateMessageRequest) *float64 {
+	if temperature, ok := ctx.Value(samplingTemperatureKey{}).(*float64); ok {
+		return temperature
+	}
+	if request.Temperature > 0 {
+		temperature := request.Temperature
+		return &temperature
+	}
+	return nil
+}
+
+// samplingTransport passes the temperature of the sampling requests of a
+// server to the handler in their context
+type samplingTransport struct {
+	transport.BidirectionalInterface
+}
+
+func (t samplingTransport) SetRequestHandler(handler transport.RequestHandler) {
+	t.BidirectionalInterface.SetRequestHandler(func(
+		ctx context.Context,
+		request transport.JSONRPCRequest,
+	) (*transport.JSONRPCResponse, error) {
+		if request.Method == string(mcp.MethodSamplingCreateMessage) {
+			var params struct {
+				Temperature *float64 `json:"temperature"`
+			}
+			if data, err := json.Marshal(request.Params); err == nil {
+				json.Unmarshal(data, &params)
+			}
+			ctx = context.WithValue(ctx, samplingTemperatureKey{}, params.Temperature)
+		}
+		return handler(ctx, request)
+	})
+}
+
+// SetProtocolVersion passes the negotiated protocol version to HTTP
+// transports, which send it with every request
+func (t samplingTransport) SetProtocolVersion(version string) {
+	if conn, ok := t.BidirectionalInterface.(transport.HTTPConnection); ok {
+		conn.SetProtocolVersion(version)
+	}
+}
+
+func (h *samplingHandler) CreateMessage(
+	ctx context.Context,
+	request mcp.CreateMessageRequest,
+) (*mcp.CreateMessageResult, error) {
+	modelString := samplingModel(h.config)
+	if err := h.approve(modelString, request.CreateMessageParams); err != nil {
+		log.Warn("Sampling request refused", "server", h.serverName, "reason", err)
+		return nil, err
+	}
+
+	provider, err := createProvider(modelString, h.config)
+	if err != nil {
+		return nil, fmt.Errorf("error creating provider for sampling: %w", err)
+	}
+
+	// The server decides the length and randomness of its response, the
+	// config and flags apply to everything else
+	options, err := resolveGenerationOptions(h.config, modelString)
+	if err != nil {
+		return nil, err
+	}
+	options.ThinkingBudget = nil
+	if request.MaxTokens > 0 {
+		options.MaxTokens = &request.MaxTokens
+	}
+	if temperature := samplingTemperature(ctx, request); temperature != nil {
+		options.Temperature = temperature
+	}
+	if len(request.StopSequences) > 0 {
+		options.Stop = request.StopSequences
+	}
+	if configurable, ok := provider.(llm.ConfigurableProvider); ok {
+		configurable.SetGenerationOptions(options)
+	}
+
+	var messages []history.HistoryMessage
+	if request.SystemPrompt != "" {
+		messages = append(messages, history.HistoryMessage{
+			Role:    "system",
+			Content: []history.ContentBlock{{Type: "text", Text: request.SystemPrompt}},
+		})
+	}
+	for _, message := range request.Messages {
+		content, ok := message.Content.(mcp.Content)
+		if !ok {
+			return nil, fmt.Errorf("unsupported content in sampling message: %T", message.Content)
+		}
+		messages = append(messages, history.HistoryMessage{
+			Role:    string(message.Role),
+			Content: []history.ContentBlock{promptContentBlock(provider, modelString, content)},
+		})
+	}
+
+	llmMessages := make([]llm.Message, len(messages))
+	for i := range messages {
+		llmMessages[i] = &messages[i]
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, samplingTimeout)
+	defer cancel()
+	response, err := provider.CreateMessage(ctx, "", llmMessages, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error sampling with %s: %w", modelString, err)
+	}
+
+	inputTokens, outputTokens := response.GetUsage()
+	log.Info("Sampling request answered",
+		"server", h.serverName,
+		"model", modelString,
+		"input_tokens", inputTokens,
+		"output_tokens", outputTokens)
+
+	_, model, _ := strings.Cut(modelString, ":")
+	return &mcp.CreateMessageResult{
+		SamplingMessage: mcp.SamplingMessage{
+			Role:    mcp.RoleAssistant,
+			Content: mcp.NewTextContent(response.GetContent()),
+		},
+		Model:      model,
+		StopReason: "endTurn",
+	}, nil
+}
+
+// approve asks the user whether the server may sample, unless the user
+// already allowed the server for the rest of the run
+func (h *samplingHandler) approve(modelString string, params mcp.CreateMessageParams) error {
+	samplingMu.Lock()
+	defer samplingMu.Unlock()
+
+	if samplingAllowed[h.serverName] {
+		return nil
+	}
+	if nonInteractive {
+		return fmt.Errorf(
+			"sampling request of %s requires approval, which is not possible in non-interactive mode",
+			h.serverName,
+		)
+	}
+
+	resume := pauseToolProgress()
+	defer resume()
+
+	choice, err := askSamplingApproval(h.serverName, modelString, params)
+	if errors.Is(err, huh.ErrUserAborted) {
+		return fmt.Errorf("sampling request of %s was cancelled by the user", h.serverName)
+	}
+	if err != nil {
+		return fmt.Errorf("sampling request of %s was not approved: %w", h.serverName, err)
+	}
+
+	switch choice {
+	case "always":
+		samplingAllowed[h.serverName] = true
+		return nil
+	case "approve":
+		return nil
+	default:
+		return fmt.Errorf("sampling request of %s was denied by the user", h.serverName)
+	}
+}
+
+// askSamplingApproval shows a sampling request and asks whether to send it
+// to the model
+func askSamplingApproval(serverName, modelString string, params mcp.CreateMessageParams) (string, error) {
+	var markdown strings.Builder
+	markdown.WriteString(fmt.Sprintf("**Sampling request from** `%s`\n\n", serverName))
+	markdown.WriteString(fmt.Sprintf("Model: `%s`, max tokens: %d\n\n", modelString, params.MaxTokens))
+	if params.SystemPrompt != "" {
+		markdown.WriteString("**System:** " + truncateSamplingText(params.SystemPrompt) + "\n\n")
+	}
+	for _, message := range params.Messages {
+		text := "[non-text content]"
+		if content, ok := message.Content.(mcp.TextContent); ok {
+			text = truncateSamplingText(content.Text)
+		}
+		markdown.WriteString(fmt.Sprintf("**%s:** %s\n\n", message.Role, text))
+	}
+
+	if err := updateRenderer(); err == nil {
+		if rendered, err := renderer.Render(markdown.String()); err == nil {
+			fmt.Print(rendered)
+		} else {
+			fmt.Print(markdown.String())
+		}
+	} else {
+		fmt.Print(markdown.String())
+	}
+
+	var choice string
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Title("Send this request to the model?").
+				Options(
+					huh.NewOption("Approve", "approve"),
+					huh.NewOption("Deny", "deny"),
+					huh.NewOption("Always allow "+serverName, "always"),
+				).
+				Value(&choice),
+		),
+	).WithWidth(getTerminalWidth()).WithTheme(huh.ThemeCharm())
+
+	if err := form.Run(); err != nil {
+		return "", err
+	}
+	return choice, nil
+}
+
+// truncateSamplingText shortens long texts of sampling requests for the
+// approval prompt
+func truncateSamplingText(text string) string {
+	const maxLength = 500
+	if runes := []rune(text); len(runes) > maxLength {
+		return string(runes[:maxLength]) + "..."
+	}
+	return text
+}
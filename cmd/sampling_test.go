@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestSamplingModel(t *testing.T) {
+	savedModel, savedSampling := modelFlag, samplingModelFlag
+	defer func() { modelFlag, samplingModelFlag = savedModel, savedSampling }()
+
+	config := &MCPConfig{Providers: map[string]ProviderConfig{
+		"groq": {BaseURL: "https://api.groq.com/openai/v1", DefaultModel: "llama-3.1-8b-instant"},
+	}}
+	tests := []struct {
+		name          string
+		model         string
+		samplingFlag  string
+		samplingModel string
+		want          string
+	}{
+		{"conversation model", "groq", "", "", "groq:llama-3.1-8b-instant"},
+		{"config", "anthropic:claude-3-5-sonnet-latest", "", "groq:", "groq:llama-3.1-8b-instant"},
+		{"flag over config", "anthropic:claude-3-5-sonnet-latest", "groq", "ollama:qwen2.5:3b", "groq:llama-3.1-8b-instant"},
+		{"full model string", "groq", "ollama:qwen2.5:3b", "", "ollama:qwen2.5:3b"},
+	}
+	for _, tt := range tests {
+		modelFlag, samplingModelFlag = tt.model, tt.samplingFlag
+		config.SamplingModel = tt.samplingModel
+		if got := samplingModel(config); got != tt.want {
+			t.Errorf("%s: samplingModel = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// requestTransport keeps the request handler it is given
+type requestTransport struct {
+	transport.Interface
+	handler transport.RequestHandler
+}
+
+func (t *requestTransport) SetRequestHandler(handler transport.RequestHandler) {
+	t.handler = handler
+}
+
+func TestSamplingTemperature(t *testing.T) {
+	inner := &requestTransport{}
+	samplingTransport{inner}.SetRequestHandler(func(
+		ctx context.Context,
+		request transport.JSONRPCRequest,
+	) (*transport.JSONRPCResponse, error) {
+		// Decode the parameters the way the client does
+		var params mcp.CreateMessageParams
+		data, _ := json.Marshal(request.Params)
+		if err := json.Unmarshal(data, &params); err != nil {
+			return nil, err
+		}
+		temperature := samplingTemperature(ctx, mcp.CreateMessageRequest{CreateMessageParams: params})
+		result, _ := json.Marshal(temperature)
+		return &transport.JSONRPCResponse{Result: result}, nil
+	})
+
+	tests := []struct {
+		name   string
+		params string
+		want   string
+	}{
+		{"unset", `{"maxTokens": 100}`, "null"},
+		{"zero", `{"maxTokens": 100, "temperature": 0}`, "0"},
+		{"set", `{"maxTokens": 100, "temperature": 0.7}`, "0.7"},
+	}
+	for _, tt := range tests {
+		response, err := inner.handler(context.Background(), transport.JSONRPCRequest{
+			Method: string(mcp.MethodSamplingCreateMessage),
+			Params: json.RawMessage(tt.params),
+		})
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if string(response.Result) != tt.want {
+			t.Errorf("%s: temperature = %s, want %s", tt.name, response.Result, tt.want)
+		}
+	}
+
+	// Without the raw parameters only a positive temperature counts as set
+	if temperature := samplingTemperature(context.Background(), mcp.CreateMessageRequest{}); temperature != nil {
+		t.Errorf("temperature = %v, want none", *temperature)
+	}
+}
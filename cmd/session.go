@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/vincent-pli/mcphost/pkg/history"
+	"github.com/vincent-pli/mcphost/pkg/session"
+)
+
+// resumeLatest is the --resume value used when no session name is given
+const resumeLatest = "latest"
+
+var (
+	sessionsDir string
+	resumeFlag  string
+
+	// sessionStore and currentSession back the /save, /load and /sessions
+	// commands. currentSession holds the metadata of the running conversation.
+	sessionStore   *session.Store
+	currentSession *session.Session
+)
+
+// openSessions opens the session store and returns the messages of the
+// session to resume, if --resume was given
+func openSessions(
+	mcpClients map[string]mcpclient.MCPClient,
+) ([]history.HistoryMessage, error) {
+	var err error
+	sessionStore, err = session.NewStore(sessionsDir)
+	if err != nil {
+		return nil, fmt.Errorf("error opening session store: %v", err)
+	}
+
+	currentSession = &session.Session{
+		Model:   modelFlag,
+		Servers: serverNames(mcpClients),
+	}
+
+	if resumeFlag == "" {
+		return make([]history.HistoryMessage, 0), nil
+	}
+
+	var resumed *session.Session
+	if resumeFlag == resumeLatest {
+		resumed, err = sessionStore.Latest()
+	} else {
+		resumed, err = sessionStore.Load(resumeFlag)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error resuming session: %v", err)
+	}
+
+	warnSessionMismatch(resumed)
+	log.Info("Session resumed",
+		"name", resumed.Name,
+		"messages", len(resumed.Messages))
+
+	currentSession.Name = resumed.Name
+	currentSession.CreatedAt = resumed.CreatedAt
+	return resumed.Messages, nil
+}
+
+func serverNames(mcpClients map[string]mcpclient.MCPClient) []string {
+	names := make([]string, 0, len(mcpClients))
+	for name := range mcpClients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// warnSessionMismatch warns when a session was held with a different model
+// or with servers that are not connected now
+func warnSessionMismatch(s *session.Session) {
+	if s.Model != "" && s.Model != currentSession.Model {
+		log.Warn("Session was created with a different model",
+			"session_model", s.Model,
+			"model", currentSession.Model)
+	}
+
+	connected := make(map[string]bool)
+	for _, name := range currentSession.Servers {
+		connected[name] = true
+	}
+	var missing []string
+	for _, name := range s.Servers {
+		if !connected[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		log.Warn("Session used servers that are not connected",
+			"servers", strings.Join(missing, ", "))
+	}
+}
+
+func handleSaveCommand(args []string, messages []history.HistoryMessage) {
+	saved := *currentSession
+	if len(args) > 0 && args[0] != saved.Name {
+		// Saving under a new name starts a new session file
+		saved.Name = args[0]
+		saved.CreatedAt = time.Time{}
+	}
+	if saved.Name == "" {
+		saved.Name = session.NewName()
+	}
+	saved.Messages = messages
+
+	if err := sessionStore.Save(&saved); err != nil {
+		fmt.Printf("\n%s\n\n", errorStyle.Render(fmt.Sprintf("Error saving session: %v", err)))
+		return
+	}
+
+	saved.Messages = nil
+	*currentSession = saved
+	fmt.Printf("\n%s\n\n", responseStyle.Render(
+		fmt.Sprintf("Session saved as %s (%d messages)", saved.Name, len(messages)),
+	))
+}
+
+func handleLoadCommand(args []string, messages *[]history.HistoryMessage) {
+	if len(args) == 0 {
+		fmt.Printf("\n%s\n\n", errorStyle.Render("Usage: /load <name>"))
+		return
+	}
+
+	loaded, err := sessionStore.Load(args[0])
+	if err != nil {
+		fmt.Printf("\n%s\n\n", errorStyle.Render(fmt.Sprintf("Error loading session: %v", err)))
+		return
+	}
+
+	warnSessionMismatch(loaded)
+	*messages = withSystemPrompt(loaded.Messages)
+	currentSession.Name = loaded.Name
+	currentSession.CreatedAt = loaded.CreatedAt
+
+	fmt.Printf("\n%s\n\n", responseStyle.Render(
+		fmt.Sprintf("Session %s loaded (%d messages)", loaded.Name, len(loaded.Messages)),
+	))
+}
+
+func handleSessionsCommand() {
+	if err := updateRenderer(); err != nil {
+		fmt.Printf(
+			"\n%s\n",
+			errorStyle.Render(fmt.Sprintf("Error updating renderer: %v", err)),
+		)
+		return
+	}
+
+	summaries, err := sessionStore.List()
+	if err != nil {
+		fmt.Printf("\n%s\n\n", errorStyle.Render(fmt.Sprintf("Error listing sessions: %v", err)))
+		return
+	}
+
+	var markdown strings.Builder
+	markdown.WriteString("# Saved Sessions\n\n")
+	if len(summaries) == 0 {
+		markdown.WriteString("No saved sessions.\n")
+	} else {
+		markdown.WriteString("| Name | Model | Messages | Updated |\n")
+		markdown.WriteString("|------|-------|----------|---------|\n")
+		for _, s := range summaries {
+			name := s.Name
+			if name == currentSession.Name {
+				name += " (current)"
+			}
+			markdown.WriteString(fmt.Sprintf("| %s | %s | %d | %s |\n",
+				name,
+				s.Model,
+				s.Messages,
+				s.UpdatedAt.Format("2006-01-02 15:04"),
+			))
+		}
+	}
+
+	rendered, err := renderer.Render(markdown.String())
+	if err != nil {
+		fmt.Printf(
+			"\n%s\n",
+			errorStyle.Render(fmt.Sprintf("Error rendering sessions: %v", err)),
+		)
+		return
+	}
+
+	fmt.Print("\n" + rendered + "\n")
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/vincent-pli/mcphost/pkg/history"
+)
+
+var (
+	systemPromptFlag string
+	systemPromptFile string
+
+	// systemPrompt is the resolved system prompt, empty if none is set
+	systemPrompt string
+)
+
+// resolveSystemPrompt returns the system prompt from the --system-prompt or
+// --system-prompt-file flag, falling back to systemPrompt in the config
+func resolveSystemPrompt(config *MCPConfig) (string, error) {
+	if systemPromptFlag != "" && systemPromptFile != "" {
+		return "", fmt.Errorf("--system-prompt and --system-prompt-file cannot be used together")
+	}
+
+	if systemPromptFlag != "" {
+		return strings.TrimSpace(systemPromptFlag), nil
+	}
+
+	if systemPromptFile != "" {
+		data, err := os.ReadFile(systemPromptFile)
+		if err != nil {
+			return "", fmt.Errorf("error reading system prompt file: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+
+	return strings.TrimSpace(config.SystemPrompt), nil
+}
+
+// withSystemPrompt puts the configured system prompt at the start of the
+// history, replacing the one a loaded session was saved with. Without a
+// configured system prompt the history is returned unchanged.
+func withSystemPrompt(messages []history.HistoryMessage) []history.HistoryMessage {
+	if systemPrompt == "" {
+		return messages
+	}
+
+	_, rest := splitSystemMessage(messages)
+	return append([]history.HistoryMessage{{
+		Role: "system",
+		Content: []history.ContentBlock{{
+			Type: "text",
+			Text: systemPrompt,
+		}},
+	}}, rest...)
+}
+
+// splitSystemMessage separates a leading system message from the rest of
+// the history. Pruning and compaction only work on the rest. The capacity
+// of system is limited so that appending to it never overwrites rest.
+func splitSystemMessage(
+	messages []history.HistoryMessage,
+) (system []history.HistoryMessage, rest []history.HistoryMessage) {
+	if len(messages) > 0 && messages[0].Role == "system" {
+		return messages[:1:1], messages[1:]
+	}
+	return nil, messages
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/vincent-pli/mcphost/pkg/history"
+	"github.com/vincent-pli/mcphost/pkg/llm"
+)
+
+var (
+	// showThinking prints the model's thinking in full instead of a
+	// collapsed summary line
+	showThinking bool
+
+	// lastThinking is the thinking of the last turn, shown with /thinking
+	lastThinking []string
+
+	thinkingStyle = lipgloss.NewStyle().
+			Faint(true).
+			Italic(true).
+			PaddingLeft(2)
+)
+
+// thinkingContent converts the thinking of a response to history blocks,
+// which must precede the other blocks of the message
+func thinkingContent(blocks []llm.ThinkingBlock) []history.ContentBlock {
+	content := make([]history.ContentBlock, 0, len(blocks))
+	for _, block := range blocks {
+		if block.RedactedData != "" {
+			content = append(content, history.ContentBlock{
+				Type: "redacted_thinking",
+				Data: block.RedactedData,
+			})
+			continue
+		}
+		content = append(content, history.ContentBlock{
+			Type:      "thinking",
+			Thinking:  block.Thinking,
+			Signature: block.Signature,
+		})
+	}
+	return content
+}
+
+// thinkingText joins the reasoning of the blocks. Redacted blocks are
+// encrypted by the provider and only noted.
+func thinkingText(blocks []llm.ThinkingBlock) string {
+	var parts []string
+	for _, block := range blocks {
+		if block.RedactedData != "" {
+			parts = append(parts, "[redacted thinking]")
+		} else if block.Thinking != "" {
+			parts = append(parts, block.Thinking)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// printThinking prints the thinking of a response, or a summary line
+// unless --show-thinking is set
+func printThinking(text string) {
+	if text == "" {
+		return
+	}
+	if showThinking {
+		fmt.Printf("\n%s\n", thinkingStyle.Render("Thinking:\n"+text))
+		return
+	}
+	printThinkingSummary(text)
+}
+
+func printThinkingSummary(text string) {
+	fmt.Printf("\n%s\n", thinkingStyle.Render(fmt.Sprintf(
+		"▸ Thought for %d words · /thinking to expand", len(strings.Fields(text)),
+	)))
+}
+
+// handleThinkingCommand shows the thinking of the last turn in full
+func handleThinkingCommand() {
+	if len(lastThinking) == 0 {
+		fmt.Printf("\n%s\n\n", responseStyle.Render("No thinking in the last response"))
+		return
+	}
+
+	width := getTerminalWidth()
+	fmt.Printf("\n%s\n\n", thinkingStyle.Width(width).Render(
+		"Thinking:\n"+strings.Join(lastThinking, "\n\n"),
+	))
+}
@@ -0,0 +1,278 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/vincent-pli/mcphost/pkg/history"
+)
+
+var (
+	// toolConcurrency limits how many tool calls run at the same time
+	toolConcurrency int
+
+	// progressProgram shows the progress of the running tool calls, it is
+	// nil while no calls run. progressDone is closed once it has ended.
+	progressProgram   *tea.Program
+	progressDone      chan struct{}
+	progressProgramMu sync.Mutex
+)
+
+// pendingToolCall is an approved tool call waiting to run
+type pendingToolCall struct {
+	// index is the position of the call in the assistant message, which is
+	// also the position of its result
+	index      int
+	id         string
+	serverName string
+	toolName   string
+	args       map[string]interface{}
+	client     mcpclient.MCPClient
+}
+
+func (c pendingToolCall) namespacedName() string {
+	return fmt.Sprintf("%s__%s", c.serverName, c.toolName)
+}
+
+// toolCallBatches groups the calls of each server into batches that run
+// one after another. Consecutive read-only calls share a batch and run
+// concurrently, any other call gets a batch of its own so that calls
+// with side effects on a server keep their order.
+func toolCallBatches(calls []pendingToolCall) [][][]pendingToolCall {
+	var servers []string
+	lanes := make(map[string][][]pendingToolCall)
+	for _, call := range calls {
+		batches, ok := lanes[call.serverName]
+		if !ok {
+			servers = append(servers, call.serverName)
+		}
+
+		readOnly := catalog.isReadOnly(call.namespacedName())
+		if n := len(batches); readOnly && n > 0 &&
+			catalog.isReadOnly(batches[n-1][0].namespacedName()) {
+			batches[n-1] = append(batches[n-1], call)
+		} else {
+			batches = append(batches, []pendingToolCall{call})
+		}
+		lanes[call.serverName] = batches
+	}
+
+	result := make([][][]pendingToolCall, 0, len(servers))
+	for _, server := range servers {
+		result = append(result, lanes[server])
+	}
+	return result
+}
+
+// newToolProgressProgram creates the view of the progress of the calls
+func newToolProgressProgram(names []string, output io.Writer) *tea.Program {
+	return tea.NewProgram(
+		newToolProgressModel(names),
+		tea.WithOutput(output),
+		// The view reads no input. The reader is empty rather than nil, as
+		// resuming the view after a pause starts reading it again.
+		tea.WithInput(strings.NewReader("")),
+		// Ctrl+C cancels the calls through ctx, the view stays up to show
+		// them as failed
+		tea.WithoutSignalHandler(),
+	)
+}
+
+// runToolCalls runs the calls, concurrently where possible, and stores the
+// result of each call at its index in results. Progress is shown in a
+// single view for all calls.
+func runToolCalls(ctx context.Context, calls []pendingToolCall, results []history.ContentBlock) {
+	if len(calls) == 0 {
+		return
+	}
+
+	// Position of each call in the progress view
+	positions := make(map[int]int, len(calls))
+	names := make([]string, len(calls))
+	for i, call := range calls {
+		positions[call.index] = i
+		names[i] = call.namespacedName()
+	}
+
+	var program *tea.Program
+	update := func(call pendingToolCall, state toolCallState) {
+		if program != nil {
+			program.Send(toolCallUpdate{index: positions[call.index], state: state})
+		}
+	}
+	if !quietMode {
+		program = newToolProgressProgram(names, os.Stderr)
+	}
+
+	var errs []string
+	var errsMu sync.Mutex
+	run := func() {
+		limit := toolConcurrency
+		if limit < 1 {
+			limit = 1
+		}
+		sem := make(chan struct{}, limit)
+
+		var wg sync.WaitGroup
+		for _, batches := range toolCallBatches(calls) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for _, batch := range batches {
+					var batchWG sync.WaitGroup
+					for _, call := range batch {
+						batchWG.Add(1)
+						go func() {
+							defer batchWG.Done()
+							sem <- struct{}{}
+							defer func() { <-sem }()
+
+							update(call, toolCallRunning)
+							result, errMsg := callTool(ctx, call)
+							results[call.index] = result
+							if ctx.Err() != nil {
+								update(call, toolCallCancelled)
+							} else if errMsg != "" {
+								errsMu.Lock()
+								errs = append(errs, errMsg)
+								errsMu.Unlock()
+								update(call, toolCallFailed)
+							} else {
+								update(call, toolCallDone)
+							}
+						}()
+					}
+					batchWG.Wait()
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	if program == nil {
+		run()
+	} else {
+		showToolProgress(program, run)
+	}
+
+	// Errors are printed once the progress view is gone
+	for _, errMsg := range errs {
+		fmt.Fprintf(os.Stderr, "\n%s\n", errorStyle.Render(errMsg))
+	}
+}
+
+// showToolProgress shows the progress view until run has run the calls
+func showToolProgress(program *tea.Program, run func()) {
+	done := make(chan struct{})
+	progressProgramMu.Lock()
+	progressProgram, progressDone = program, done
+	progressProgramMu.Unlock()
+
+	go func() {
+		run()
+		program.Send(toolCallsFinished{})
+	}()
+	if _, err := program.Run(); err != nil {
+		log.Error("Failed to show tool progress", "error", err)
+	}
+	close(done)
+
+	progressProgramMu.Lock()
+	progressProgram, progressDone = nil, nil
+	progressProgramMu.Unlock()
+}
+
+// pauseToolProgress hides the progress view of running tool calls while a
+// server asks the user something, and returns a function that shows it
+// again. The view is cleared and the terminal released when it returns.
+func pauseToolProgress() (resume func()) {
+	progressProgramMu.Lock()
+	program, done := progressProgram, progressDone
+	progressProgramMu.Unlock()
+	if program == nil {
+		return func() {}
+	}
+
+	pause := toolProgressPause{
+		released: make(chan struct{}),
+		resume:   make(chan struct{}),
+	}
+	program.Send(pause)
+
+	// The view may end before it takes up the pause, once all calls are
+	// done
+	select {
+	case <-pause.released:
+	case <-done:
+	}
+
+	return sync.OnceFunc(func() {
+		close(pause.resume)
+	})
+}
+
+// callTool runs a single tool call and returns its tool_result block. If
+// the call failed, the error message is returned as well.
+func callTool(ctx context.Context, call pendingToolCall) (history.ContentBlock, string) {
+	if ctx.Err() != nil {
+		return toolCancelledResult(call.id), ""
+	}
+
+	if call.namespacedName() == readResourceTool {
+		return callResourceTool(ctx, call)
+	}
+
+	req := mcp.CallToolRequest{}
+	req.Params.Name = call.toolName
+	req.Params.Arguments = call.args
+	toolResult, err := call.client.CallTool(ctx, req)
+	if err != nil && ctx.Err() != nil {
+		return toolCancelledResult(call.id), ""
+	}
+	if err != nil {
+		errMsg := fmt.Sprintf(
+			"Error calling tool %s: %v",
+			call.toolName,
+			err,
+		)
+		return toolErrorResult(call.id, errMsg), errMsg
+	}
+
+	log.Debug("raw tool result content", "content", toolResult.Content)
+
+	// Create the tool result block
+	resultBlock := history.ContentBlock{
+		Type:      "tool_result",
+		ToolUseID: call.id,
+		Content:   toolResult.Content,
+		IsError:   toolResult.IsError,
+	}
+
+	// Extract text content
+	var resultText string
+	for _, item := range toolResult.Content {
+		if content, ok := item.(mcp.TextContent); ok {
+			if content.Text != "" {
+				resultText += fmt.Sprintf("%v ", content.Text)
+			}
+		}
+	}
+
+	resultBlock.Text = strings.TrimSpace(resultText)
+	log.Debug("created tool result block",
+		"block", resultBlock,
+		"tool_id", call.id)
+
+	if toolResult.IsError {
+		return resultBlock, fmt.Sprintf("Tool %s reported an error: %s", call.toolName, resultBlock.Text)
+	}
+	return resultBlock, ""
+}
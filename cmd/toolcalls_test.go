@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestToolCallBatches(t *testing.T) {
+	saved := catalog.readOnly
+	defer func() { catalog.readOnly = saved }()
+	catalog.readOnly = map[string]bool{
+		"fs__read":   true,
+		"fs__list":   true,
+		"web__fetch": true,
+	}
+
+	call := func(index int, namespacedName string) pendingToolCall {
+		serverName, toolName, _ := strings.Cut(namespacedName, "__")
+		return pendingToolCall{index: index, serverName: serverName, toolName: toolName}
+	}
+	// format writes the batches of each server as e.g. "0,1 2", with the
+	// indexes of the calls of a batch joined by commas
+	format := func(lanes [][][]pendingToolCall) []string {
+		var result []string
+		for _, batches := range lanes {
+			var parts []string
+			for _, batch := range batches {
+				var indexes []string
+				for _, c := range batch {
+					indexes = append(indexes, fmt.Sprint(c.index))
+				}
+				parts = append(parts, strings.Join(indexes, ","))
+			}
+			result = append(result, strings.Join(parts, " "))
+		}
+		return result
+	}
+
+	tests := []struct {
+		name  string
+		calls []pendingToolCall
+		want  []string
+	}{
+		{
+			name:  "read-only calls share a batch",
+			calls: []pendingToolCall{call(0, "fs__read"), call(1, "fs__list"), call(2, "fs__read")},
+			want:  []string{"0,1,2"},
+		},
+		{
+			name:  "other calls keep their order",
+			calls: []pendingToolCall{call(0, "fs__read"), call(1, "fs__write"), call(2, "fs__read"), call(3, "fs__list"), call(4, "fs__write")},
+			want:  []string{"0 1 2,3 4"},
+		},
+		{
+			name:  "calls with side effects run one by one",
+			calls: []pendingToolCall{call(0, "fs__write"), call(1, "fs__delete")},
+			want:  []string{"0 1"},
+		},
+		{
+			name:  "servers in order of their first call",
+			calls: []pendingToolCall{call(0, "web__fetch"), call(1, "fs__write"), call(2, "web__fetch"), call(3, "fs__read")},
+			want:  []string{"0,2", "1 3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := format(toolCallBatches(tt.calls))
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("batches = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPauseToolProgress(t *testing.T) {
+	var output bytes.Buffer
+	program := newToolProgressProgram([]string{"fs__read"}, &output)
+
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		showToolProgress(program, func() {
+			resume := pauseToolProgress()
+
+			// The view takes no messages while the terminal is released,
+			// so an update sent now waits until it is resumed
+			updated := make(chan struct{})
+			go func() {
+				program.Send(toolCallUpdate{index: 0, state: toolCallDone})
+				close(updated)
+			}()
+			select {
+			case <-updated:
+				t.Error("the view took an update while paused")
+			case <-time.After(50 * time.Millisecond):
+			}
+
+			resume()
+			resume()
+			<-updated
+
+			// A second pause in the same view works as well
+			pauseToolProgress()()
+		})
+	}()
+
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the view did not finish")
+	}
+	if !strings.Contains(output.String(), "fs__read") {
+		t.Errorf("output = %q, want the progress of the call", output.String())
+	}
+}
+
+func TestPauseEndedToolProgress(t *testing.T) {
+	// A view that ended before it took up the pause does not block it
+	program := newToolProgressProgram([]string{"fs__read"}, &bytes.Buffer{})
+	showToolProgress(program, func() {})
+
+	done := make(chan struct{})
+	close(done)
+	progressProgramMu.Lock()
+	progressProgram, progressDone = program, done
+	progressProgramMu.Unlock()
+	defer func() {
+		progressProgramMu.Lock()
+		progressProgram, progressDone = nil, nil
+		progressProgramMu.Unlock()
+	}()
+
+	paused := make(chan struct{})
+	go func() {
+		pauseToolProgress()()
+		close(paused)
+	}()
+	select {
+	case <-paused:
+	case <-time.After(5 * time.Second):
+		t.Fatal("pausing an ended view blocked")
+	}
+}
@@ -0,0 +1,99 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Content kinds recorded next to ContentBlock.Content so that it can be
+// decoded back into the Go type it was created with
+const (
+	contentKindString = "string"
+	contentKindBlocks = "blocks"
+	contentKindMCP    = "mcp"
+)
+
+// contentBlockFields has the fields of ContentBlock without its methods
+type contentBlockFields ContentBlock
+
+func (b ContentBlock) MarshalJSON() ([]byte, error) {
+	var kind string
+	switch b.Content.(type) {
+	case string:
+		kind = contentKindString
+	case []ContentBlock:
+		kind = contentKindBlocks
+	case []mcp.Content:
+		kind = contentKindMCP
+	}
+
+	return json.Marshal(struct {
+		contentBlockFields
+		ContentKind string `json:"content_kind,omitempty"`
+	}{
+		contentBlockFields: contentBlockFields(b),
+		ContentKind:        kind,
+	})
+}
+
+func (b *ContentBlock) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		contentBlockFields
+		Content     json.RawMessage `json:"content,omitempty"`
+		ContentKind string          `json:"content_kind,omitempty"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	content, err := decodeContent(raw.ContentKind, raw.Content)
+	if err != nil {
+		return fmt.Errorf("error decoding %s block content: %w", raw.Type, err)
+	}
+
+	*b = ContentBlock(raw.contentBlockFields)
+	b.Content = content
+	return nil
+}
+
+func decodeContent(kind string, data json.RawMessage) (interface{}, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+
+	switch kind {
+	case contentKindString:
+		var content string
+		err := json.Unmarshal(data, &content)
+		return content, err
+
+	case contentKindBlocks:
+		var content []ContentBlock
+		err := json.Unmarshal(data, &content)
+		return content, err
+
+	case contentKindMCP:
+		var items []map[string]interface{}
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, err
+		}
+
+		content := make([]mcp.Content, 0, len(items))
+		for _, item := range items {
+			c, err := mcp.ParseContent(item)
+			if err != nil {
+				return nil, err
+			}
+			content = append(content, c)
+		}
+		return content, nil
+
+	default:
+		// Content of unknown origin is kept in its generic JSON form
+		var content interface{}
+		err := json.Unmarshal(data, &content)
+		return content, err
+	}
+}
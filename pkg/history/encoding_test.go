@@ -0,0 +1,110 @@
+package history
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// conversation has every kind of block a saved conversation can hold
+func conversation() []HistoryMessage {
+	return []HistoryMessage{
+		{
+			Role: "user",
+			Content: []ContentBlock{
+				{Type: "text", Text: "Describe the chart and read notes.md"},
+				{Type: "image", MediaType: "image/png", Name: "chart.png", Data: "iVBORw0KGgo="},
+				{Type: "document", MediaType: "text/markdown", Name: "notes.md", Text: "# Notes"},
+				{Type: "document", MediaType: "application/pdf", Name: "report.pdf", Data: "JVBERi0xLjQ="},
+			},
+		},
+		{
+			Role: "assistant",
+			Content: []ContentBlock{
+				{Type: "thinking", Thinking: "The chart needs the data file.", Signature: "sig=="},
+				{Type: "redacted_thinking", Data: "cmVkYWN0ZWQ="},
+				{Type: "text", Text: "Let me read the data."},
+				{Type: "tool_use", ID: "toolu_1", Name: "fs__read_file", Input: json.RawMessage(`{"path":"data.csv"}`)},
+				{Type: "tool_use", ID: "toolu_2", Name: "fs__stat", Input: json.RawMessage(`{}`)},
+			},
+			InputTokens:  120,
+			OutputTokens: 45,
+		},
+		{
+			Role: "user",
+			Content: []ContentBlock{
+				{
+					Type:      "tool_result",
+					ToolUseID: "toolu_1",
+					Content: []mcp.Content{
+						mcp.NewTextContent("a,b\n1,2"),
+						mcp.NewImageContent("iVBORw0KGgo=", "image/png"),
+						mcp.NewEmbeddedResource(mcp.TextResourceContents{
+							URI:      "file:///data.csv",
+							MIMEType: "text/csv",
+							Text:     "a,b\n1,2",
+						}),
+						mcp.NewEmbeddedResource(mcp.BlobResourceContents{
+							URI:      "file:///data.bin",
+							MIMEType: "application/octet-stream",
+							Blob:     "AAEC",
+						}),
+					},
+				},
+				{Type: "tool_result", ToolUseID: "toolu_2", Content: "no such file", IsError: true},
+			},
+		},
+		{
+			Role: "user",
+			Content: []ContentBlock{{
+				Type:      "tool_result",
+				ToolUseID: "toolu_3",
+				Content: []ContentBlock{
+					{Type: "text", Text: "summary of the result"},
+					{Type: "image", MediaType: "image/jpeg", Data: "/9j/4AAQ"},
+				},
+			}},
+		},
+		{
+			Role:    "user",
+			Content: []ContentBlock{{Type: "text", Text: "Summary of the earlier conversation"}},
+			Summary: true,
+		},
+	}
+}
+
+func TestContentBlockRoundTrip(t *testing.T) {
+	want := conversation()
+
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []HistoryMessage
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d messages, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("message %d:\n got %#v\nwant %#v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestContentBlockUnknownContent(t *testing.T) {
+	// Content without a recorded kind is kept in its generic JSON form
+	var block ContentBlock
+	if err := json.Unmarshal([]byte(`{"type": "tool_result", "tool_use_id": "toolu_1", "content": [{"type": "text", "text": "ok"}]}`), &block); err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{map[string]interface{}{"type": "text", "text": "ok"}}
+	if !reflect.DeepEqual(block.Content, want) {
+		t.Errorf("content = %#v, want %#v", block.Content, want)
+	}
+}
@@ -11,6 +11,14 @@ import (
 type HistoryMessage struct {
 	Role    string         `json:"role"`
 	Content []ContentBlock `json:"content"`
+
+	// InputTokens and OutputTokens are the usage reported by the provider
+	// for the request that produced this message, if any
+	InputTokens  int `json:"input_tokens,omitempty"`
+	OutputTokens int `json:"output_tokens,omitempty"`
+
+	// Summary marks a message that replaces older, compacted messages
+	Summary bool `json:"summary,omitempty"`
 }
 
 func (m *HistoryMessage) GetRole() string {
@@ -33,9 +41,10 @@ func (m *HistoryMessage) GetToolCalls() []llm.ToolCall {
 	for _, block := range m.Content {
 		if block.Type == "tool_use" {
 			calls = append(calls, &HistoryToolCall{
-				id:   block.ID,
-				name: block.Name,
-				args: block.Input,
+				id:        block.ID,
+				name:      block.Name,
+				args:      block.Input,
+				signature: block.Signature,
 			})
 		}
 	}
@@ -60,15 +69,48 @@ func (m *HistoryMessage) GetToolResponseID() string {
 	return ""
 }
 
+// GetAttachments returns the image and document blocks
+func (m *HistoryMessage) GetAttachments() []ContentBlock {
+	var attachments []ContentBlock
+	for _, block := range m.Content {
+		if block.IsAttachment() {
+			attachments = append(attachments, block)
+		}
+	}
+	return attachments
+}
+
+// GetThinking returns the thinking and redacted_thinking blocks
+func (m *HistoryMessage) GetThinking() []llm.ThinkingBlock {
+	var blocks []llm.ThinkingBlock
+	for _, block := range m.Content {
+		switch block.Type {
+		case "thinking":
+			blocks = append(blocks, llm.ThinkingBlock{
+				Thinking:  block.Thinking,
+				Signature: block.Signature,
+			})
+		case "redacted_thinking":
+			blocks = append(blocks, llm.ThinkingBlock{RedactedData: block.Data})
+		}
+	}
+	return blocks
+}
+
 func (m *HistoryMessage) GetUsage() (int, int) {
-	return 0, 0 // History doesn't track usage
+	return m.InputTokens, m.OutputTokens
 }
 
-// HistoryToolCall implements llm.ToolCall for stored tool calls
+// HistoryToolCall implements llm.SignedToolCall for stored tool calls
 type HistoryToolCall struct {
-	id   string
-	name string
-	args json.RawMessage
+	id        string
+	name      string
+	args      json.RawMessage
+	signature string
+}
+
+func (t *HistoryToolCall) GetSignature() string {
+	return t.signature
 }
 
 func (t *HistoryToolCall) GetID() string {
@@ -96,4 +138,22 @@ type ContentBlock struct {
 	Name      string          `json:"name,omitempty"`
 	Input     json.RawMessage `json:"input,omitempty"`
 	Content   interface{}     `json:"content,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
+
+	// Thinking and Signature hold a thinking block, Data a
+	// redacted_thinking block. A tool_use block can have a Signature too.
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Data      string `json:"data,omitempty"`
+
+	// MediaType is set on attached files. An image block holds the image
+	// base64 encoded in Data, a document block holds a text file in Text
+	// or a binary file, e.g. a PDF, base64 encoded in Data. Name is the
+	// file name.
+	MediaType string `json:"media_type,omitempty"`
+}
+
+// IsAttachment reports whether the block is an attached file
+func (b ContentBlock) IsAttachment() bool {
+	return b.Type == "image" || b.Type == "document"
 }
@@ -0,0 +1,81 @@
+package history
+
+import (
+	"encoding/json"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/vincent-pli/mcphost/pkg/llm"
+)
+
+const (
+	// messageOverheadTokens covers role markers and message framing
+	messageOverheadTokens = 4
+
+	// blockOverheadTokens covers the framing of a single content block
+	blockOverheadTokens = 3
+
+	// imageTokens is a rough average cost of an image
+	imageTokens = 1600
+)
+
+// EstimateTokens returns the number of tokens the message takes up in the
+// context. Provider-reported output usage is used where it was recorded,
+// a heuristic estimate otherwise.
+func (m *HistoryMessage) EstimateTokens() int {
+	if m.OutputTokens > 0 {
+		return m.OutputTokens + messageOverheadTokens
+	}
+
+	tokens := messageOverheadTokens
+	for _, block := range m.Content {
+		tokens += estimateBlockTokens(block)
+	}
+	return tokens
+}
+
+func estimateBlockTokens(block ContentBlock) int {
+	if block.Type == "image" {
+		return blockOverheadTokens + imageTokens
+	}
+
+	tokens := blockOverheadTokens +
+		llm.EstimateTokens(block.Text) +
+		llm.EstimateTokens(block.Thinking) +
+		llm.EstimateTokens(block.Data) +
+		llm.EstimateTokens(block.Name) +
+		llm.EstimateTokens(string(block.Input))
+
+	switch content := block.Content.(type) {
+	case string:
+		// Tool results keep their text in both fields, count it once
+		if block.Text == "" {
+			tokens += llm.EstimateTokens(content)
+		}
+	case []ContentBlock:
+		for _, b := range content {
+			tokens += estimateBlockTokens(b)
+		}
+	case []mcp.Content:
+		for _, c := range content {
+			switch c := c.(type) {
+			case mcp.TextContent:
+				if block.Text == "" {
+					tokens += llm.EstimateTokens(c.Text)
+				}
+			case mcp.ImageContent:
+				tokens += imageTokens
+			default:
+				if data, err := json.Marshal(c); err == nil {
+					tokens += llm.EstimateTokens(string(data))
+				}
+			}
+		}
+	case nil:
+	default:
+		if data, err := json.Marshal(content); err == nil {
+			tokens += llm.EstimateTokens(string(data))
+		}
+	}
+
+	return tokens
+}
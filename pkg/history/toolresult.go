@@ -0,0 +1,115 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ToolResultPart is a part of a tool result in a form every provider can
+// convert: either text or an image
+type ToolResultPart struct {
+	Text string
+
+	// MIMEType and Data, base64 encoded, are set for images
+	MIMEType string
+	Data     string
+}
+
+// IsImage reports whether the part is an image
+func (p ToolResultPart) IsImage() bool {
+	return p.Data != ""
+}
+
+// ToolResultParts returns the parts of a tool_result block. Text resources
+// are inlined, and content models cannot take, like audio, is described
+// in text.
+func ToolResultParts(block ContentBlock) []ToolResultPart {
+	var parts []ToolResultPart
+	switch content := block.Content.(type) {
+	case []mcp.Content:
+		for _, c := range content {
+			parts = append(parts, mcpContentPart(c))
+		}
+	case []ContentBlock:
+		for _, b := range content {
+			if b.Type == "text" {
+				parts = append(parts, ToolResultPart{Text: b.Text})
+			}
+		}
+	case string:
+		parts = append(parts, ToolResultPart{Text: content})
+	case []interface{}:
+		// Content of unknown origin in its generic JSON form
+		for _, item := range content {
+			itemMap, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if c, err := mcp.ParseContent(itemMap); err == nil {
+				parts = append(parts, mcpContentPart(c))
+			}
+		}
+	}
+
+	// Drop empty text, and fall back to the text of the block
+	n := 0
+	for _, part := range parts {
+		if part.IsImage() || part.Text != "" {
+			parts[n] = part
+			n++
+		}
+	}
+	parts = parts[:n]
+	if len(parts) == 0 && block.Text != "" {
+		parts = append(parts, ToolResultPart{Text: block.Text})
+	}
+	return parts
+}
+
+func mcpContentPart(content mcp.Content) ToolResultPart {
+	switch c := content.(type) {
+	case mcp.TextContent:
+		return ToolResultPart{Text: c.Text}
+	case mcp.ImageContent:
+		return ToolResultPart{MIMEType: c.MIMEType, Data: c.Data}
+	case mcp.AudioContent:
+		return ToolResultPart{Text: fmt.Sprintf("[%s audio omitted: audio is not supported]", c.MIMEType)}
+	case mcp.ResourceLink:
+		return ToolResultPart{Text: fmt.Sprintf("Resource link: %s (%s)", c.URI, c.Name)}
+	case mcp.EmbeddedResource:
+		switch r := c.Resource.(type) {
+		case mcp.TextResourceContents:
+			return ToolResultPart{Text: fmt.Sprintf("Resource %s:\n%s", r.URI, r.Text)}
+		case mcp.BlobResourceContents:
+			if strings.HasPrefix(r.MIMEType, "image/") {
+				return ToolResultPart{MIMEType: r.MIMEType, Data: r.Blob}
+			}
+			return ToolResultPart{Text: fmt.Sprintf("[Resource %s omitted: binary content of type %s]", r.URI, r.MIMEType)}
+		}
+	}
+
+	data, _ := json.Marshal(content)
+	return ToolResultPart{Text: string(data)}
+}
+
+// ImagePlaceholder describes an image that is not sent to the model
+func ImagePlaceholder(mimeType string) string {
+	return fmt.Sprintf("[%s image omitted: the model does not support images]", mimeType)
+}
+
+// ToolResultText joins the text of the parts, with a placeholder for every
+// image, for models that take tool results as text only
+func ToolResultText(parts []ToolResultPart) string {
+	texts := make([]string, len(parts))
+	for i, part := range parts {
+		if part.IsImage() {
+			texts[i] = ImagePlaceholder(part.MIMEType)
+		} else {
+			texts[i] = part.Text
+		}
+	}
+	return strings.Join(texts, "\n")
+}
@@ -1,12 +1,15 @@
 package anthropic
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
+
+	"github.com/vincent-pli/mcphost/pkg/llm"
 )
 
 type Client struct {
@@ -29,6 +32,68 @@ func NewClient(apiKey string, baseURL string) *Client {
 }
 
 func (c *Client) CreateMessage(ctx context.Context, req CreateRequest) (*APIMessage, error) {
+	resp, err := c.doRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var message APIMessage
+	if err := json.NewDecoder(resp.Body).Decode(&message); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return &message, nil
+}
+
+// StreamMessage sends a streaming request and calls handler for every
+// server-sent event until the stream ends or handler returns an error. A
+// stream that ends without a message_stop event is incomplete.
+func (c *Client) StreamMessage(ctx context.Context, req CreateRequest, handler func(StreamEvent) error) error {
+	req.Stream = true
+	resp, err := c.doRequest(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	complete := false
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		var event StreamEvent
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return fmt.Errorf("error decoding stream event: %w", err)
+		}
+
+		if event.Type == "error" && event.Error != nil {
+			return llm.NewProviderError(0, event.Error.Type, event.Error.Message)
+		}
+		if event.Type == "message_stop" {
+			complete = true
+		}
+
+		if err := handler(event); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return llm.NewConnectionError("error reading stream", err)
+	}
+	if !complete {
+		return llm.NewIncompleteStreamError()
+	}
+
+	return nil
+}
+
+func (c *Client) doRequest(ctx context.Context, req CreateRequest) (*http.Response, error) {
 	body, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("error marshaling request: %w", err)
@@ -45,32 +110,21 @@ func (c *Client) CreateMessage(ctx context.Context, req CreateRequest) (*APIMess
 
 	resp, err := c.client.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("error making request: %w", err)
+		return nil, llm.NewConnectionError("error making request", err)
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+
 		var errResp struct {
-			Error struct {
-				Type    string `json:"type"`
-				Message string `json:"message"`
-			} `json:"error"`
+			Error ErrorDetail `json:"error"`
 		}
 		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
-			return nil, fmt.Errorf("error response with status %d", resp.StatusCode)
+			return nil, llm.NewHTTPError(resp, "", "")
 		}
 
-		if errResp.Error.Type == "overloaded_error" {
-			return nil, fmt.Errorf("overloaded_error: %s", errResp.Error.Message)
-		}
-
-		return nil, fmt.Errorf("%s: %s", errResp.Error.Type, errResp.Error.Message)
-	}
-
-	var message APIMessage
-	if err := json.NewDecoder(resp.Body).Decode(&message); err != nil {
-		return nil, fmt.Errorf("error decoding response: %w", err)
+		return nil, llm.NewHTTPError(resp, errResp.Error.Type, errResp.Error.Message)
 	}
 
-	return &message, nil
+	return resp, nil
 }
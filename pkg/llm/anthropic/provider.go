@@ -11,17 +11,32 @@ import (
 	"github.com/vincent-pli/mcphost/pkg/llm"
 )
 
+// MessageClient sends requests to the Messages API. Client implements it
+// for the Anthropic API, other packages for other endpoints serving
+// Claude models.
+type MessageClient interface {
+	CreateMessage(ctx context.Context, req CreateRequest) (*APIMessage, error)
+	StreamMessage(ctx context.Context, req CreateRequest, handler func(StreamEvent) error) error
+}
+
 type Provider struct {
-	client *Client
-	model  string
+	client  MessageClient
+	model   string
+	options llm.GenerationOptions
 }
 
 func NewProvider(apiKey string, baseURL string, model string) *Provider {
+	return NewProviderWithClient(NewClient(apiKey, baseURL), model)
+}
+
+// NewProviderWithClient creates a provider that sends its requests with
+// client
+func NewProviderWithClient(client MessageClient, model string) *Provider {
 	if model == "" {
 		model = "claude-3-5-sonnet-20240620" // 默认模型
 	}
 	return &Provider{
-		client: NewClient(apiKey, baseURL),
+		client: client,
 		model:  model,
 	}
 }
@@ -32,12 +47,134 @@ func (p *Provider) CreateMessage(
 	messages []llm.Message,
 	tools []llm.Tool,
 ) (llm.Message, error) {
+	// Make the API call
+	resp, err := p.client.CreateMessage(ctx, p.createRequest(prompt, messages, tools))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Message{Msg: *resp}, nil
+}
+
+func (p *Provider) StreamMessage(
+	ctx context.Context,
+	prompt string,
+	messages []llm.Message,
+	tools []llm.Tool,
+) (<-chan llm.StreamEvent, error) {
+	req := p.createRequest(prompt, messages, tools)
+	events := make(chan llm.StreamEvent)
+
+	send := func(event llm.StreamEvent) error {
+		select {
+		case events <- event:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	go func() {
+		defer close(events)
+
+		var msg APIMessage
+		err := p.client.StreamMessage(ctx, req, func(event StreamEvent) error {
+			switch event.Type {
+			case "message_start":
+				if event.Message != nil {
+					msg = *event.Message
+				}
+
+			case "content_block_start":
+				if event.ContentBlock == nil {
+					return nil
+				}
+				block := *event.ContentBlock
+				msg.Content = append(msg.Content, block)
+				if block.Type == "tool_use" {
+					// The arguments arrive as input_json_delta fragments
+					msg.Content[len(msg.Content)-1].Input = nil
+					return send(llm.StreamEvent{
+						Type:       llm.StreamEventToolCall,
+						ToolCallID: block.ID,
+						ToolName:   block.Name,
+					})
+				}
+
+			case "content_block_delta":
+				if event.Delta == nil || event.Index < 0 || event.Index >= len(msg.Content) {
+					return nil
+				}
+				block := &msg.Content[event.Index]
+				switch event.Delta.Type {
+				case "text_delta":
+					block.Text += event.Delta.Text
+					return send(llm.StreamEvent{
+						Type: llm.StreamEventText,
+						Text: event.Delta.Text,
+					})
+				case "input_json_delta":
+					block.Input = append(block.Input, event.Delta.PartialJSON...)
+					return send(llm.StreamEvent{
+						Type:          llm.StreamEventToolCall,
+						ToolCallID:    block.ID,
+						ToolArguments: event.Delta.PartialJSON,
+					})
+				case "thinking_delta":
+					block.Thinking += event.Delta.Thinking
+					return send(llm.StreamEvent{
+						Type: llm.StreamEventThinking,
+						Text: event.Delta.Thinking,
+					})
+				case "signature_delta":
+					block.Signature += event.Delta.Signature
+				}
+
+			case "content_block_stop":
+				if event.Index >= 0 && event.Index < len(msg.Content) {
+					block := &msg.Content[event.Index]
+					if block.Type == "tool_use" && len(block.Input) == 0 {
+						block.Input = json.RawMessage("{}")
+					}
+				}
+
+			case "message_delta":
+				if event.Delta != nil {
+					msg.StopReason = event.Delta.StopReason
+					msg.StopSequence = event.Delta.StopSequence
+				}
+				if event.Usage != nil {
+					msg.Usage.OutputTokens = event.Usage.OutputTokens
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			_ = send(llm.StreamEvent{Type: llm.StreamEventError, Err: err})
+			return
+		}
+
+		_ = send(llm.StreamEvent{
+			Type:    llm.StreamEventDone,
+			Message: &Message{Msg: msg},
+		})
+	}()
+
+	return events, nil
+}
+
+func (p *Provider) createRequest(
+	prompt string,
+	messages []llm.Message,
+	tools []llm.Tool,
+) CreateRequest {
 	log.Debug("creating message",
 		"prompt", prompt,
 		"num_messages", len(messages),
 		"num_tools", len(tools))
 
 	anthropicMessages := make([]MessageParam, 0, len(messages))
+	var systemPrompts []string
 
 	for _, msg := range messages {
 		log.Debug("converting message",
@@ -45,8 +182,43 @@ func (p *Provider) CreateMessage(
 			"content", msg.GetContent(),
 			"is_tool_response", msg.IsToolResponse())
 
+		// Anthropic takes the system prompt as a top-level field
+		// rather than as a message
+		if msg.GetRole() == "system" {
+			if text := strings.TrimSpace(msg.GetContent()); text != "" {
+				systemPrompts = append(systemPrompts, text)
+			}
+			continue
+		}
+
 		content := []ContentBlock{}
 
+		// Thinking blocks must come first and are sent back unchanged,
+		// the API checks their signatures
+		if thinkingMsg, ok := msg.(llm.ThinkingMessage); ok && p.options.ThinkingBudget != nil {
+			for _, block := range thinkingMsg.GetThinking() {
+				if block.RedactedData != "" {
+					content = append(content, ContentBlock{
+						Type: "redacted_thinking",
+						Data: block.RedactedData,
+					})
+				} else {
+					content = append(content, ContentBlock{
+						Type:      "thinking",
+						Thinking:  block.Thinking,
+						Signature: block.Signature,
+					})
+				}
+			}
+		}
+
+		// Attached files come before the text they are asked about
+		if historyMsg, ok := msg.(*history.HistoryMessage); ok {
+			for _, attachment := range historyMsg.GetAttachments() {
+				content = append(content, attachmentBlock(attachment))
+			}
+		}
+
 		// Add regular text content if present
 		if textContent := strings.TrimSpace(msg.GetContent()); textContent != "" {
 			content = append(content, ContentBlock{
@@ -75,11 +247,15 @@ func (p *Provider) CreateMessage(
 			if historyMsg, ok := msg.(*history.HistoryMessage); ok {
 				for _, block := range historyMsg.Content {
 					if block.Type == "tool_result" {
-						content = append(content, ContentBlock{
+						result := ContentBlock{
 							Type:      "tool_result",
 							ToolUseID: block.ToolUseID,
-							Content:   block.Content,
-						})
+							IsError:   block.IsError,
+						}
+						if resultContent := p.toolResultContent(block); len(resultContent) > 0 {
+							result.Content = resultContent
+						}
+						content = append(content, result)
 					}
 				}
 			} else {
@@ -121,6 +297,7 @@ func (p *Provider) CreateMessage(
 				Type:       tool.InputSchema.Type,
 				Properties: tool.InputSchema.Properties,
 				Required:   tool.InputSchema.Required,
+				Defs:       tool.InputSchema.Defs,
 			},
 		}
 	}
@@ -129,18 +306,159 @@ func (p *Provider) CreateMessage(
 		"messages", anthropicMessages,
 		"num_tools", len(tools))
 
-	// Make the API call
-	resp, err := p.client.CreateMessage(ctx, CreateRequest{
+	var system []ContentBlock
+	if len(systemPrompts) > 0 {
+		system = []ContentBlock{{
+			Type: "text",
+			Text: strings.Join(systemPrompts, "\n\n"),
+		}}
+	}
+	addCacheBreakpoints(system, anthropicMessages, anthropicTools)
+
+	req := CreateRequest{
 		Model:     p.model,
+		System:    system,
 		Messages:  anthropicMessages,
-		MaxTokens: 4096,
+		MaxTokens: p.options.MaxTokensOrDefault(),
 		Tools:     anthropicTools,
-	})
-	if err != nil {
-		return nil, err
+
+		Temperature:   p.options.Temperature,
+		TopP:          p.options.TopP,
+		StopSequences: p.options.Stop,
 	}
 
-	return &Message{Msg: *resp}, nil
+	if p.options.ThinkingBudget != nil {
+		budget := *p.options.ThinkingBudget
+		req.Thinking = &ThinkingConfig{Type: "enabled", BudgetTokens: budget}
+
+		// The thinking budget counts towards max_tokens
+		if req.MaxTokens <= budget {
+			req.MaxTokens = budget + llm.DefaultMaxTokens
+		}
+
+		// Thinking does not allow changing the temperature or a top_p
+		// below 0.95
+		req.Temperature = nil
+		if req.TopP != nil && *req.TopP < 0.95 {
+			req.TopP = nil
+		}
+	}
+
+	return req
+}
+
+// attachmentBlock converts an attached file to an image or document block
+func attachmentBlock(block history.ContentBlock) ContentBlock {
+	switch {
+	case block.Type == "image":
+		return ContentBlock{
+			Type: "image",
+			Source: &BlockSource{
+				Type:      "base64",
+				MediaType: block.MediaType,
+				Data:      block.Data,
+			},
+		}
+	case block.Data != "":
+		return ContentBlock{
+			Type:  "document",
+			Title: block.Name,
+			Source: &BlockSource{
+				Type:      "base64",
+				MediaType: block.MediaType,
+				Data:      block.Data,
+			},
+		}
+	default:
+		return ContentBlock{
+			Type:  "document",
+			Title: block.Name,
+			Source: &BlockSource{
+				Type:      "text",
+				MediaType: "text/plain",
+				Data:      block.Text,
+			},
+		}
+	}
+}
+
+// toolResultContent converts the content of a tool result to text and
+// image blocks. Models without vision get a placeholder for images.
+func (p *Provider) toolResultContent(block history.ContentBlock) []ContentBlock {
+	vision := llm.SupportsVision(p.model)
+
+	var content []ContentBlock
+	for _, part := range history.ToolResultParts(block) {
+		switch {
+		case part.IsImage() && vision:
+			content = append(content, ContentBlock{
+				Type: "image",
+				Source: &BlockSource{
+					Type:      "base64",
+					MediaType: part.MIMEType,
+					Data:      part.Data,
+				},
+			})
+		case part.IsImage():
+			content = append(content, ContentBlock{
+				Type: "text",
+				Text: history.ImagePlaceholder(part.MIMEType),
+			})
+		default:
+			content = append(content, ContentBlock{
+				Type: "text",
+				Text: part.Text,
+			})
+		}
+	}
+	return content
+}
+
+// addCacheBreakpoints marks the prompt prefixes to cache. Tools, system
+// prompt and history are sent in that order, so a breakpoint caches
+// everything before it. The tools and the system prompt rarely change
+// between turns. The history gets a rolling breakpoint on the last
+// message, and one on the user message before it, where the previous
+// request wrote the cache. The API allows four breakpoints.
+func addCacheBreakpoints(system []ContentBlock, messages []MessageParam, tools []Tool) {
+	if len(tools) > 0 {
+		tools[len(tools)-1].CacheControl = ephemeralCache
+	}
+	if len(system) > 0 {
+		system[len(system)-1].CacheControl = ephemeralCache
+	}
+
+	breakpoints := 0
+	for i := len(messages) - 1; i >= 0 && breakpoints < 2; i-- {
+		if breakpoints > 0 && messages[i].Role != "user" {
+			continue
+		}
+		if markCacheBreakpoint(messages[i].Content) {
+			breakpoints++
+		}
+	}
+}
+
+// markCacheBreakpoint sets the breakpoint on the last block of content
+// that can carry one. Thinking blocks and empty text cannot.
+func markCacheBreakpoint(content []ContentBlock) bool {
+	for i := len(content) - 1; i >= 0; i-- {
+		block := &content[i]
+		switch {
+		case block.Type == "thinking" || block.Type == "redacted_thinking":
+			continue
+		case block.Type == "text" && block.Text == "":
+			continue
+		}
+		block.CacheControl = ephemeralCache
+		return true
+	}
+	return false
+}
+
+// SetGenerationOptions sets the options used for all following requests
+func (p *Provider) SetGenerationOptions(options llm.GenerationOptions) {
+	p.options = options
 }
 
 func (p *Provider) SupportsTools() bool {
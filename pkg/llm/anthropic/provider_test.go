@@ -0,0 +1,582 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/vincent-pli/mcphost/pkg/history"
+	"github.com/vincent-pli/mcphost/pkg/llm"
+)
+
+// streamServer answers every request with the recorded server-sent events,
+// given as their data, and records the request bodies
+func streamServer(t *testing.T, events []string) (*httptest.Server, *[]CreateRequest) {
+	t.Helper()
+	var requests []CreateRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		var req CreateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("error decoding request: %v", err)
+		}
+		requests = append(requests, req)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, data := range events {
+			var event struct {
+				Type string `json:"type"`
+			}
+			json.Unmarshal([]byte(data), &event)
+			io.WriteString(w, "event: "+event.Type+"\ndata: "+data+"\n\n")
+		}
+	}))
+	return server, &requests
+}
+
+func userMessage(text string) *history.HistoryMessage {
+	return &history.HistoryMessage{
+		Role:    "user",
+		Content: []history.ContentBlock{{Type: "text", Text: text}},
+	}
+}
+
+// streamResult is what a consumer of a stream sees
+type streamResult struct {
+	text      string
+	thinking  string
+	toolCalls []llm.StreamEvent
+	done      llm.Message
+	err       error
+}
+
+func collectStream(events <-chan llm.StreamEvent) streamResult {
+	var result streamResult
+	for event := range events {
+		switch event.Type {
+		case llm.StreamEventText:
+			result.text += event.Text
+		case llm.StreamEventThinking:
+			result.thinking += event.Text
+		case llm.StreamEventToolCall:
+			result.toolCalls = append(result.toolCalls, event)
+		case llm.StreamEventError:
+			result.err = event.Err
+		case llm.StreamEventDone:
+			result.done = event.Message
+		}
+	}
+	return result
+}
+
+const (
+	messageStart = `{"type": "message_start", "message": {"id": "msg_1", "type": "message", "role": "assistant", "content": [], "model": "claude-3-5-sonnet-latest", "usage": {"input_tokens": 25, "output_tokens": 1}}}`
+	messageStop  = `{"type": "message_stop"}`
+)
+
+func TestStreamMessage(t *testing.T) {
+	tests := []struct {
+		name      string
+		events    []string
+		wantText  string
+		wantCalls []string
+		wantStop  string
+		wantUsage [2]int
+	}{
+		{
+			name: "text",
+			events: []string{
+				messageStart,
+				`{"type": "content_block_start", "index": 0, "content_block": {"type": "text", "text": ""}}`,
+				`{"type": "ping"}`,
+				`{"type": "content_block_delta", "index": 0, "delta": {"type": "text_delta", "text": "Hello"}}`,
+				`{"type": "content_block_delta", "index": 0, "delta": {"type": "text_delta", "text": ", world."}}`,
+				`{"type": "content_block_stop", "index": 0}`,
+				`{"type": "message_delta", "delta": {"stop_reason": "end_turn"}, "usage": {"output_tokens": 6}}`,
+				messageStop,
+			},
+			wantText:  "Hello, world.",
+			wantStop:  "end_turn",
+			wantUsage: [2]int{25, 6},
+		},
+		{
+			name: "tool_use split across deltas",
+			events: []string{
+				messageStart,
+				`{"type": "content_block_start", "index": 0, "content_block": {"type": "text", "text": ""}}`,
+				`{"type": "content_block_delta", "index": 0, "delta": {"type": "text_delta", "text": "Reading it."}}`,
+				`{"type": "content_block_stop", "index": 0}`,
+				`{"type": "content_block_start", "index": 1, "content_block": {"type": "tool_use", "id": "toolu_1", "name": "fs__read", "input": {}}}`,
+				`{"type": "content_block_delta", "index": 1, "delta": {"type": "input_json_delta", "partial_json": ""}}`,
+				`{"type": "content_block_delta", "index": 1, "delta": {"type": "input_json_delta", "partial_json": "{\"path\": \"a."}}`,
+				`{"type": "content_block_delta", "index": 1, "delta": {"type": "input_json_delta", "partial_json": "txt\", \"lines\": 10}"}}`,
+				`{"type": "content_block_stop", "index": 1}`,
+				`{"type": "content_block_start", "index": 2, "content_block": {"type": "tool_use", "id": "toolu_2", "name": "fs__list", "input": {}}}`,
+				`{"type": "content_block_stop", "index": 2}`,
+				`{"type": "message_delta", "delta": {"stop_reason": "tool_use"}, "usage": {"output_tokens": 40}}`,
+				messageStop,
+			},
+			wantText:  "Reading it.",
+			wantCalls: []string{`toolu_1 fs__read {"lines":10,"path":"a.txt"}`, `toolu_2 fs__list {}`},
+			wantStop:  "tool_use",
+			wantUsage: [2]int{25, 40},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := streamServer(t, tt.events)
+			defer server.Close()
+
+			provider := NewProvider("test-key", server.URL, "claude-3-5-sonnet-latest")
+			events, err := provider.StreamMessage(context.Background(), "", []llm.Message{userMessage("Hi")}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := collectStream(events)
+			if result.err != nil {
+				t.Fatal(result.err)
+			}
+
+			if result.text != tt.wantText {
+				t.Errorf("streamed text = %q, want %q", result.text, tt.wantText)
+			}
+			if result.done == nil {
+				t.Fatal("stream ended without a message")
+			}
+			if got := result.done.GetContent(); got != tt.wantText {
+				t.Errorf("content = %q, want %q", got, tt.wantText)
+			}
+
+			var calls []string
+			for _, call := range result.done.GetToolCalls() {
+				args, _ := json.Marshal(call.GetArguments())
+				calls = append(calls, call.GetID()+" "+call.GetName()+" "+string(args))
+			}
+			if strings.Join(calls, "|") != strings.Join(tt.wantCalls, "|") {
+				t.Errorf("tool calls = %q, want %q", calls, tt.wantCalls)
+			}
+			// Every call is announced with its name before its arguments
+			var announced []string
+			for _, event := range result.toolCalls {
+				if event.ToolName != "" {
+					announced = append(announced, event.ToolCallID)
+				}
+			}
+			if len(announced) != len(tt.wantCalls) {
+				t.Errorf("announced tool calls = %q", announced)
+			}
+
+			msg := result.done.(*Message).Msg
+			if msg.StopReason == nil || *msg.StopReason != tt.wantStop {
+				t.Errorf("stop reason = %v, want %s", msg.StopReason, tt.wantStop)
+			}
+			if input, output := result.done.GetUsage(); input != tt.wantUsage[0] || output != tt.wantUsage[1] {
+				t.Errorf("usage = %d, %d, want %v", input, output, tt.wantUsage)
+			}
+		})
+	}
+}
+
+func TestStreamMessageErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		events  []string
+		want    error
+		wantMsg string
+	}{
+		{
+			name: "no message_stop",
+			events: []string{
+				messageStart,
+				`{"type": "content_block_start", "index": 0, "content_block": {"type": "text", "text": ""}}`,
+				`{"type": "content_block_delta", "index": 0, "delta": {"type": "text_delta", "text": "Half an"}}`,
+			},
+			want:    llm.ErrTransient,
+			wantMsg: "stream ended before the response was complete",
+		},
+		{
+			name: "error event",
+			events: []string{
+				messageStart,
+				`{"type": "error", "error": {"type": "overloaded_error", "message": "Overloaded"}}`,
+			},
+			want:    llm.ErrOverloaded,
+			wantMsg: "Overloaded",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := streamServer(t, tt.events)
+			defer server.Close()
+
+			provider := NewProvider("test-key", server.URL, "claude-3-5-sonnet-latest")
+			events, err := provider.StreamMessage(context.Background(), "", []llm.Message{userMessage("Hi")}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := collectStream(events)
+			if result.done != nil {
+				t.Errorf("stream gave a message despite the error")
+			}
+
+			var providerErr *llm.ProviderError
+			if !errors.As(result.err, &providerErr) || !providerErr.Retryable() {
+				t.Fatalf("error = %v, want a retryable *llm.ProviderError", result.err)
+			}
+			if !errors.Is(result.err, tt.want) || !strings.Contains(result.err.Error(), tt.wantMsg) {
+				t.Errorf("error = %v, want %v with %q", result.err, tt.want, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestCreateRequestThinking(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+	floatPtr := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name            string
+		options         llm.GenerationOptions
+		wantBudget      int
+		wantMaxTokens   int
+		wantTemperature *float64
+		wantTopP        *float64
+	}{
+		{
+			name:            "no thinking",
+			options:         llm.GenerationOptions{Temperature: floatPtr(0.2), TopP: floatPtr(0.5)},
+			wantMaxTokens:   llm.DefaultMaxTokens,
+			wantTemperature: floatPtr(0.2),
+			wantTopP:        floatPtr(0.5),
+		},
+		{
+			name:          "budget below max_tokens",
+			options:       llm.GenerationOptions{ThinkingBudget: intPtr(2048)},
+			wantBudget:    2048,
+			wantMaxTokens: llm.DefaultMaxTokens,
+		},
+		{
+			name:          "budget equal to max_tokens",
+			options:       llm.GenerationOptions{ThinkingBudget: intPtr(4096), MaxTokens: intPtr(4096)},
+			wantBudget:    4096,
+			wantMaxTokens: 4096 + llm.DefaultMaxTokens,
+		},
+		{
+			name:          "budget above max_tokens",
+			options:       llm.GenerationOptions{ThinkingBudget: intPtr(16000), MaxTokens: intPtr(1024)},
+			wantBudget:    16000,
+			wantMaxTokens: 16000 + llm.DefaultMaxTokens,
+		},
+		{
+			name:          "temperature and low top_p dropped",
+			options:       llm.GenerationOptions{ThinkingBudget: intPtr(2048), Temperature: floatPtr(0), TopP: floatPtr(0.9)},
+			wantBudget:    2048,
+			wantMaxTokens: llm.DefaultMaxTokens,
+		},
+		{
+			name:          "high top_p kept",
+			options:       llm.GenerationOptions{ThinkingBudget: intPtr(2048), Temperature: floatPtr(1), TopP: floatPtr(0.95)},
+			wantBudget:    2048,
+			wantMaxTokens: llm.DefaultMaxTokens,
+			wantTopP:      floatPtr(0.95),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := NewProvider("test-key", "", "claude-3-7-sonnet-latest")
+			provider.SetGenerationOptions(tt.options)
+			req := provider.createRequest("", []llm.Message{userMessage("Hi")}, nil)
+
+			if tt.wantBudget == 0 {
+				if req.Thinking != nil {
+					t.Errorf("thinking = %+v, want none", req.Thinking)
+				}
+			} else if req.Thinking == nil || req.Thinking.Type != "enabled" || req.Thinking.BudgetTokens != tt.wantBudget {
+				t.Errorf("thinking = %+v, want a budget of %d", req.Thinking, tt.wantBudget)
+			}
+			if req.MaxTokens != tt.wantMaxTokens {
+				t.Errorf("max_tokens = %d, want %d", req.MaxTokens, tt.wantMaxTokens)
+			}
+			if !equalFloat(req.Temperature, tt.wantTemperature) {
+				t.Errorf("temperature = %v, want %v", req.Temperature, tt.wantTemperature)
+			}
+			if !equalFloat(req.TopP, tt.wantTopP) {
+				t.Errorf("top_p = %v, want %v", req.TopP, tt.wantTopP)
+			}
+		})
+	}
+}
+
+func equalFloat(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func TestCreateRequestThinkingBlocks(t *testing.T) {
+	budget := 2048
+	messages := []llm.Message{
+		userMessage("What is in a.txt?"),
+		&history.HistoryMessage{Role: "assistant", Content: []history.ContentBlock{
+			{Type: "thinking", Thinking: "I should read it.", Signature: "sig"},
+			{Type: "redacted_thinking", Data: "encrypted"},
+			{Type: "text", Text: "Reading it."},
+			{Type: "tool_use", ID: "toolu_1", Name: "fs__read", Input: json.RawMessage(`{"path":"a.txt"}`)},
+		}},
+	}
+
+	// The blocks go back first and unchanged while thinking is enabled
+	provider := NewProvider("test-key", "", "claude-3-7-sonnet-latest")
+	provider.SetGenerationOptions(llm.GenerationOptions{ThinkingBudget: &budget})
+	content := provider.createRequest("", messages, nil).Messages[1].Content
+	if len(content) != 4 {
+		t.Fatalf("content = %+v", content)
+	}
+	if block := content[0]; block.Type != "thinking" || block.Thinking != "I should read it." || block.Signature != "sig" {
+		t.Errorf("first block = %+v, want the thinking block", block)
+	}
+	if block := content[1]; block.Type != "redacted_thinking" || block.Data != "encrypted" {
+		t.Errorf("second block = %+v, want the redacted_thinking block", block)
+	}
+
+	// Without thinking the API rejects them
+	provider.SetGenerationOptions(llm.GenerationOptions{})
+	content = provider.createRequest("", messages, nil).Messages[1].Content
+	if len(content) != 2 || content[0].Type != "text" || content[1].Type != "tool_use" {
+		t.Errorf("content = %+v, want only the text and tool_use blocks", content)
+	}
+}
+
+// cacheBreakpoints lists where a request has cache_control set, e.g.
+// "tools[1]" or "messages[2][0]"
+func cacheBreakpoints(req CreateRequest) []string {
+	var breakpoints []string
+	for i, tool := range req.Tools {
+		if tool.CacheControl != nil {
+			breakpoints = append(breakpoints, fmt.Sprintf("tools[%d]", i))
+		}
+	}
+	for i, block := range req.System {
+		if block.CacheControl != nil {
+			breakpoints = append(breakpoints, fmt.Sprintf("system[%d]", i))
+		}
+	}
+	for i, msg := range req.Messages {
+		for j, block := range msg.Content {
+			if block.CacheControl != nil {
+				breakpoints = append(breakpoints, fmt.Sprintf("messages[%d][%d]", i, j))
+			}
+		}
+	}
+	return breakpoints
+}
+
+func TestCacheBreakpoints(t *testing.T) {
+	system := &history.HistoryMessage{Role: "system", Content: []history.ContentBlock{{Type: "text", Text: "Be brief."}}}
+	toolUse := &history.HistoryMessage{Role: "assistant", Content: []history.ContentBlock{
+		{Type: "text", Text: "Reading it."},
+		{Type: "tool_use", ID: "toolu_1", Name: "fs__read", Input: json.RawMessage(`{"path":"a.txt"}`)},
+	}}
+	toolResult := &history.HistoryMessage{Role: "user", Content: []history.ContentBlock{
+		{Type: "tool_result", ToolUseID: "toolu_1", Content: "hello"},
+	}}
+	answer := &history.HistoryMessage{Role: "assistant", Content: []history.ContentBlock{{Type: "text", Text: "It says hello."}}}
+	tools := []llm.Tool{
+		{Name: "fs__read", InputSchema: llm.Schema{Type: "object"}},
+		{Name: "fs__list", InputSchema: llm.Schema{Type: "object"}},
+	}
+
+	tests := []struct {
+		name     string
+		messages []llm.Message
+		prompt   string
+		tools    []llm.Tool
+		want     []string
+	}{
+		{
+			name:     "first prompt",
+			messages: []llm.Message{system, userMessage("What is in a.txt?")},
+			tools:    tools,
+			want:     []string{"tools[1]", "system[0]", "messages[0][0]"},
+		},
+		{
+			name:     "tool result",
+			messages: []llm.Message{system, userMessage("What is in a.txt?"), toolUse, toolResult},
+			tools:    tools,
+			want:     []string{"tools[1]", "system[0]", "messages[0][0]", "messages[2][0]"},
+		},
+		{
+			// The assistant message in between gets no breakpoint
+			name:     "next prompt",
+			messages: []llm.Message{system, userMessage("What is in a.txt?"), toolUse, toolResult, answer},
+			prompt:   "And b.txt?",
+			tools:    tools,
+			want:     []string{"tools[1]", "system[0]", "messages[2][0]", "messages[4][0]"},
+		},
+		{
+			name:     "no tools or system prompt",
+			messages: []llm.Message{userMessage("What is in a.txt?"), toolUse, toolResult},
+			want:     []string{"messages[0][0]", "messages[2][0]"},
+		},
+		{
+			name:   "empty history",
+			prompt: "Hi",
+			tools:  tools[:1],
+			want:   []string{"tools[0]", "messages[0][0]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := NewProvider("test-key", "", "claude-3-5-sonnet-latest")
+			got := cacheBreakpoints(provider.createRequest(tt.prompt, tt.messages, tt.tools))
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("breakpoints = %v, want %v", got, tt.want)
+			}
+			if len(got) > 4 {
+				t.Errorf("%d breakpoints, the API allows 4", len(got))
+			}
+		})
+	}
+}
+
+func TestCacheBreakpointsSkipThinking(t *testing.T) {
+	// Thinking blocks and empty text cannot carry a breakpoint, so it goes
+	// on the last block that can
+	tests := []struct {
+		name string
+		last []ContentBlock
+		want []string
+	}{
+		{
+			name: "text between thinking",
+			last: []ContentBlock{
+				{Type: "thinking", Thinking: "A greeting.", Signature: "sig"},
+				{Type: "text", Text: "Hello."},
+				{Type: "text", Text: ""},
+				{Type: "redacted_thinking", Data: "encrypted"},
+			},
+			want: []string{"messages[0][0]", "messages[1][1]"},
+		},
+		{
+			// The user message before is then the last one
+			name: "only thinking",
+			last: []ContentBlock{{Type: "thinking", Thinking: "A greeting.", Signature: "sig"}},
+			want: []string{"messages[0][0]"},
+		},
+	}
+
+	for _, tt := range tests {
+		messages := []MessageParam{
+			{Role: "user", Content: []ContentBlock{{Type: "text", Text: "Hi"}}},
+			{Role: "assistant", Content: tt.last},
+		}
+		addCacheBreakpoints(nil, messages, nil)
+
+		got := cacheBreakpoints(CreateRequest{Messages: messages})
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%s: breakpoints = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestToolResultContent(t *testing.T) {
+	content := []mcp.Content{
+		mcp.NewTextContent("Here is the chart."),
+		mcp.NewImageContent("aW1hZ2U=", "image/png"),
+		mcp.NewEmbeddedResource(mcp.TextResourceContents{URI: "file:///a.txt", MIMEType: "text/plain", Text: "hello"}),
+		mcp.NewEmbeddedResource(mcp.BlobResourceContents{URI: "file:///b.jpg", MIMEType: "image/jpeg", Blob: "anBlZw=="}),
+		mcp.NewEmbeddedResource(mcp.BlobResourceContents{URI: "file:///c.pdf", MIMEType: "application/pdf", Blob: "cGRm"}),
+	}
+	// Results loaded from a saved session are generic JSON
+	data, _ := json.Marshal(content)
+	var loaded []interface{}
+	json.Unmarshal(data, &loaded)
+
+	tests := []struct {
+		name  string
+		model string
+		want  []string
+	}{
+		{
+			name:  "vision",
+			model: "claude-3-5-sonnet-latest",
+			want: []string{
+				"text Here is the chart.",
+				"image image/png aW1hZ2U=",
+				"text Resource file:///a.txt:\nhello",
+				"image image/jpeg anBlZw==",
+				"text [Resource file:///c.pdf omitted: binary content of type application/pdf]",
+			},
+		},
+		{
+			name:  "no vision",
+			model: "claude-2.1",
+			want: []string{
+				"text Here is the chart.",
+				"text " + history.ImagePlaceholder("image/png"),
+				"text Resource file:///a.txt:\nhello",
+				"text " + history.ImagePlaceholder("image/jpeg"),
+				"text [Resource file:///c.pdf omitted: binary content of type application/pdf]",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		provider := NewProvider("test-key", "", tt.model)
+		for origin, resultContent := range map[string]interface{}{"mcp": content, "loaded": loaded} {
+			messages := []llm.Message{&history.HistoryMessage{Role: "user", Content: []history.ContentBlock{{
+				Type:      "tool_result",
+				ToolUseID: "toolu_1",
+				Content:   resultContent,
+			}}}}
+			result := provider.createRequest("", messages, nil).Messages[0].Content[0]
+			if result.Type != "tool_result" || result.ToolUseID != "toolu_1" {
+				t.Fatalf("%s, %s: block = %+v", tt.name, origin, result)
+			}
+
+			blocks, _ := result.Content.([]ContentBlock)
+			var got []string
+			for _, block := range blocks {
+				switch block.Type {
+				case "image":
+					got = append(got, "image "+block.Source.MediaType+" "+block.Source.Data)
+				default:
+					got = append(got, block.Type+" "+block.Text)
+				}
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("%s, %s: content = %q, want %q", tt.name, origin, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestToolResultContentError(t *testing.T) {
+	// An error without content parts keeps its text
+	provider := NewProvider("test-key", "", "claude-3-5-sonnet-latest")
+	messages := []llm.Message{&history.HistoryMessage{Role: "user", Content: []history.ContentBlock{{
+		Type:      "tool_result",
+		ToolUseID: "toolu_1",
+		Text:      "Error: file not found",
+		IsError:   true,
+	}}}}
+	result := provider.createRequest("", messages, nil).Messages[0].Content[0]
+	blocks, _ := result.Content.([]ContentBlock)
+	if !result.IsError || len(blocks) != 1 || blocks[0].Text != "Error: file not found" {
+		t.Errorf("block = %+v, want an error with its text", result)
+	}
+}
@@ -11,9 +11,32 @@ import (
 
 type CreateRequest struct {
 	Model     string         `json:"model"`
+	System    []ContentBlock `json:"system,omitempty"`
 	Messages  []MessageParam `json:"messages"`
 	MaxTokens int            `json:"max_tokens"`
 	Tools     []Tool         `json:"tools,omitempty"`
+	Stream    bool           `json:"stream,omitempty"`
+
+	Temperature   *float64 `json:"temperature,omitempty"`
+	TopP          *float64 `json:"top_p,omitempty"`
+	StopSequences []string `json:"stop_sequences,omitempty"`
+
+	Thinking *ThinkingConfig `json:"thinking,omitempty"`
+}
+
+// CacheControl marks the end of a prompt prefix to cache
+type CacheControl struct {
+	Type string `json:"type"`
+}
+
+// ephemeralCache is the only cache type, kept for five minutes after its
+// last use
+var ephemeralCache = &CacheControl{Type: "ephemeral"}
+
+// ThinkingConfig enables extended thinking
+type ThinkingConfig struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens"`
 }
 
 type MessageParam struct {
@@ -29,18 +52,41 @@ type ContentBlock struct {
 	Name      string          `json:"name,omitempty"`
 	Input     json.RawMessage `json:"input,omitempty"`
 	Content   interface{}     `json:"content,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
+
+	// Thinking and Signature are set on thinking blocks, Data on
+	// redacted_thinking blocks
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Data      string `json:"data,omitempty"`
+
+	// Source is set on image and document blocks, Title on documents
+	Source *BlockSource `json:"source,omitempty"`
+	Title  string       `json:"title,omitempty"`
+
+	CacheControl *CacheControl `json:"cache_control,omitempty"`
+}
+
+// BlockSource holds the data of an image or document block
+type BlockSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
 }
 
 type Tool struct {
 	Name        string      `json:"name"`
 	Description string      `json:"description"`
 	InputSchema InputSchema `json:"input_schema"`
+
+	CacheControl *CacheControl `json:"cache_control,omitempty"`
 }
 
 type InputSchema struct {
 	Type       string                 `json:"type"`
 	Properties map[string]interface{} `json:"properties"`
 	Required   []string               `json:"required,omitempty"`
+	Defs       map[string]interface{} `json:"$defs,omitempty"`
 }
 
 type APIMessage struct {
@@ -57,6 +103,35 @@ type APIMessage struct {
 type Usage struct {
 	InputTokens  int `json:"input_tokens"`
 	OutputTokens int `json:"output_tokens"`
+
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens,omitempty"`
+}
+
+type ErrorDetail struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// StreamEvent is a server-sent event of a streaming response
+type StreamEvent struct {
+	Type         string        `json:"type"`
+	Index        int           `json:"index"`
+	Message      *APIMessage   `json:"message,omitempty"`
+	ContentBlock *ContentBlock `json:"content_block,omitempty"`
+	Delta        *StreamDelta  `json:"delta,omitempty"`
+	Usage        *Usage        `json:"usage,omitempty"`
+	Error        *ErrorDetail  `json:"error,omitempty"`
+}
+
+type StreamDelta struct {
+	Type         string  `json:"type"`
+	Text         string  `json:"text,omitempty"`
+	PartialJSON  string  `json:"partial_json,omitempty"`
+	Thinking     string  `json:"thinking,omitempty"`
+	Signature    string  `json:"signature,omitempty"`
+	StopReason   *string `json:"stop_reason,omitempty"`
+	StopSequence *string `json:"stop_sequence,omitempty"`
 }
 
 // Message implements the llm.Message interface
@@ -150,10 +225,31 @@ func (m *Message) GetToolResponseID() string {
 	return ""
 }
 
+// GetThinking returns the thinking and redacted_thinking blocks
+func (m *Message) GetThinking() []llm.ThinkingBlock {
+	var blocks []llm.ThinkingBlock
+	for _, block := range m.Msg.Content {
+		switch block.Type {
+		case "thinking":
+			blocks = append(blocks, llm.ThinkingBlock{
+				Thinking:  block.Thinking,
+				Signature: block.Signature,
+			})
+		case "redacted_thinking":
+			blocks = append(blocks, llm.ThinkingBlock{RedactedData: block.Data})
+		}
+	}
+	return blocks
+}
+
 func (m *Message) GetUsage() (input int, output int) {
 	return m.Msg.Usage.InputTokens, m.Msg.Usage.OutputTokens
 }
 
+func (m *Message) GetCacheUsage() (creation int, read int) {
+	return m.Msg.Usage.CacheCreationInputTokens, m.Msg.Usage.CacheReadInputTokens
+}
+
 // ToolCall implements the llm.ToolCall interface
 type ToolCall struct {
 	id   string
@@ -1,11 +1,15 @@
 package azure
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
+
+	"github.com/vincent-pli/mcphost/pkg/llm"
 )
 
 type Client struct {
@@ -27,6 +31,70 @@ func NewClient(apiKey string, azureEndpoint string, azureDeployment string, apiV
 }
 
 func (c *Client) CreateChatCompletion(ctx context.Context, req CreateRequest) (*APIResponse, error) {
+	resp, err := c.doRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var response APIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return &response, nil
+}
+
+// StreamChatCompletion sends a streaming request and calls handler for every
+// chunk until the stream ends or handler returns an error. A stream that
+// ends without [DONE] is incomplete.
+func (c *Client) StreamChatCompletion(ctx context.Context, req CreateRequest, handler func(StreamChunk) error) error {
+	req.Stream = true
+	resp, err := c.doRequest(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	complete := false
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			complete = true
+			break
+		}
+
+		var chunk StreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("error decoding stream chunk: %w", err)
+		}
+
+		if chunk.Error != nil {
+			return llm.NewProviderError(0, chunk.Error.errorType(), chunk.Error.Message)
+		}
+
+		if err := handler(chunk); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return llm.NewConnectionError("error reading stream", err)
+	}
+	if !complete {
+		return llm.NewIncompleteStreamError()
+	}
+
+	return nil
+}
+
+func (c *Client) doRequest(ctx context.Context, req CreateRequest) (*http.Response, error) {
 	body, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("error marshaling request: %w", err)
@@ -47,30 +115,20 @@ func (c *Client) CreateChatCompletion(ctx context.Context, req CreateRequest) (*
 
 	resp, err := c.client.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("error making request: %w", err)
+		return nil, llm.NewConnectionError("error making request", err)
 	}
 
-	defer resp.Body.Close()
-
 	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+
 		var errResp struct {
-			Error struct {
-				Message string `json:"message"`
-				Type    string `json:"type"`
-				Code    string `json:"code"`
-			} `json:"error"`
+			Error ErrorDetail `json:"error"`
 		}
 		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
-			return nil, fmt.Errorf("error response with status %d", resp.StatusCode)
+			return nil, llm.NewHTTPError(resp, "", "")
 		}
-
-		return nil, fmt.Errorf("%s: %s", errResp.Error.Type, errResp.Error.Message)
-	}
-
-	var response APIResponse
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return nil, fmt.Errorf("error decoding response: %w", err)
+		return nil, llm.NewHTTPError(resp, errResp.Error.errorType(), errResp.Error.Message)
 	}
 
-	return &response, nil
+	return resp, nil
 }
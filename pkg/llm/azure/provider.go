@@ -12,8 +12,9 @@ import (
 )
 
 type Provider struct {
-	client *Client
-	model  string
+	client  *Client
+	model   string
+	options llm.GenerationOptions
 }
 
 func convertSchema(schema llm.Schema) map[string]interface{} {
@@ -23,11 +24,15 @@ func convertSchema(schema llm.Schema) map[string]interface{} {
 		required = []string{}
 	}
 
-	return map[string]interface{}{
+	parameters := map[string]interface{}{
 		"type":       schema.Type,
 		"properties": schema.Properties,
 		"required":   required,
 	}
+	if len(schema.Defs) > 0 {
+		parameters["$defs"] = schema.Defs
+	}
+	return parameters
 }
 
 func NewProvider(apiKey string, azure_endpoint string, azure_deployment string, api_version string, model string) *Provider {
@@ -43,6 +48,127 @@ func (p *Provider) CreateMessage(
 	messages []llm.Message,
 	tools []llm.Tool,
 ) (llm.Message, error) {
+	req, err := p.createRequest(prompt, messages, tools)
+	if err != nil {
+		return nil, err
+	}
+
+	// Make the API call
+	resp, err := p.client.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("no choices in response")
+	}
+
+	return &Message{Resp: resp, Choice: &resp.Choices[0]}, nil
+}
+
+func (p *Provider) StreamMessage(
+	ctx context.Context,
+	prompt string,
+	messages []llm.Message,
+	tools []llm.Tool,
+) (<-chan llm.StreamEvent, error) {
+	req, err := p.createRequest(prompt, messages, tools)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan llm.StreamEvent)
+	send := func(event llm.StreamEvent) error {
+		select {
+		case events <- event:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	go func() {
+		defer close(events)
+
+		resp := &APIResponse{}
+		choice := Choice{Message: MessageParam{Role: "assistant"}}
+		var content strings.Builder
+
+		err := p.client.StreamChatCompletion(ctx, req, func(chunk StreamChunk) error {
+			resp.ID = chunk.ID
+			resp.Object = chunk.Object
+			resp.Created = chunk.Created
+			resp.Model = chunk.Model
+			if chunk.Usage != nil {
+				resp.Usage = *chunk.Usage
+			}
+
+			for _, c := range chunk.Choices {
+				if c.Index != 0 {
+					continue
+				}
+				if c.FinishReason != nil {
+					choice.FinishReason = *c.FinishReason
+				}
+				if c.Delta.Content != nil && *c.Delta.Content != "" {
+					content.WriteString(*c.Delta.Content)
+					if err := send(llm.StreamEvent{
+						Type: llm.StreamEventText,
+						Text: *c.Delta.Content,
+					}); err != nil {
+						return err
+					}
+				}
+
+				// Tool calls arrive in fragments keyed by their index
+				for _, delta := range c.Delta.ToolCalls {
+					for len(choice.Message.ToolCalls) <= delta.Index {
+						choice.Message.ToolCalls = append(choice.Message.ToolCalls, ToolCall{Type: "function"})
+					}
+					call := &choice.Message.ToolCalls[delta.Index]
+					if delta.ID != "" {
+						call.ID = delta.ID
+					}
+					call.Function.Name += delta.Function.Name
+					call.Function.Arguments += delta.Function.Arguments
+
+					if err := send(llm.StreamEvent{
+						Type:          llm.StreamEventToolCall,
+						ToolCallID:    call.ID,
+						ToolName:      delta.Function.Name,
+						ToolArguments: delta.Function.Arguments,
+					}); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			_ = send(llm.StreamEvent{Type: llm.StreamEventError, Err: err})
+			return
+		}
+
+		if content.Len() > 0 {
+			text := content.String()
+			choice.Message.Content = &text
+		}
+		resp.Choices = []Choice{choice}
+
+		_ = send(llm.StreamEvent{
+			Type:    llm.StreamEventDone,
+			Message: &Message{Resp: resp, Choice: &resp.Choices[0]},
+		})
+	}()
+
+	return events, nil
+}
+
+func (p *Provider) createRequest(
+	prompt string,
+	messages []llm.Message,
+	tools []llm.Tool,
+) (CreateRequest, error) {
 	log.Debug("creating message",
 		"prompt", prompt,
 		"num_messages", len(messages),
@@ -67,6 +193,19 @@ func (p *Provider) CreateMessage(
 			param.Content = &content
 		}
 
+		// Attached files turn the content into a list of parts
+		if historyMsg, ok := msg.(*history.HistoryMessage); ok {
+			if attachments := historyMsg.GetAttachments(); len(attachments) > 0 {
+				param.Parts = attachmentParts(attachments)
+				if param.Content != nil {
+					param.Parts = append(param.Parts, ContentPart{
+						Type: "text",
+						Text: *param.Content,
+					})
+				}
+			}
+		}
+
 		// Handle function/tool calls
 		toolCalls := msg.GetToolCalls()
 		if len(toolCalls) > 0 {
@@ -77,7 +216,7 @@ func (p *Provider) CreateMessage(
 			for i, call := range toolCalls {
 				args, err := json.Marshal(call.GetArguments())
 				if err != nil {
-					return nil, fmt.Errorf(
+					return CreateRequest{}, fmt.Errorf(
 						"error marshaling function arguments: %w",
 						err,
 					)
@@ -100,33 +239,13 @@ func (p *Provider) CreateMessage(
 				"tool_call_id", msg.GetToolResponseID(),
 				"raw_message", msg)
 
-			// Extract content from tool response
-			var contentStr string
-			if content := msg.GetContent(); content != "" {
-				contentStr = content
-			} else {
-				// Try to extract text from history message content blocks
-				if historyMsg, ok := msg.(*history.HistoryMessage); ok {
-					var texts []string
-					for _, block := range historyMsg.Content {
-						if block.Type == "tool_result" {
-							if block.Text != "" {
-								texts = append(texts, block.Text)
-							} else if contentArray, ok := block.Content.([]interface{}); ok {
-								for _, item := range contentArray {
-									if contentMap, ok := item.(map[string]interface{}); ok {
-										if text, ok := contentMap["text"]; ok {
-											texts = append(texts, fmt.Sprint(text))
-										}
-									}
-								}
-							}
-						}
-					}
-					contentStr = strings.Join(texts, "\n")
-				}
+			// Every result of a history message becomes a tool message
+			if historyMsg, ok := msg.(*history.HistoryMessage); ok {
+				openaiMessages = append(openaiMessages, p.toolResultMessages(historyMsg)...)
+				continue
 			}
 
+			contentStr := msg.GetContent()
 			if contentStr == "" {
 				contentStr = "No content returned from function"
 			}
@@ -167,23 +286,102 @@ func (p *Provider) CreateMessage(
 		}
 	}
 
-	// Make the API call
-	resp, err := p.client.CreateChatCompletion(ctx, CreateRequest{
+	return CreateRequest{
 		Model:       p.model,
 		Messages:    openaiMessages,
 		Tools:       openaiTools,
-		MaxTokens:   4096,
-		Temperature: 0.7,
-	})
-	if err != nil {
-		return nil, err
+		MaxTokens:   p.options.MaxTokensOrDefault(),
+		Temperature: p.options.Temperature,
+		TopP:        p.options.TopP,
+		Stop:        p.options.Stop,
+	}, nil
+}
+
+// attachmentParts converts attached files to content parts. Text files are
+// inlined, images and other files are sent as data URLs.
+func attachmentParts(attachments []history.ContentBlock) []ContentPart {
+	parts := make([]ContentPart, 0, len(attachments))
+	for _, block := range attachments {
+		switch {
+		case block.Type == "image":
+			parts = append(parts, ContentPart{
+				Type:     "image_url",
+				ImageURL: &ImageURL{URL: "data:" + block.MediaType + ";base64," + block.Data},
+			})
+		case block.Data != "":
+			parts = append(parts, ContentPart{
+				Type: "file",
+				File: &FilePart{
+					Filename: block.Name,
+					FileData: "data:" + block.MediaType + ";base64," + block.Data,
+				},
+			})
+		default:
+			parts = append(parts, ContentPart{
+				Type: "text",
+				Text: fmt.Sprintf("Contents of %s:\n\n%s", block.Name, block.Text),
+			})
+		}
 	}
+	return parts
+}
 
-	if len(resp.Choices) == 0 {
-		return nil, fmt.Errorf("no choices in response")
+// toolResultMessages converts the tool results of a message to one tool
+// message each. Tool messages only take text, so images follow in a user
+// message, or are replaced with a placeholder for models without vision.
+func (p *Provider) toolResultMessages(msg *history.HistoryMessage) []MessageParam {
+	vision := llm.SupportsVision(p.model)
+
+	var messages []MessageParam
+	var images []ContentPart
+	for _, block := range msg.Content {
+		if block.Type != "tool_result" {
+			continue
+		}
+
+		var texts []string
+		for _, part := range history.ToolResultParts(block) {
+			switch {
+			case part.IsImage() && vision:
+				texts = append(texts, fmt.Sprintf("[%s image attached below]", part.MIMEType))
+				images = append(images, ContentPart{
+					Type:     "image_url",
+					ImageURL: &ImageURL{URL: "data:" + part.MIMEType + ";base64," + part.Data},
+				})
+			case part.IsImage():
+				texts = append(texts, history.ImagePlaceholder(part.MIMEType))
+			default:
+				texts = append(texts, part.Text)
+			}
+		}
+
+		content := strings.Join(texts, "\n")
+		if content == "" {
+			content = "No content returned from function"
+		}
+		messages = append(messages, MessageParam{
+			Role:       "tool",
+			Content:    &content,
+			ToolCallID: block.ToolUseID,
+		})
 	}
 
-	return &Message{Resp: resp, Choice: &resp.Choices[0]}, nil
+	if len(images) > 0 {
+		parts := append([]ContentPart{{
+			Type: "text",
+			Text: "Images returned by the tool calls above:",
+		}}, images...)
+		messages = append(messages, MessageParam{
+			Role:  "user",
+			Parts: parts,
+		})
+	}
+	return messages
+}
+
+// SetGenerationOptions sets the options used for all following requests
+func (p *Provider) SetGenerationOptions(options llm.GenerationOptions) {
+	p.options = options
 }
 
 func (p *Provider) SupportsTools() bool {
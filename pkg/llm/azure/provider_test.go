@@ -0,0 +1,156 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vincent-pli/mcphost/pkg/history"
+	"github.com/vincent-pli/mcphost/pkg/llm"
+)
+
+// chunkServer answers every request with the recorded stream chunks, given
+// as the data of their server-sent events
+func chunkServer(t *testing.T, chunks []string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if want := "/openai/deployments/gpt-4o/chat/completions"; r.URL.Path != want {
+			t.Errorf("path = %s, want %s", r.URL.Path, want)
+		}
+		if got := r.Header.Get("api-key"); got != "test-key" {
+			t.Errorf("api-key = %q", got)
+		}
+		var req CreateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !req.Stream {
+			t.Errorf("request = %+v, %v, want a streaming request", req, err)
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range chunks {
+			io.WriteString(w, "data: "+chunk+"\n\n")
+		}
+	}))
+}
+
+func TestStreamMessage(t *testing.T) {
+	tests := []struct {
+		name       string
+		chunks     []string
+		wantText   string
+		wantCalls  []string
+		wantFinish string
+		wantUsage  [2]int
+		wantErr    error
+	}{
+		{
+			name: "text",
+			chunks: []string{
+				`{"id": "1", "choices": [{"index": 0, "delta": {"role": "assistant", "content": ""}}]}`,
+				`{"id": "1", "choices": [{"index": 0, "delta": {"content": "Hello"}}]}`,
+				`{"id": "1", "choices": [{"index": 0, "delta": {"content": ", world."}}]}`,
+				`{"id": "1", "choices": [{"index": 0, "delta": {}, "finish_reason": "stop"}]}`,
+				`{"id": "1", "choices": [], "usage": {"prompt_tokens": 12, "completion_tokens": 5, "total_tokens": 17}}`,
+				`[DONE]`,
+			},
+			wantText:   "Hello, world.",
+			wantFinish: "stop",
+			wantUsage:  [2]int{12, 5},
+		},
+		{
+			name: "tool calls split across deltas",
+			chunks: []string{
+				`{"id": "2", "choices": [{"index": 0, "delta": {"role": "assistant", "tool_calls": [{"index": 0, "id": "call_1", "type": "function", "function": {"name": "fs__read", "arguments": ""}}]}}]}`,
+				`{"id": "2", "choices": [{"index": 0, "delta": {"tool_calls": [{"index": 0, "function": {"arguments": "{\"path\": "}}]}}]}`,
+				`{"id": "2", "choices": [{"index": 0, "delta": {"tool_calls": [{"index": 0, "function": {"arguments": "\"a.txt\"}"}}]}}]}`,
+				`{"id": "2", "choices": [{"index": 0, "delta": {"tool_calls": [{"index": 1, "id": "call_2", "type": "function", "function": {"name": "fs__list", "arguments": "{}"}}]}}]}`,
+				`{"id": "2", "choices": [{"index": 0, "delta": {}, "finish_reason": "tool_calls"}]}`,
+				`[DONE]`,
+			},
+			wantCalls:  []string{`call_1 fs__read {"path":"a.txt"}`, `call_2 fs__list {}`},
+			wantFinish: "tool_calls",
+		},
+		{
+			name: "no [DONE]",
+			chunks: []string{
+				`{"id": "3", "choices": [{"index": 0, "delta": {"role": "assistant", "content": "Half an"}}]}`,
+			},
+			wantErr: llm.ErrTransient,
+		},
+		{
+			name: "error chunk",
+			chunks: []string{
+				`{"id": "4", "choices": [{"index": 0, "delta": {"content": "Hi"}}]}`,
+				`{"error": {"message": "Rate limit reached", "type": "requests", "code": "429"}}`,
+			},
+			wantErr: llm.ErrRateLimited,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := chunkServer(t, tt.chunks)
+			defer server.Close()
+
+			provider := NewProvider("test-key", server.URL, "gpt-4o", "2024-10-21", "gpt-4o")
+			messages := []llm.Message{&history.HistoryMessage{
+				Role:    "user",
+				Content: []history.ContentBlock{{Type: "text", Text: "Hi"}},
+			}}
+			events, err := provider.StreamMessage(context.Background(), "", messages, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var text string
+			var done llm.Message
+			var streamErr error
+			for event := range events {
+				switch event.Type {
+				case llm.StreamEventText:
+					text += event.Text
+				case llm.StreamEventError:
+					streamErr = event.Err
+				case llm.StreamEventDone:
+					done = event.Message
+				}
+			}
+
+			if tt.wantErr != nil {
+				var providerErr *llm.ProviderError
+				if !errors.As(streamErr, &providerErr) || !errors.Is(streamErr, tt.wantErr) || !providerErr.Retryable() {
+					t.Errorf("error = %v, want a retryable %v", streamErr, tt.wantErr)
+				}
+				if done != nil {
+					t.Error("stream gave a message despite the error")
+				}
+				return
+			}
+			if streamErr != nil {
+				t.Fatal(streamErr)
+			}
+
+			if text != tt.wantText || done.GetContent() != tt.wantText {
+				t.Errorf("streamed text = %q, content = %q, want %q", text, done.GetContent(), tt.wantText)
+			}
+			var calls []string
+			for _, call := range done.GetToolCalls() {
+				args, _ := json.Marshal(call.GetArguments())
+				calls = append(calls, call.GetID()+" "+call.GetName()+" "+string(args))
+			}
+			if strings.Join(calls, "|") != strings.Join(tt.wantCalls, "|") {
+				t.Errorf("tool calls = %q, want %q", calls, tt.wantCalls)
+			}
+			if finish := done.(*Message).Choice.FinishReason; finish != tt.wantFinish {
+				t.Errorf("finish reason = %q, want %q", finish, tt.wantFinish)
+			}
+			if input, output := done.GetUsage(); input != tt.wantUsage[0] || output != tt.wantUsage[1] {
+				t.Errorf("usage = %d, %d, want %v", input, output, tt.wantUsage)
+			}
+		})
+	}
+}
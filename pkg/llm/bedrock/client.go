@@ -0,0 +1,214 @@
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vincent-pli/mcphost/pkg/llm"
+	"github.com/vincent-pli/mcphost/pkg/llm/anthropic"
+)
+
+// anthropicVersion is the version of the Messages API Bedrock expects in
+// the request body
+const anthropicVersion = "bedrock-2023-05-31"
+
+// Client sends Messages API requests to the Bedrock InvokeModel endpoints.
+// It implements anthropic.MessageClient.
+type Client struct {
+	// credsMu guards creds, which are loaded again before they expire
+	credsMu sync.Mutex
+	creds   Credentials
+	region  string
+	baseURL string
+	client  *http.Client
+}
+
+func NewClient(creds Credentials, region string, baseURL string) *Client {
+	if baseURL == "" {
+		baseURL = fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com", region)
+	}
+	return &Client{
+		creds:   creds,
+		region:  region,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  &http.Client{},
+	}
+}
+
+func (c *Client) CreateMessage(ctx context.Context, req anthropic.CreateRequest) (*anthropic.APIMessage, error) {
+	resp, err := c.doRequest(ctx, req, "invoke")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var message anthropic.APIMessage
+	if err := json.NewDecoder(resp.Body).Decode(&message); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return &message, nil
+}
+
+// StreamMessage sends a streaming request and calls handler for every
+// event until the stream ends or handler returns an error. Bedrock wraps
+// the events of the Anthropic streaming API in event stream messages. A
+// stream that ends without a message_stop event is incomplete.
+func (c *Client) StreamMessage(
+	ctx context.Context,
+	req anthropic.CreateRequest,
+	handler func(anthropic.StreamEvent) error,
+) error {
+	resp, err := c.doRequest(ctx, req, "invoke-with-response-stream")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	complete := false
+	for {
+		message, err := readEventMessage(resp.Body)
+		if errors.Is(err, io.EOF) {
+			if !complete {
+				return llm.NewIncompleteStreamError()
+			}
+			return nil
+		}
+		if err != nil {
+			return llm.NewConnectionError("error reading stream", err)
+		}
+
+		if message.Headers[":message-type"] == "exception" {
+			var detail ErrorDetail
+			_ = json.Unmarshal(message.Payload, &detail)
+			return llm.NewProviderError(0, message.Headers[":exception-type"], detail.Message)
+		}
+		if message.Headers[":event-type"] != "chunk" {
+			continue
+		}
+
+		var chunk chunkPayload
+		if err := json.Unmarshal(message.Payload, &chunk); err != nil {
+			return fmt.Errorf("error decoding stream chunk: %w", err)
+		}
+		var event anthropic.StreamEvent
+		if err := json.Unmarshal(chunk.Bytes, &event); err != nil {
+			return fmt.Errorf("error decoding stream event: %w", err)
+		}
+
+		if event.Type == "error" && event.Error != nil {
+			return llm.NewProviderError(0, event.Error.Type, event.Error.Message)
+		}
+		if event.Type == "message_stop" {
+			complete = true
+		}
+
+		if err := handler(event); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *Client) doRequest(ctx context.Context, req anthropic.CreateRequest, action string) (*http.Response, error) {
+	body, err := invokeBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
+		"POST",
+		fmt.Sprintf("%s/model/%s/%s", c.baseURL, uriEncode(req.Model), action),
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	creds, err := c.credentials()
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	if action == "invoke-with-response-stream" {
+		httpReq.Header.Set("Accept", "application/vnd.amazon.eventstream")
+	} else {
+		httpReq.Header.Set("Accept", "application/json")
+	}
+	signRequest(httpReq, body, creds, c.region, signingService, time.Now())
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, llm.NewConnectionError("error making request", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+
+		// The error type is sent as header, e.g. "ThrottlingException:http://..."
+		errType, _, _ := strings.Cut(resp.Header.Get("X-Amzn-ErrorType"), ":")
+
+		var errResp ErrorDetail
+		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
+			return nil, llm.NewHTTPError(resp, errType, "")
+		}
+		return nil, llm.NewHTTPError(resp, errType, errResp.Message)
+	}
+
+	return resp, nil
+}
+
+// credentials returns the credentials to sign a request with. Temporary
+// credentials, e.g. of a credential_process or an instance role, are
+// loaded again before they expire.
+func (c *Client) credentials() (Credentials, error) {
+	c.credsMu.Lock()
+	defer c.credsMu.Unlock()
+
+	if !c.creds.expiringSoon(time.Now()) {
+		return c.creds, nil
+	}
+
+	profile, err := loadProfile()
+	if err != nil {
+		return Credentials{}, err
+	}
+	creds, err := loadCredentials(profile)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("error refreshing AWS credentials: %w", err)
+	}
+	c.creds = creds
+	return creds, nil
+}
+
+// invokeBody converts a Messages API request to the InvokeModel body,
+// which takes the model in the URL and streaming from the endpoint
+func invokeBody(req anthropic.CreateRequest) ([]byte, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling request: %w", err)
+	}
+
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, fmt.Errorf("error marshaling request: %w", err)
+	}
+	delete(body, "model")
+	delete(body, "stream")
+	body["anthropic_version"], _ = json.Marshal(anthropicVersion)
+
+	data, err = json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling request: %w", err)
+	}
+	return data, nil
+}
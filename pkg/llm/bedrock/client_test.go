@@ -0,0 +1,272 @@
+package bedrock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vincent-pli/mcphost/pkg/llm"
+	"github.com/vincent-pli/mcphost/pkg/llm/anthropic"
+)
+
+const testModel = "anthropic.claude-3-5-sonnet-20240620-v1:0"
+
+var testCreds = Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret"}
+
+func testRequest() anthropic.CreateRequest {
+	return anthropic.CreateRequest{
+		Model:     testModel,
+		MaxTokens: 100,
+		Messages: []anthropic.MessageParam{{
+			Role:    "user",
+			Content: []anthropic.ContentBlock{{Type: "text", Text: "Hello"}},
+		}},
+	}
+}
+
+// checkInvokeRequest checks the signature, path and body of a request
+func checkInvokeRequest(t *testing.T, r *http.Request, action string) {
+	t.Helper()
+
+	wantPath := "/model/" + testModel + "/" + action
+	if r.Method != "POST" || r.URL.Path != wantPath {
+		t.Errorf("request = %s %s, want POST %s", r.Method, r.URL.Path, wantPath)
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") ||
+		!strings.Contains(auth, "/us-east-1/bedrock/aws4_request") {
+		t.Errorf("Authorization = %q", auth)
+	}
+	if r.Header.Get("X-Amz-Date") == "" {
+		t.Error("X-Amz-Date is missing")
+	}
+
+	var body map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		t.Fatalf("error decoding body: %v", err)
+	}
+	if body["anthropic_version"] != anthropicVersion {
+		t.Errorf("anthropic_version = %v", body["anthropic_version"])
+	}
+	for _, key := range []string{"model", "stream"} {
+		if _, ok := body[key]; ok {
+			t.Errorf("body contains %s", key)
+		}
+	}
+}
+
+func TestClientCreateMessage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		checkInvokeRequest(t, r, "invoke")
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{
+			"id": "msg_1",
+			"type": "message",
+			"role": "assistant",
+			"content": [{"type": "text", "text": "Hi there"}],
+			"stop_reason": "end_turn",
+			"usage": {"input_tokens": 10, "output_tokens": 3}
+		}`)
+	}))
+	defer server.Close()
+
+	client := NewClient(testCreds, "us-east-1", server.URL)
+	message, err := client.CreateMessage(context.Background(), testRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(message.Content) != 1 || message.Content[0].Text != "Hi there" {
+		t.Errorf("content = %+v", message.Content)
+	}
+	if message.Usage.InputTokens != 10 || message.Usage.OutputTokens != 3 {
+		t.Errorf("usage = %+v", message.Usage)
+	}
+}
+
+func TestClientCreateMessageError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Amzn-ErrorType", "ThrottlingException:http://internal.amazon.com/coral/com.amazon.bedrock/")
+		w.Header().Set("Retry-After", "2")
+		w.WriteHeader(http.StatusTooManyRequests)
+		io.WriteString(w, `{"message": "Too many requests, please wait before trying again."}`)
+	}))
+	defer server.Close()
+
+	client := NewClient(testCreds, "us-east-1", server.URL)
+	_, err := client.CreateMessage(context.Background(), testRequest())
+
+	var providerErr *llm.ProviderError
+	if !errors.As(err, &providerErr) {
+		t.Fatalf("error = %v, want a *llm.ProviderError", err)
+	}
+	if !errors.Is(err, llm.ErrRateLimited) || !providerErr.Retryable() {
+		t.Errorf("error %v is not a retryable rate limit", err)
+	}
+	if providerErr.Type != "ThrottlingException" {
+		t.Errorf("type = %q", providerErr.Type)
+	}
+	if providerErr.RetryAfter.Seconds() != 2 {
+		t.Errorf("retry after = %v", providerErr.RetryAfter)
+	}
+}
+
+// chunkMessage wraps an event of the Anthropic streaming API in a chunk
+// message
+func chunkMessage(t *testing.T, event string) []byte {
+	t.Helper()
+	payload, err := json.Marshal(chunkPayload{Bytes: []byte(event)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encodeEventMessage(map[string]string{
+		":event-type":   "chunk",
+		":content-type": "application/json",
+		":message-type": "event",
+	}, payload)
+}
+
+// streamServer answers invoke-with-response-stream requests with messages
+func streamServer(t *testing.T, messages ...[]byte) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		checkInvokeRequest(t, r, "invoke-with-response-stream")
+		if accept := r.Header.Get("Accept"); accept != "application/vnd.amazon.eventstream" {
+			t.Errorf("Accept = %q", accept)
+		}
+		w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
+		for _, message := range messages {
+			w.Write(message)
+		}
+	}))
+}
+
+func TestProviderStreamMessage(t *testing.T) {
+	server := streamServer(t,
+		chunkMessage(t, `{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"usage":{"input_tokens":12,"output_tokens":1}}}`),
+		chunkMessage(t, `{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`),
+		chunkMessage(t, `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`),
+		chunkMessage(t, `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" world"}}`),
+		chunkMessage(t, `{"type":"content_block_stop","index":0}`),
+		chunkMessage(t, `{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"fs__read","input":{}}}`),
+		chunkMessage(t, `{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"path\":"}}`),
+		chunkMessage(t, `{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"a.txt\"}"}}`),
+		chunkMessage(t, `{"type":"content_block_stop","index":1}`),
+		chunkMessage(t, `{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":20}}`),
+		chunkMessage(t, `{"type":"message_stop"}`),
+	)
+	defer server.Close()
+
+	provider := NewProvider(testCreds, "us-east-1", server.URL, testModel)
+	messages := []llm.Message{&testMessage{role: "user", content: "Hello"}}
+	events, err := provider.StreamMessage(context.Background(), "", messages, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var text strings.Builder
+	var done llm.Message
+	for event := range events {
+		switch event.Type {
+		case llm.StreamEventText:
+			text.WriteString(event.Text)
+		case llm.StreamEventError:
+			t.Fatal(event.Err)
+		case llm.StreamEventDone:
+			done = event.Message
+		}
+	}
+
+	if text.String() != "Hello world" {
+		t.Errorf("streamed text = %q", text.String())
+	}
+	if done == nil {
+		t.Fatal("stream ended without a message")
+	}
+	if done.GetContent() != "Hello world" {
+		t.Errorf("content = %q", done.GetContent())
+	}
+	calls := done.GetToolCalls()
+	if len(calls) != 1 || calls[0].GetName() != "fs__read" || calls[0].GetArguments()["path"] != "a.txt" {
+		t.Errorf("tool calls = %+v", calls)
+	}
+	if input, output := done.GetUsage(); input != 12 || output != 20 {
+		t.Errorf("usage = %d, %d", input, output)
+	}
+}
+
+func TestClientStreamMessageErrors(t *testing.T) {
+	start := chunkMessage(t, `{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[]}}`)
+
+	tests := []struct {
+		name     string
+		messages [][]byte
+		wantKind error
+		wantErr  string
+	}{
+		{
+			name: "exception message",
+			messages: [][]byte{start, encodeEventMessage(map[string]string{
+				":message-type":   "exception",
+				":exception-type": "throttlingException",
+			}, []byte(`{"message":"Too many tokens, please wait before trying again."}`))},
+			wantKind: llm.ErrRateLimited,
+			wantErr:  "Too many tokens",
+		},
+		{
+			name:     "error event",
+			messages: [][]byte{start, chunkMessage(t, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`)},
+			wantKind: llm.ErrOverloaded,
+		},
+		{
+			name:     "truncated stream",
+			messages: [][]byte{start, chunkMessage(t, `{"type":"message_stop"}`)[:20]},
+			wantKind: llm.ErrTransient,
+			wantErr:  "unexpected EOF",
+		},
+		{
+			name:     "no message_stop",
+			messages: [][]byte{start},
+			wantKind: llm.ErrTransient,
+			wantErr:  "stream ended before the response was complete",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := streamServer(t, tt.messages...)
+			defer server.Close()
+
+			client := NewClient(testCreds, "us-east-1", server.URL)
+			err := client.StreamMessage(context.Background(), testRequest(), func(anthropic.StreamEvent) error {
+				return nil
+			})
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if tt.wantKind != nil && !errors.Is(err, tt.wantKind) {
+				t.Errorf("error = %v, want kind %v", err, tt.wantKind)
+			}
+			if tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %q, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// testMessage is a user message with text content
+type testMessage struct {
+	role    string
+	content string
+}
+
+func (m *testMessage) GetRole() string              { return m.role }
+func (m *testMessage) GetContent() string           { return m.content }
+func (m *testMessage) GetToolCalls() []llm.ToolCall { return nil }
+func (m *testMessage) IsToolResponse() bool         { return false }
+func (m *testMessage) GetToolResponseID() string    { return "" }
+func (m *testMessage) GetUsage() (int, int)         { return 0, 0 }
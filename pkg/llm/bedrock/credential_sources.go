@@ -0,0 +1,223 @@
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// metadataClient fetches credentials from link-local endpoints, which must
+// not be reached through a proxy
+var metadataClient = &http.Client{Transport: &http.Transport{}}
+
+// processOutput is what a credential_process prints
+type processOutput struct {
+	Version         int       `json:"Version"`
+	AccessKeyID     string    `json:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey"`
+	SessionToken    string    `json:"SessionToken"`
+	Expiration      time.Time `json:"Expiration"`
+}
+
+// processCredentials runs the credential_process of a profile through the
+// shell and reads the credentials it prints
+func processCredentials(command string) (Credentials, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd.exe", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return Credentials{}, fmt.Errorf(
+			"error running credential_process %q: %w: %s",
+			command, err, strings.TrimSpace(stderr.String()),
+		)
+	}
+
+	var output processOutput
+	if err := json.Unmarshal(out, &output); err != nil {
+		return Credentials{}, fmt.Errorf("error decoding output of credential_process %q: %w", command, err)
+	}
+	if output.Version != 1 {
+		return Credentials{}, fmt.Errorf(
+			"unsupported version %d in output of credential_process %q", output.Version, command,
+		)
+	}
+	if output.AccessKeyID == "" || output.SecretAccessKey == "" {
+		return Credentials{}, fmt.Errorf("credential_process %q printed no credentials", command)
+	}
+
+	return Credentials{
+		AccessKeyID:     output.AccessKeyID,
+		SecretAccessKey: output.SecretAccessKey,
+		SessionToken:    output.SessionToken,
+		Expires:         output.Expiration,
+	}, nil
+}
+
+// endpointCredentials is the response of the container credentials
+// endpoint and of the instance metadata service
+type endpointCredentials struct {
+	AccessKeyID     string    `json:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey"`
+	Token           string    `json:"Token"`
+	Expiration      time.Time `json:"Expiration"`
+}
+
+func (c endpointCredentials) credentials() Credentials {
+	return Credentials{
+		AccessKeyID:     c.AccessKeyID,
+		SecretAccessKey: c.SecretAccessKey,
+		SessionToken:    c.Token,
+		Expires:         c.Expiration,
+	}
+}
+
+// containerEndpoint returns the container credentials endpoint that ECS
+// and EKS set in the environment, and the token to authorize with
+func containerEndpoint() (string, string, bool) {
+	endpoint := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); uri != "" {
+		endpoint = "http://169.254.170.2" + uri
+	}
+	if endpoint == "" {
+		return "", "", false
+	}
+
+	token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+	if file := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); file != "" {
+		if data, err := os.ReadFile(file); err == nil {
+			token = strings.TrimSpace(string(data))
+		}
+	}
+	return endpoint, token, true
+}
+
+// containerCredentials fetches the credentials of the container's task or
+// pod
+func containerCredentials(endpoint, token string) (Credentials, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("error creating container credentials request: %w", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	var creds endpointCredentials
+	if err := getJSON(req, &creds); err != nil {
+		return Credentials{}, fmt.Errorf("error fetching container credentials from %s: %w", endpoint, err)
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return Credentials{}, fmt.Errorf("container credentials endpoint %s returned no credentials", endpoint)
+	}
+	return creds.credentials(), nil
+}
+
+// errNoInstanceMetadata means mcphost does not run on an instance with an
+// instance metadata service and an instance role
+var errNoInstanceMetadata = errors.New("no instance metadata")
+
+// instanceMetadataEndpoint returns the endpoint of the instance metadata
+// service, which AWS_EC2_METADATA_SERVICE_ENDPOINT can override
+func instanceMetadataEndpoint() string {
+	if endpoint := os.Getenv("AWS_EC2_METADATA_SERVICE_ENDPOINT"); endpoint != "" {
+		return strings.TrimSuffix(endpoint, "/")
+	}
+	return "http://169.254.169.254"
+}
+
+// instanceCredentials fetches the credentials of the instance role with
+// IMDSv2. It returns errNoInstanceMetadata if the service does not answer
+// in time or the instance has no role.
+func instanceCredentials(endpoint string) (Credentials, error) {
+	// Outside of EC2 the service does not answer, so the wait is short
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", endpoint+"/latest/api/token", nil)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("error creating instance metadata request: %w", err)
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "21600")
+	resp, err := metadataClient.Do(req)
+	if err != nil {
+		return Credentials{}, errNoInstanceMetadata
+	}
+	token, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return Credentials{}, errNoInstanceMetadata
+	}
+
+	get := func(path string) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", endpoint+path, nil)
+		if err != nil {
+			return nil, fmt.Errorf("error creating instance metadata request: %w", err)
+		}
+		req.Header.Set("X-aws-ec2-metadata-token", string(token))
+		return req, nil
+	}
+
+	req, err = get("/latest/meta-data/iam/security-credentials/")
+	if err != nil {
+		return Credentials{}, err
+	}
+	resp, err = metadataClient.Do(req)
+	if err != nil {
+		return Credentials{}, errNoInstanceMetadata
+	}
+	roles, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	role, _, _ := strings.Cut(strings.TrimSpace(string(roles)), "\n")
+	if err != nil || resp.StatusCode != http.StatusOK || role == "" {
+		return Credentials{}, errNoInstanceMetadata
+	}
+
+	req, err = get("/latest/meta-data/iam/security-credentials/" + role)
+	if err != nil {
+		return Credentials{}, err
+	}
+	var creds endpointCredentials
+	if err := getJSON(req, &creds); err != nil {
+		return Credentials{}, fmt.Errorf("error fetching credentials of instance role %s: %w", role, err)
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return Credentials{}, fmt.Errorf("instance metadata service returned no credentials for role %s", role)
+	}
+	return creds.credentials(), nil
+}
+
+// getJSON sends req and decodes the JSON response into v
+func getJSON(req *http.Request, v any) error {
+	resp, err := metadataClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,243 @@
+package bedrock
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Credentials are AWS credentials used to sign requests
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	// Expires is when temporary credentials expire, zero for credentials
+	// that do not
+	Expires time.Time
+}
+
+// expiringSoon reports whether the credentials expire within the next
+// minutes and should be loaded again
+func (c Credentials) expiringSoon(now time.Time) bool {
+	return !c.Expires.IsZero() && now.Add(5*time.Minute).After(c.Expires)
+}
+
+// awsProfile holds the settings of a profile from the shared credentials
+// and config files
+type awsProfile struct {
+	name        string
+	credentials map[string]string
+	config      map[string]string
+}
+
+// value returns a setting of the profile, preferring the credentials file
+func (p awsProfile) value(key string) string {
+	if value := p.credentials[key]; value != "" {
+		return value
+	}
+	return p.config[key]
+}
+
+// LoadConfig returns the credentials and region from the standard AWS
+// sources, in the order the AWS SDKs use:
+//
+//   - the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+//     environment variables
+//   - the keys or the credential_process of the profile named by
+//     AWS_PROFILE (default "default") in the shared credentials and config
+//     files
+//   - the container credentials endpoint of ECS and EKS
+//   - the instance metadata service (IMDS) of EC2
+//
+// Profiles using SSO, assumed roles or web identities are reported as
+// unsupported. The region comes from AWS_REGION, AWS_DEFAULT_REGION or the
+// profile.
+func LoadConfig() (Credentials, string, error) {
+	profile, err := loadProfile()
+	if err != nil {
+		return Credentials{}, "", err
+	}
+
+	creds, err := loadCredentials(profile)
+	if err != nil {
+		return Credentials{}, "", err
+	}
+
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		region = profile.value("region")
+	}
+	if region == "" {
+		return Credentials{}, "", fmt.Errorf(
+			"AWS region not set. Set AWS_REGION or configure a region for the %q profile in ~/.aws/config",
+			profile.name,
+		)
+	}
+
+	return creds, region, nil
+}
+
+// loadProfile reads the profile named by AWS_PROFILE from the shared files
+func loadProfile() (awsProfile, error) {
+	name := os.Getenv("AWS_PROFILE")
+	if name == "" {
+		name = "default"
+	}
+
+	credentialsFile, err := loadINI(awsFile("AWS_SHARED_CREDENTIALS_FILE", "credentials"))
+	if err != nil {
+		return awsProfile{}, err
+	}
+	configFile, err := loadINI(awsFile("AWS_CONFIG_FILE", "config"))
+	if err != nil {
+		return awsProfile{}, err
+	}
+
+	// The config file prefixes all profiles but the default one
+	configSection := "profile " + name
+	if name == "default" {
+		configSection = "default"
+	}
+
+	return awsProfile{
+		name:        name,
+		credentials: credentialsFile[name],
+		config:      configFile[configSection],
+	}, nil
+}
+
+// loadCredentials returns the credentials of the first source that has any
+func loadCredentials(profile awsProfile) (Credentials, error) {
+	creds := Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID != "" && creds.SecretAccessKey != "" {
+		return creds, nil
+	}
+
+	creds = Credentials{
+		AccessKeyID:     profile.value("aws_access_key_id"),
+		SecretAccessKey: profile.value("aws_secret_access_key"),
+		SessionToken:    profile.value("aws_session_token"),
+	}
+	if creds.AccessKeyID != "" && creds.SecretAccessKey != "" {
+		return creds, nil
+	}
+
+	if command := profile.value("credential_process"); command != "" {
+		return processCredentials(command)
+	}
+	if err := unsupportedSource(profile); err != nil {
+		return Credentials{}, err
+	}
+
+	if endpoint, token, ok := containerEndpoint(); ok {
+		return containerCredentials(endpoint, token)
+	}
+
+	if os.Getenv("AWS_EC2_METADATA_DISABLED") != "true" {
+		creds, err := instanceCredentials(instanceMetadataEndpoint())
+		if err == nil {
+			return creds, nil
+		}
+		if err != errNoInstanceMetadata {
+			return Credentials{}, err
+		}
+	}
+
+	return Credentials{}, fmt.Errorf(
+		"AWS credentials not found. Set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY or configure the %q profile in ~/.aws/credentials",
+		profile.name,
+	)
+}
+
+// unsupportedSource returns an error naming the credential source of the
+// profile or the environment that mcphost cannot use
+func unsupportedSource(profile awsProfile) error {
+	switch {
+	case profile.value("sso_session") != "" || profile.value("sso_start_url") != "":
+		return fmt.Errorf(
+			"AWS profile %q uses SSO (IAM Identity Center), which is not supported. "+
+				"Add \"credential_process = aws configure export-credentials --profile <sso-profile> --format process\" to a profile and use that one",
+			profile.name,
+		)
+	case profile.value("role_arn") != "":
+		return fmt.Errorf(
+			"AWS profile %q assumes the role %s, which is not supported. Use a profile with keys or a credential_process",
+			profile.name, profile.value("role_arn"),
+		)
+	case profile.value("web_identity_token_file") != "" || os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE") != "":
+		return fmt.Errorf(
+			"AWS web identity credentials (AWS_WEB_IDENTITY_TOKEN_FILE) are not supported. Use keys or a credential_process",
+		)
+	}
+	return nil
+}
+
+// awsFile returns the path of a shared AWS file, which env can override
+func awsFile(env string, name string) string {
+	if path := os.Getenv(env); path != "" {
+		return path
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, ".aws", name)
+}
+
+// loadINI reads the sections of an AWS shared file. A missing file has no
+// sections.
+func loadINI(path string) (map[string]map[string]string, error) {
+	sections := make(map[string]map[string]string)
+	if path == "" {
+		return sections, nil
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return sections, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var section map[string]string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if sections[name] == nil {
+				sections[name] = make(map[string]string)
+			}
+			section = sections[name]
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || section == nil {
+			continue
+		}
+		section[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+
+	return sections, nil
+}
@@ -0,0 +1,301 @@
+package bedrock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// isolateAWS clears the AWS environment and points the shared files to a
+// temporary directory holding credentials and config
+func isolateAWS(t *testing.T, credentials, config string) {
+	t.Helper()
+	for _, name := range []string{
+		"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN",
+		"AWS_PROFILE", "AWS_REGION", "AWS_DEFAULT_REGION",
+		"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "AWS_CONTAINER_CREDENTIALS_FULL_URI",
+		"AWS_CONTAINER_AUTHORIZATION_TOKEN", "AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE",
+		"AWS_WEB_IDENTITY_TOKEN_FILE", "AWS_EC2_METADATA_SERVICE_ENDPOINT",
+	} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+
+	dir := t.TempDir()
+	credentialsFile := filepath.Join(dir, "credentials")
+	configFile := filepath.Join(dir, "config")
+	if err := os.WriteFile(credentialsFile, []byte(credentials), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(configFile, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentialsFile)
+	t.Setenv("AWS_CONFIG_FILE", configFile)
+}
+
+func TestLoadConfigEnvironment(t *testing.T) {
+	isolateAWS(t, "[default]\naws_access_key_id = FILEKEY\naws_secret_access_key = filesecret\n", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "ENVKEY")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "envsecret")
+	t.Setenv("AWS_SESSION_TOKEN", "envtoken")
+	t.Setenv("AWS_DEFAULT_REGION", "eu-west-1")
+
+	creds, region, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Credentials{AccessKeyID: "ENVKEY", SecretAccessKey: "envsecret", SessionToken: "envtoken"}
+	if creds != want {
+		t.Errorf("credentials = %+v, want %+v", creds, want)
+	}
+	if region != "eu-west-1" {
+		t.Errorf("region = %q", region)
+	}
+}
+
+func TestLoadConfigProfile(t *testing.T) {
+	isolateAWS(t, `
+# comment
+[default]
+aws_access_key_id = DEFAULTKEY
+aws_secret_access_key = defaultsecret
+
+[work]
+aws_access_key_id = WORKKEY
+; comment
+aws_secret_access_key = worksecret
+aws_session_token = worktoken
+`, `
+[default]
+region = us-east-1
+
+[profile work]
+region = us-west-2
+`)
+	t.Setenv("AWS_PROFILE", "work")
+
+	creds, region, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Credentials{AccessKeyID: "WORKKEY", SecretAccessKey: "worksecret", SessionToken: "worktoken"}
+	if creds != want {
+		t.Errorf("credentials = %+v, want %+v", creds, want)
+	}
+	if region != "us-west-2" {
+		t.Errorf("region = %q, want the region of the profile", region)
+	}
+
+	t.Setenv("AWS_REGION", "ap-south-1")
+	if _, region, _ := LoadConfig(); region != "ap-south-1" {
+		t.Errorf("region = %q, want AWS_REGION", region)
+	}
+}
+
+func TestLoadConfigCredentialProcess(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test process is a shell script")
+	}
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	script := filepath.Join(t.TempDir(), "creds.sh")
+	output := `{"Version": 1, "AccessKeyId": "PROCKEY", "SecretAccessKey": "procsecret", ` +
+		`"SessionToken": "proctoken", "Expiration": "` + expires.Format(time.RFC3339) + `"}`
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho '"+output+"'\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	isolateAWS(t, "", "[default]\nregion = us-east-1\ncredential_process = "+script+" --profile x\n")
+
+	creds, _, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Credentials{AccessKeyID: "PROCKEY", SecretAccessKey: "procsecret", SessionToken: "proctoken", Expires: expires}
+	if !creds.Expires.Equal(want.Expires) {
+		t.Errorf("expires = %v, want %v", creds.Expires, want.Expires)
+	}
+	creds.Expires = want.Expires
+	if creds != want {
+		t.Errorf("credentials = %+v, want %+v", creds, want)
+	}
+}
+
+func TestLoadConfigCredentialProcessFails(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test process is a shell command")
+	}
+	isolateAWS(t, "", "[default]\nregion = us-east-1\ncredential_process = echo token expired >&2; exit 1\n")
+
+	_, _, err := LoadConfig()
+	if err == nil || !strings.Contains(err.Error(), "credential_process") || !strings.Contains(err.Error(), "token expired") {
+		t.Errorf("error = %v, want the failed credential_process and its output", err)
+	}
+}
+
+func TestLoadConfigUnsupportedSources(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		env     map[string]string
+		wantErr string
+	}{
+		{
+			name:    "sso session",
+			config:  "[default]\nregion = us-east-1\nsso_session = corp\nsso_account_id = 123\n",
+			wantErr: "uses SSO",
+		},
+		{
+			name:    "legacy sso",
+			config:  "[default]\nregion = us-east-1\nsso_start_url = https://corp.awsapps.com/start\n",
+			wantErr: "uses SSO",
+		},
+		{
+			name:    "assumed role",
+			config:  "[default]\nregion = us-east-1\nrole_arn = arn:aws:iam::123:role/dev\nsource_profile = base\n",
+			wantErr: "assumes the role arn:aws:iam::123:role/dev",
+		},
+		{
+			name:    "web identity",
+			config:  "[default]\nregion = us-east-1\n",
+			env:     map[string]string{"AWS_WEB_IDENTITY_TOKEN_FILE": "/var/run/token"},
+			wantErr: "web identity",
+		},
+		{
+			name:    "nothing",
+			config:  "[default]\nregion = us-east-1\n",
+			wantErr: "AWS credentials not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolateAWS(t, "", tt.config)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			_, _, err := LoadConfig()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadConfigContainerCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/creds" || r.Header.Get("Authorization") != "pod-token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"AccessKeyId": "PODKEY", "SecretAccessKey": "podsecret", "Token": "podtoken", "Expiration": "2030-01-01T00:00:00Z"}`))
+	}))
+	defer server.Close()
+
+	isolateAWS(t, "", "")
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("pod-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", server.URL+"/creds")
+	t.Setenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE", tokenFile)
+	t.Setenv("AWS_REGION", "us-east-1")
+
+	creds, _, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.AccessKeyID != "PODKEY" || creds.SecretAccessKey != "podsecret" || creds.SessionToken != "podtoken" {
+		t.Errorf("credentials = %+v", creds)
+	}
+	if !creds.Expires.Equal(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expires = %v", creds.Expires)
+	}
+}
+
+func TestLoadConfigInstanceCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PUT" && r.URL.Path == "/latest/api/token" {
+			w.Write([]byte("imds-token"))
+			return
+		}
+		if r.Header.Get("X-aws-ec2-metadata-token") != "imds-token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/latest/meta-data/iam/security-credentials/":
+			w.Write([]byte("instance-role\n"))
+		case "/latest/meta-data/iam/security-credentials/instance-role":
+			w.Write([]byte(`{"Code": "Success", "AccessKeyId": "EC2KEY", "SecretAccessKey": "ec2secret", "Token": "ec2token", "Expiration": "2030-01-01T00:00:00Z"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	isolateAWS(t, "", "")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "")
+	t.Setenv("AWS_EC2_METADATA_SERVICE_ENDPOINT", server.URL+"/")
+	t.Setenv("AWS_REGION", "us-east-1")
+
+	creds, _, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.AccessKeyID != "EC2KEY" || creds.SecretAccessKey != "ec2secret" || creds.SessionToken != "ec2token" {
+		t.Errorf("credentials = %+v", creds)
+	}
+}
+
+func TestLoadConfigNoInstanceRole(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PUT" {
+			w.Write([]byte("imds-token"))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	isolateAWS(t, "", "")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "")
+	t.Setenv("AWS_EC2_METADATA_SERVICE_ENDPOINT", server.URL)
+
+	_, _, err := LoadConfig()
+	if err == nil || !strings.Contains(err.Error(), "AWS credentials not found") {
+		t.Errorf("error = %v, want credentials not found", err)
+	}
+}
+
+func TestLoadConfigMissingRegion(t *testing.T) {
+	isolateAWS(t, "[default]\naws_access_key_id = KEY\naws_secret_access_key = secret\n", "")
+
+	_, _, err := LoadConfig()
+	if err == nil || !strings.Contains(err.Error(), "AWS region not set") {
+		t.Errorf("error = %v, want region not set", err)
+	}
+}
+
+func TestCredentialsExpiringSoon(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		expires time.Time
+		want    bool
+	}{
+		{time.Time{}, false},
+		{now.Add(time.Hour), false},
+		{now.Add(time.Minute), true},
+		{now.Add(-time.Minute), true},
+	}
+	for _, tt := range tests {
+		if got := (Credentials{Expires: tt.expires}).expiringSoon(now); got != tt.want {
+			t.Errorf("expiringSoon with expiry %v = %v, want %v", tt.expires, got, tt.want)
+		}
+	}
+}
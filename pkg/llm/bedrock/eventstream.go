@@ -0,0 +1,115 @@
+package bedrock
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// eventMessage is a message of the AWS event stream encoding, which
+// Bedrock uses for streaming responses
+type eventMessage struct {
+	Headers map[string]string
+	Payload []byte
+}
+
+// maxEventMessageSize guards against allocating huge buffers for a
+// corrupted length prefix
+const maxEventMessageSize = 16 * 1024 * 1024
+
+// readEventMessage reads the next message from r. It returns io.EOF at the
+// end of the stream. Only string headers are kept, which is all Bedrock
+// sends.
+func readEventMessage(r io.Reader) (eventMessage, error) {
+	// The prelude holds the total and headers length and its own checksum
+	prelude := make([]byte, 12)
+	if _, err := io.ReadFull(r, prelude); err != nil {
+		return eventMessage{}, err
+	}
+	totalLength := binary.BigEndian.Uint32(prelude[0:4])
+	headersLength := binary.BigEndian.Uint32(prelude[4:8])
+	if crc32.ChecksumIEEE(prelude[0:8]) != binary.BigEndian.Uint32(prelude[8:12]) {
+		return eventMessage{}, fmt.Errorf("invalid event stream prelude checksum")
+	}
+	if totalLength < 16+headersLength || totalLength > maxEventMessageSize {
+		return eventMessage{}, fmt.Errorf("invalid event stream message length %d", totalLength)
+	}
+
+	message := make([]byte, totalLength)
+	copy(message, prelude)
+	if _, err := io.ReadFull(r, message[12:]); err != nil {
+		// Only the end of the stream between messages is io.EOF
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return eventMessage{}, fmt.Errorf("error reading event stream message: %w", err)
+	}
+	crcOffset := totalLength - 4
+	if crc32.ChecksumIEEE(message[:crcOffset]) != binary.BigEndian.Uint32(message[crcOffset:]) {
+		return eventMessage{}, fmt.Errorf("invalid event stream message checksum")
+	}
+
+	headers, err := parseEventHeaders(message[12 : 12+headersLength])
+	if err != nil {
+		return eventMessage{}, err
+	}
+
+	return eventMessage{
+		Headers: headers,
+		Payload: message[12+headersLength : crcOffset],
+	}, nil
+}
+
+// valueSizes are the sizes of the fixed size header value types, by type
+var valueSizes = map[byte]int{
+	0: 0,  // true
+	1: 0,  // false
+	2: 1,  // byte
+	3: 2,  // short
+	4: 4,  // integer
+	5: 8,  // long
+	8: 8,  // timestamp
+	9: 16, // uuid
+}
+
+const (
+	headerTypeBytes  = 6
+	headerTypeString = 7
+)
+
+func parseEventHeaders(data []byte) (map[string]string, error) {
+	headers := make(map[string]string)
+	for len(data) > 0 {
+		nameLength := int(data[0])
+		if len(data) < 1+nameLength+1 {
+			return nil, fmt.Errorf("invalid event stream header")
+		}
+		name := string(data[1 : 1+nameLength])
+		valueType := data[1+nameLength]
+		data = data[2+nameLength:]
+
+		switch valueType {
+		case headerTypeBytes, headerTypeString:
+			if len(data) < 2 {
+				return nil, fmt.Errorf("invalid event stream header %s", name)
+			}
+			valueLength := int(binary.BigEndian.Uint16(data[0:2]))
+			if len(data) < 2+valueLength {
+				return nil, fmt.Errorf("invalid event stream header %s", name)
+			}
+			if valueType == headerTypeString {
+				headers[name] = string(data[2 : 2+valueLength])
+			}
+			data = data[2+valueLength:]
+
+		default:
+			size, ok := valueSizes[valueType]
+			if !ok || len(data) < size {
+				return nil, fmt.Errorf("invalid event stream header %s", name)
+			}
+			data = data[size:]
+		}
+	}
+	return headers, nil
+}
@@ -0,0 +1,203 @@
+package bedrock
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// encodeEventMessage encodes a message with string headers like Bedrock
+// sends them
+func encodeEventMessage(headers map[string]string, payload []byte) []byte {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var encodedHeaders bytes.Buffer
+	for _, name := range names {
+		encodedHeaders.WriteByte(byte(len(name)))
+		encodedHeaders.WriteString(name)
+		encodedHeaders.WriteByte(headerTypeString)
+		binary.Write(&encodedHeaders, binary.BigEndian, uint16(len(headers[name])))
+		encodedHeaders.WriteString(headers[name])
+	}
+
+	totalLength := 16 + encodedHeaders.Len() + len(payload)
+	message := make([]byte, 0, totalLength)
+	message = binary.BigEndian.AppendUint32(message, uint32(totalLength))
+	message = binary.BigEndian.AppendUint32(message, uint32(encodedHeaders.Len()))
+	message = binary.BigEndian.AppendUint32(message, crc32.ChecksumIEEE(message))
+	message = append(message, encodedHeaders.Bytes()...)
+	message = append(message, payload...)
+	return binary.BigEndian.AppendUint32(message, crc32.ChecksumIEEE(message))
+}
+
+func TestReadEventMessage(t *testing.T) {
+	headers := map[string]string{
+		":event-type":   "chunk",
+		":content-type": "application/json",
+		":message-type": "event",
+	}
+	stream := append(
+		encodeEventMessage(headers, []byte(`{"bytes":"e30="}`)),
+		encodeEventMessage(map[string]string{":event-type": "chunk"}, nil)...,
+	)
+	r := bytes.NewReader(stream)
+
+	message, err := readEventMessage(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range headers {
+		if message.Headers[name] != value {
+			t.Errorf("header %s = %q, want %q", name, message.Headers[name], value)
+		}
+	}
+	if string(message.Payload) != `{"bytes":"e30="}` {
+		t.Errorf("payload = %q", message.Payload)
+	}
+
+	message, err = readEventMessage(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(message.Payload) != 0 {
+		t.Errorf("payload = %q, want none", message.Payload)
+	}
+
+	if _, err := readEventMessage(r); !errors.Is(err, io.EOF) {
+		t.Errorf("error at end of stream = %v, want io.EOF", err)
+	}
+}
+
+func TestReadEventMessageSkipsOtherHeaderTypes(t *testing.T) {
+	// A bool, an int32 and a bytes header precede the string header
+	var encodedHeaders bytes.Buffer
+	encodedHeaders.Write([]byte{4, 'f', 'l', 'a', 'g', 0})
+	encodedHeaders.Write([]byte{3, 'n', 'u', 'm', 4, 0, 0, 0, 7})
+	encodedHeaders.Write([]byte{3, 'r', 'a', 'w', headerTypeBytes, 0, 2, 0xff, 0xfe})
+	encodedHeaders.Write([]byte{4, 't', 'y', 'p', 'e', headerTypeString, 0, 5})
+	encodedHeaders.WriteString("chunk")
+
+	totalLength := 16 + encodedHeaders.Len()
+	message := binary.BigEndian.AppendUint32(nil, uint32(totalLength))
+	message = binary.BigEndian.AppendUint32(message, uint32(encodedHeaders.Len()))
+	message = binary.BigEndian.AppendUint32(message, crc32.ChecksumIEEE(message))
+	message = append(message, encodedHeaders.Bytes()...)
+	message = binary.BigEndian.AppendUint32(message, crc32.ChecksumIEEE(message))
+
+	got, err := readEventMessage(bytes.NewReader(message))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Headers) != 1 || got.Headers["type"] != "chunk" {
+		t.Errorf("headers = %v, want only type=chunk", got.Headers)
+	}
+}
+
+func TestReadEventMessageErrors(t *testing.T) {
+	valid := encodeEventMessage(map[string]string{":event-type": "chunk"}, []byte(`{"bytes":"e30="}`))
+	corrupt := func(offset int) []byte {
+		message := bytes.Clone(valid)
+		message[offset] ^= 0xff
+		return message
+	}
+	withLength := func(totalLength uint32) []byte {
+		message := bytes.Clone(valid)
+		binary.BigEndian.PutUint32(message[0:4], totalLength)
+		binary.BigEndian.PutUint32(message[8:12], crc32.ChecksumIEEE(message[0:8]))
+		return message
+	}
+
+	tests := []struct {
+		name    string
+		stream  []byte
+		wantErr string
+		wantIs  error
+	}{
+		{
+			name:    "prelude checksum mismatch",
+			stream:  corrupt(9),
+			wantErr: "invalid event stream prelude checksum",
+		},
+		{
+			name:    "corrupted length",
+			stream:  corrupt(3),
+			wantErr: "invalid event stream prelude checksum",
+		},
+		{
+			name:    "message checksum mismatch",
+			stream:  corrupt(len(valid) - 1),
+			wantErr: "invalid event stream message checksum",
+		},
+		{
+			name:    "corrupted payload",
+			stream:  corrupt(len(valid) - 6),
+			wantErr: "invalid event stream message checksum",
+		},
+		{
+			name:    "length shorter than headers",
+			stream:  withLength(16),
+			wantErr: "invalid event stream message length 16",
+		},
+		{
+			name:    "length too large",
+			stream:  withLength(maxEventMessageSize + 1),
+			wantErr: "invalid event stream message length",
+		},
+		{
+			name:   "truncated prelude",
+			stream: valid[:7],
+			wantIs: io.ErrUnexpectedEOF,
+		},
+		{
+			name:    "truncated message",
+			stream:  valid[:len(valid)-2],
+			wantErr: "error reading event stream message",
+			wantIs:  io.ErrUnexpectedEOF,
+		},
+		{
+			name:    "truncated after prelude",
+			stream:  valid[:12],
+			wantErr: "error reading event stream message",
+			wantIs:  io.ErrUnexpectedEOF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readEventMessage(bytes.NewReader(tt.stream))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %q, want it to contain %q", err, tt.wantErr)
+			}
+			if tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+				t.Errorf("error = %v, want %v", err, tt.wantIs)
+			}
+		})
+	}
+}
+
+func TestParseEventHeadersInvalid(t *testing.T) {
+	tests := map[string][]byte{
+		"name longer than data":       {10, 'a'},
+		"string value length":         {1, 'a', headerTypeString, 0, 9, 'x'},
+		"missing string value length": {1, 'a', headerTypeString, 0},
+		"unknown value type":          {1, 'a', 42},
+		"short integer value":         {1, 'a', 4, 0, 0},
+	}
+	for name, data := range tests {
+		if _, err := parseEventHeaders(data); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
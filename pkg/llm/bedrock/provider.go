@@ -0,0 +1,21 @@
+package bedrock
+
+import (
+	"github.com/vincent-pli/mcphost/pkg/llm/anthropic"
+)
+
+// Provider runs Claude models on Bedrock. It converts messages and tools
+// like the Anthropic provider and only sends the requests elsewhere.
+type Provider struct {
+	*anthropic.Provider
+}
+
+func NewProvider(creds Credentials, region string, baseURL string, model string) *Provider {
+	return &Provider{
+		Provider: anthropic.NewProviderWithClient(NewClient(creds, region, baseURL), model),
+	}
+}
+
+func (p *Provider) Name() string {
+	return "bedrock"
+}
@@ -0,0 +1,122 @@
+package bedrock
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The example request of the AWS Signature Version 4 documentation, which
+// lists the intermediate values of signing it
+var (
+	exampleCreds = Credentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	exampleTime = time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+)
+
+func exampleRequest(t *testing.T) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest("GET", "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	return req
+}
+
+func TestCanonicalRequest(t *testing.T) {
+	req := exampleRequest(t)
+	req.Header.Set("X-Amz-Date", "20150830T123600Z")
+
+	canonical, signedHeaders := canonicalRequest(req, nil)
+
+	want := strings.Join([]string{
+		"GET",
+		"/",
+		"Action=ListUsers&Version=2010-05-08",
+		"content-type:application/x-www-form-urlencoded; charset=utf-8",
+		"host:iam.amazonaws.com",
+		"x-amz-date:20150830T123600Z",
+		"",
+		"content-type;host;x-amz-date",
+		"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+	}, "\n")
+	if canonical != want {
+		t.Errorf("canonical request:\n%s\nwant:\n%s", canonical, want)
+	}
+	if signedHeaders != "content-type;host;x-amz-date" {
+		t.Errorf("signed headers = %q", signedHeaders)
+	}
+	if got := hashHex([]byte(canonical)); got != "f536975d06c0309214f805bb90ccff089219ecd68b2577efef23edd43b7e1a59" {
+		t.Errorf("canonical request hash = %s", got)
+	}
+}
+
+func TestStringToSign(t *testing.T) {
+	req := exampleRequest(t)
+	req.Header.Set("X-Amz-Date", "20150830T123600Z")
+	canonical, _ := canonicalRequest(req, nil)
+
+	got := stringToSign("20150830T123600Z", "20150830/us-east-1/iam/aws4_request", canonical)
+
+	want := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		"20150830T123600Z",
+		"20150830/us-east-1/iam/aws4_request",
+		"f536975d06c0309214f805bb90ccff089219ecd68b2577efef23edd43b7e1a59",
+	}, "\n")
+	if got != want {
+		t.Errorf("string to sign:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSignRequest(t *testing.T) {
+	req := exampleRequest(t)
+
+	signRequest(req, nil, exampleCreds, "us-east-1", "iam", exampleTime)
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date, " +
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization:\n%s\nwant:\n%s", got, want)
+	}
+	if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+		t.Errorf("X-Amz-Date = %q", got)
+	}
+}
+
+func TestSignRequestSessionToken(t *testing.T) {
+	req := exampleRequest(t)
+	creds := exampleCreds
+	creds.SessionToken = "session-token"
+
+	signRequest(req, nil, creds, "us-east-1", "iam", exampleTime)
+
+	if got := req.Header.Get("X-Amz-Security-Token"); got != "session-token" {
+		t.Errorf("X-Amz-Security-Token = %q", got)
+	}
+	if got := req.Header.Get("Authorization"); !strings.Contains(got, "SignedHeaders=content-type;host;x-amz-date;x-amz-security-token,") {
+		t.Errorf("session token is not signed: %s", got)
+	}
+}
+
+func TestCanonicalURI(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"", "/"},
+		{"/", "/"},
+		{"/model/anthropic.claude-3-5-sonnet-20240620-v1%3A0/invoke", "/model/anthropic.claude-3-5-sonnet-20240620-v1%253A0/invoke"},
+		{"/a%20b/c", "/a%2520b/c"},
+	}
+	for _, tt := range tests {
+		if got := canonicalURI(tt.path); got != tt.want {
+			t.Errorf("canonicalURI(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
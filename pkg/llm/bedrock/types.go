@@ -0,0 +1,12 @@
+package bedrock
+
+// ErrorDetail is the body of an error response or exception event
+type ErrorDetail struct {
+	Message string `json:"message"`
+}
+
+// chunkPayload is the payload of a chunk event, which wraps an event of
+// the Anthropic streaming API
+type chunkPayload struct {
+	Bytes []byte `json:"bytes"`
+}
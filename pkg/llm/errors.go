@@ -0,0 +1,255 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kinds of provider errors. A *ProviderError matches its kind with
+// errors.Is, e.g. errors.Is(err, llm.ErrRateLimited).
+var (
+	ErrRateLimited    = errors.New("rate limited")
+	ErrOverloaded     = errors.New("overloaded")
+	ErrContextTooLong = errors.New("context too long")
+	ErrAuthFailed     = errors.New("authentication failed")
+	ErrInvalidRequest = errors.New("invalid request")
+	ErrTransient      = errors.New("transient error")
+)
+
+// ProviderError is an error reported by a provider's API
+type ProviderError struct {
+	// Kind is one of the Err* kinds above
+	Kind error
+
+	// StatusCode is the HTTP status code, 0 for errors reported in a stream
+	StatusCode int
+
+	// Type is the provider's own name for the error, if any
+	Type    string
+	Message string
+
+	// RetryAfter is how long the provider asked to wait before retrying,
+	// 0 if it did not say
+	RetryAfter time.Duration
+}
+
+func (e *ProviderError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = e.Kind.Error()
+	}
+	if e.Type != "" {
+		msg = e.Type + ": " + msg
+	}
+	if e.StatusCode != 0 {
+		msg = fmt.Sprintf("%s (status %d)", msg, e.StatusCode)
+	}
+	return msg
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Kind
+}
+
+// Retryable reports whether the request may succeed if sent again later
+func (e *ProviderError) Retryable() bool {
+	switch e.Kind {
+	case ErrRateLimited, ErrOverloaded, ErrTransient:
+		return true
+	}
+	return false
+}
+
+// NewProviderError classifies an error by its HTTP status code and, where
+// the status code is ambiguous or missing, by the provider's error type and
+// message. statusCode is 0 for errors reported inside a stream.
+func NewProviderError(statusCode int, errType string, message string) *ProviderError {
+	return &ProviderError{
+		Kind:       classifyError(statusCode, errType, message),
+		StatusCode: statusCode,
+		Type:       errType,
+		Message:    message,
+	}
+}
+
+// NewHTTPError creates a ProviderError for an error response, taking the
+// retry delay from its headers
+func NewHTTPError(resp *http.Response, errType string, message string) *ProviderError {
+	if message == "" {
+		message = http.StatusText(resp.StatusCode)
+	}
+	err := NewProviderError(resp.StatusCode, errType, message)
+	err.RetryAfter = ParseRetryAfter(resp.Header)
+	return err
+}
+
+// NewConnectionError wraps an error of the connection to a provider, e.g.
+// while sending a request or reading a stream. Network failures such as
+// connection resets, DNS failures and timeouts, which *url.Error and
+// net.Error report, are transient. Cancellations are kept as they are.
+func NewConnectionError(message string, err error) error {
+	var netErr net.Error
+	if !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) &&
+		(errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)) {
+		return &ProviderError{
+			Kind:    ErrTransient,
+			Message: message + ": " + err.Error(),
+		}
+	}
+	return fmt.Errorf("%s: %w", message, err)
+}
+
+// NewIncompleteStreamError returns the error for a stream that ended before
+// the provider's final event, e.g. because the connection dropped. The
+// response is incomplete, sending the request again may succeed.
+func NewIncompleteStreamError() *ProviderError {
+	return &ProviderError{
+		Kind:    ErrTransient,
+		Message: "stream ended before the response was complete",
+	}
+}
+
+func classifyError(statusCode int, errType string, message string) error {
+	errType = strings.ToLower(errType)
+	message = strings.ToLower(message)
+
+	switch {
+	// Throttling messages such as Bedrock's "Too many tokens, please wait"
+	// do not mean the context is too long
+	case strings.Contains(errType, "rate_limit"),
+		strings.Contains(errType, "resource_exhausted"),
+		strings.Contains(errType, "throttling"):
+		return ErrRateLimited
+	case isContextTooLong(errType, message):
+		return ErrContextTooLong
+	case strings.Contains(errType, "insufficient_quota"):
+		// Out of credits, waiting does not help
+		return ErrInvalidRequest
+	case strings.Contains(errType, "overloaded"),
+		strings.Contains(errType, "serviceunavailable"),
+		strings.Contains(errType, "modelnotready"):
+		return ErrOverloaded
+	case strings.Contains(errType, "authentication"),
+		strings.Contains(errType, "permission"),
+		strings.Contains(errType, "invalid_api_key"),
+		strings.Contains(errType, "api_key_invalid"),
+		strings.Contains(errType, "unauthenticated"),
+		strings.Contains(errType, "accessdenied"),
+		strings.Contains(errType, "unrecognizedclient"),
+		strings.Contains(errType, "expiredtoken"):
+		return ErrAuthFailed
+	}
+
+	switch {
+	case statusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case statusCode == http.StatusServiceUnavailable, statusCode == 529:
+		return ErrOverloaded
+	case statusCode == http.StatusUnauthorized, statusCode == http.StatusForbidden:
+		return ErrAuthFailed
+	case statusCode == http.StatusRequestTimeout, statusCode >= 500:
+		return ErrTransient
+	case statusCode >= 400:
+		return ErrInvalidRequest
+	}
+
+	// Errors without a status code come from streams that already started
+	switch {
+	case strings.Contains(errType, "api_error"),
+		strings.Contains(errType, "server_error"),
+		strings.Contains(errType, "internalserver"),
+		strings.Contains(errType, "modelstreamerror"),
+		strings.Contains(errType, "modeltimeout"):
+		return ErrTransient
+	case strings.Contains(message, "rate limit"):
+		return ErrRateLimited
+	}
+	return ErrInvalidRequest
+}
+
+func isContextTooLong(errType string, message string) bool {
+	if strings.Contains(errType, "context_length") ||
+		strings.Contains(errType, "request_too_large") {
+		return true
+	}
+	for _, phrase := range []string{
+		"maximum context length",
+		"context length",
+		"context window",
+		"prompt is too long",
+		"input is too long",
+		"too many tokens",
+		"exceeds the maximum number of tokens",
+	} {
+		if strings.Contains(message, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseRetryAfter returns the delay from the Retry-After header, given in
+// seconds or as a date, or from the retry-after-ms header some providers
+// send. It returns 0 if neither is set.
+func ParseRetryAfter(header http.Header) time.Duration {
+	if ms := header.Get("retry-after-ms"); ms != "" {
+		if n, err := strconv.ParseFloat(ms, 64); err == nil && n > 0 {
+			return time.Duration(n * float64(time.Millisecond))
+		}
+	}
+
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if d := time.Until(date); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// ErrorCode is the code of an error response. Most APIs send a name like
+// "context_length_exceeded", some servers a number like 429, which is kept
+// as its text. Codes of other types are ignored.
+type ErrorCode string
+
+func (c *ErrorCode) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return err
+	}
+
+	switch value := value.(type) {
+	case string:
+		*c = ErrorCode(value)
+	case json.Number:
+		*c = ErrorCode(value.String())
+	default:
+		*c = ""
+	}
+	return nil
+}
+
+// IsNumber reports whether the code is a number
+func (c ErrorCode) IsNumber() bool {
+	_, err := strconv.ParseFloat(string(c), 64)
+	return err == nil
+}
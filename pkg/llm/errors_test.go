@@ -0,0 +1,135 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestErrorCodeUnmarshal(t *testing.T) {
+	tests := []struct {
+		data       string
+		want       ErrorCode
+		wantNumber bool
+	}{
+		{`"context_length_exceeded"`, "context_length_exceeded", false},
+		{`429`, "429", true},
+		{`4.5`, "4.5", true},
+		{`null`, "", false},
+		{`{"id": 1}`, "", false},
+	}
+	for _, tt := range tests {
+		var detail struct {
+			Code    ErrorCode `json:"code"`
+			Message string    `json:"message"`
+		}
+		if err := json.Unmarshal([]byte(`{"code": `+tt.data+`, "message": "failed"}`), &detail); err != nil {
+			t.Errorf("code %s: %v", tt.data, err)
+			continue
+		}
+		if detail.Code != tt.want || detail.Message != "failed" {
+			t.Errorf("code %s = %q, message %q", tt.data, detail.Code, detail.Message)
+		}
+		if detail.Code.IsNumber() != tt.wantNumber {
+			t.Errorf("code %s: IsNumber = %v", tt.data, !tt.wantNumber)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{"none", http.Header{}, 0},
+		{"seconds", http.Header{"Retry-After": {"3"}}, 3 * time.Second},
+		{"fractional seconds", http.Header{"Retry-After": {"1.5"}}, 1500 * time.Millisecond},
+		{"zero", http.Header{"Retry-After": {"0"}}, 0},
+		{"negative", http.Header{"Retry-After": {"-2"}}, 0},
+		{"invalid", http.Header{"Retry-After": {"soon"}}, 0},
+		{"past date", http.Header{"Retry-After": {"Wed, 21 Oct 2015 07:28:00 GMT"}}, 0},
+		{"milliseconds", http.Header{"Retry-After-Ms": {"250"}}, 250 * time.Millisecond},
+		{"milliseconds first", http.Header{"Retry-After-Ms": {"250"}, "Retry-After": {"1"}}, 250 * time.Millisecond},
+		{"invalid milliseconds", http.Header{"Retry-After-Ms": {"x"}, "Retry-After": {"1"}}, time.Second},
+	}
+	for _, tt := range tests {
+		if got := ParseRetryAfter(tt.header); got != tt.want {
+			t.Errorf("%s: ParseRetryAfter = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	date := time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat)
+	got := ParseRetryAfter(http.Header{"Retry-After": {date}})
+	if got <= 8*time.Second || got > 10*time.Second {
+		t.Errorf("date: ParseRetryAfter = %v, want about 10s", got)
+	}
+}
+
+func TestNewProviderError(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		errType    string
+		message    string
+		want       error
+	}{
+		{"status rate limit", 429, "", "", ErrRateLimited},
+		{"throttling type", 400, "ThrottlingException", "Too many tokens, please wait", ErrRateLimited},
+		{"context code", 400, "context_length_exceeded", "", ErrContextTooLong},
+		{"context message", 400, "invalid_request_error", "prompt is too long: 210000 tokens > 200000 maximum", ErrContextTooLong},
+		{"overloaded", 529, "overloaded_error", "", ErrOverloaded},
+	}
+	for _, tt := range tests {
+		err := NewProviderError(tt.statusCode, tt.errType, tt.message)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: error %v is not %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestNewConnectionError(t *testing.T) {
+	// A request to a server that is gone fails with a real network error
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	_, refused := http.Get(server.URL)
+	if refused == nil {
+		t.Fatal("request to a closed server succeeded")
+	}
+
+	tests := []struct {
+		name          string
+		err           error
+		wantTransient bool
+	}{
+		{"connection refused", refused, true},
+		{"dns failure", &url.Error{Op: "Post", URL: "https://api.example.com", Err: &net.DNSError{Err: "no such host", Name: "api.example.com"}}, true},
+		{"connection reset", &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}, true},
+		{"truncated body", io.ErrUnexpectedEOF, true},
+		{"cancelled", &url.Error{Op: "Post", URL: "https://api.example.com", Err: context.Canceled}, false},
+		{"deadline", &url.Error{Op: "Post", URL: "https://api.example.com", Err: context.DeadlineExceeded}, false},
+		{"other", errors.New("invalid header"), false},
+	}
+	for _, tt := range tests {
+		err := NewConnectionError("error making request", tt.err)
+		var providerErr *ProviderError
+		isProviderErr := errors.As(err, &providerErr)
+		if isProviderErr != tt.wantTransient {
+			t.Errorf("%s: error %v is a provider error: %v, want %v", tt.name, err, isProviderErr, tt.wantTransient)
+			continue
+		}
+		if tt.wantTransient && (!errors.Is(err, ErrTransient) || !providerErr.Retryable()) {
+			t.Errorf("%s: error %v is not a retryable transient error", tt.name, err)
+		}
+		if !tt.wantTransient && !errors.Is(err, tt.err) {
+			t.Errorf("%s: error %v does not wrap %v", tt.name, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package gemini
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/vincent-pli/mcphost/pkg/llm"
+)
+
+type Client struct {
+	apiKey  string
+	baseURL string
+	client  *http.Client
+}
+
+func NewClient(apiKey string, baseURL string) *Client {
+	if baseURL == "" {
+		baseURL = "https://generativelanguage.googleapis.com/v1beta"
+	} else if !strings.HasSuffix(baseURL, "/v1beta") {
+		baseURL = strings.TrimSuffix(baseURL, "/") + "/v1beta"
+	}
+	return &Client{
+		apiKey:  apiKey,
+		baseURL: baseURL,
+		client:  &http.Client{},
+	}
+}
+
+func (c *Client) GenerateContent(ctx context.Context, model string, req CreateRequest) (*APIResponse, error) {
+	resp, err := c.doRequest(ctx, model, "generateContent", req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var response APIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return &response, nil
+}
+
+// StreamGenerateContent sends a streaming request and calls handler for
+// every chunk until the stream ends or handler returns an error.
+func (c *Client) StreamGenerateContent(
+	ctx context.Context,
+	model string,
+	req CreateRequest,
+	handler func(APIResponse) error,
+) error {
+	resp, err := c.doRequest(ctx, model, "streamGenerateContent?alt=sse", req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// The last chunk has the finish reason, or the reason the prompt was
+	// blocked
+	complete := false
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		var chunk APIResponse
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("error decoding stream chunk: %w", err)
+		}
+
+		if chunk.Error != nil {
+			return llm.NewProviderError(0, chunk.Error.errorType(), chunk.Error.Message)
+		}
+		for _, candidate := range chunk.Candidates {
+			if candidate.FinishReason != "" {
+				complete = true
+			}
+		}
+		if chunk.PromptFeedback != nil && chunk.PromptFeedback.BlockReason != "" {
+			complete = true
+		}
+
+		if err := handler(chunk); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return llm.NewConnectionError("error reading stream", err)
+	}
+	if !complete {
+		return llm.NewIncompleteStreamError()
+	}
+
+	return nil
+}
+
+func (c *Client) doRequest(ctx context.Context, model string, method string, req CreateRequest) (*http.Response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
+		"POST",
+		fmt.Sprintf("%s/models/%s:%s", c.baseURL, url.PathEscape(model), method),
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-goog-api-key", c.apiKey)
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, llm.NewConnectionError("error making request", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+
+		var errResp struct {
+			Error ErrorDetail `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
+			return nil, llm.NewHTTPError(resp, "", "")
+		}
+		return nil, llm.NewHTTPError(resp, errResp.Error.errorType(), errResp.Error.Message)
+	}
+
+	return resp, nil
+}
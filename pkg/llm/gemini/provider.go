@@ -0,0 +1,517 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/vincent-pli/mcphost/pkg/history"
+	"github.com/vincent-pli/mcphost/pkg/llm"
+)
+
+type Provider struct {
+	client  *Client
+	model   string
+	options llm.GenerationOptions
+}
+
+func NewProvider(apiKey string, baseURL string, model string) *Provider {
+	return &Provider{
+		client: NewClient(apiKey, baseURL),
+		model:  model,
+	}
+}
+
+func (p *Provider) CreateMessage(
+	ctx context.Context,
+	prompt string,
+	messages []llm.Message,
+	tools []llm.Tool,
+) (llm.Message, error) {
+	req, err := p.createRequest(prompt, messages, tools)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.GenerateContent(ctx, p.model, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return newMessage(resp)
+}
+
+func (p *Provider) StreamMessage(
+	ctx context.Context,
+	prompt string,
+	messages []llm.Message,
+	tools []llm.Tool,
+) (<-chan llm.StreamEvent, error) {
+	req, err := p.createRequest(prompt, messages, tools)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan llm.StreamEvent)
+	send := func(event llm.StreamEvent) error {
+		select {
+		case events <- event:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	go func() {
+		defer close(events)
+
+		resp := &APIResponse{}
+		candidate := Candidate{Content: Content{Role: "model"}}
+		calls := 0
+
+		err := p.client.StreamGenerateContent(ctx, p.model, req, func(chunk APIResponse) error {
+			resp.UsageMetadata = chunk.UsageMetadata
+			resp.ModelVersion = chunk.ModelVersion
+			if chunk.PromptFeedback != nil {
+				resp.PromptFeedback = chunk.PromptFeedback
+			}
+
+			for _, c := range chunk.Candidates {
+				if c.Index != 0 {
+					continue
+				}
+				if c.FinishReason != "" {
+					candidate.FinishReason = c.FinishReason
+				}
+
+				for _, part := range c.Content.Parts {
+					parts := candidate.Content.Parts
+					switch {
+					case part.Text != "":
+						// Text arrives in pieces, keep it in one part
+						if n := len(parts); n > 0 && parts[n-1].FunctionCall == nil {
+							parts[n-1].Text += part.Text
+						} else {
+							candidate.Content.Parts = append(parts, part)
+						}
+						if err := send(llm.StreamEvent{
+							Type: llm.StreamEventText,
+							Text: part.Text,
+						}); err != nil {
+							return err
+						}
+
+					case part.FunctionCall != nil:
+						// Function calls arrive whole
+						call := *part.FunctionCall
+						if call.ID == "" {
+							call.ID = toolCallID(calls)
+						}
+						calls++
+						candidate.Content.Parts = append(parts, Part{
+							FunctionCall:     &call,
+							ThoughtSignature: part.ThoughtSignature,
+						})
+						if err := send(llm.StreamEvent{
+							Type:          llm.StreamEventToolCall,
+							ToolCallID:    call.ID,
+							ToolName:      call.Name,
+							ToolArguments: string(call.Args),
+						}); err != nil {
+							return err
+						}
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			_ = send(llm.StreamEvent{Type: llm.StreamEventError, Err: err})
+			return
+		}
+
+		if len(candidate.Content.Parts) > 0 || candidate.FinishReason != "" {
+			resp.Candidates = []Candidate{candidate}
+		}
+		msg, err := newMessage(resp)
+		if err != nil {
+			_ = send(llm.StreamEvent{Type: llm.StreamEventError, Err: err})
+			return
+		}
+
+		_ = send(llm.StreamEvent{
+			Type:    llm.StreamEventDone,
+			Message: msg,
+		})
+	}()
+
+	return events, nil
+}
+
+func (p *Provider) createRequest(
+	prompt string,
+	messages []llm.Message,
+	tools []llm.Tool,
+) (CreateRequest, error) {
+	log.Debug("creating message",
+		"prompt", prompt,
+		"num_messages", len(messages),
+		"num_tools", len(tools))
+
+	contents := make([]Content, 0, len(messages))
+	var systemPrompts []string
+
+	// Function responses are matched to calls by name, so remember the
+	// name of every call
+	callNames := make(map[string]string)
+
+	for _, msg := range messages {
+		log.Debug("converting message",
+			"role", msg.GetRole(),
+			"content", msg.GetContent(),
+			"is_tool_response", msg.IsToolResponse())
+
+		// Gemini takes the system prompt as a separate instruction
+		if msg.GetRole() == "system" {
+			if text := strings.TrimSpace(msg.GetContent()); text != "" {
+				systemPrompts = append(systemPrompts, text)
+			}
+			continue
+		}
+
+		var parts []Part
+
+		// Attached files come before the text they are asked about
+		if historyMsg, ok := msg.(*history.HistoryMessage); ok {
+			for _, block := range historyMsg.GetAttachments() {
+				if block.Data != "" {
+					parts = append(parts, Part{InlineData: &Blob{
+						MimeType: block.MediaType,
+						Data:     block.Data,
+					}})
+				} else {
+					parts = append(parts, Part{Text: fmt.Sprintf("Contents of %s:\n\n%s", block.Name, block.Text)})
+				}
+			}
+		}
+
+		if text := strings.TrimSpace(msg.GetContent()); text != "" && !msg.IsToolResponse() {
+			parts = append(parts, Part{Text: text})
+		}
+
+		for _, call := range msg.GetToolCalls() {
+			callNames[call.GetID()] = call.GetName()
+			args, _ := json.Marshal(call.GetArguments())
+			part := Part{FunctionCall: &FunctionCall{
+				Name: call.GetName(),
+				Args: args,
+			}}
+			if signed, ok := call.(llm.SignedToolCall); ok {
+				part.ThoughtSignature = signed.GetSignature()
+			}
+			parts = append(parts, part)
+		}
+
+		if msg.IsToolResponse() {
+			log.Debug("processing tool response",
+				"tool_call_id", msg.GetToolResponseID(),
+				"raw_message", msg)
+
+			if historyMsg, ok := msg.(*history.HistoryMessage); ok {
+				// Images follow the function responses as inline data
+				var images []Part
+				for _, block := range historyMsg.Content {
+					if block.Type == "tool_result" {
+						text, blockImages := p.toolResultParts(block)
+						parts = append(parts, functionResponsePart(
+							callNames[block.ToolUseID],
+							text,
+							block.IsError,
+						))
+						images = append(images, blockImages...)
+					}
+				}
+				parts = append(parts, images...)
+			} else {
+				parts = append(parts, functionResponsePart(
+					callNames[msg.GetToolResponseID()],
+					msg.GetContent(),
+					false,
+				))
+			}
+		}
+
+		if len(parts) == 0 {
+			continue
+		}
+
+		role := "user"
+		if msg.GetRole() == "assistant" {
+			role = "model"
+		}
+
+		// Gemini expects turns to alternate, so merge consecutive messages
+		// of the same role
+		if n := len(contents); n > 0 && contents[n-1].Role == role {
+			contents[n-1].Parts = append(contents[n-1].Parts, parts...)
+			continue
+		}
+		contents = append(contents, Content{Role: role, Parts: parts})
+	}
+
+	// Add the new prompt if provided
+	if prompt != "" {
+		contents = append(contents, Content{
+			Role:  "user",
+			Parts: []Part{{Text: prompt}},
+		})
+	}
+
+	var geminiTools []Tool
+	if len(tools) > 0 {
+		declarations := make([]FunctionDeclaration, len(tools))
+		for i, tool := range tools {
+			parameters, err := convertSchema(tool.InputSchema)
+			if err != nil {
+				return CreateRequest{}, fmt.Errorf("error converting parameters of tool %s: %w", tool.Name, err)
+			}
+			declarations[i] = FunctionDeclaration{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  parameters,
+			}
+		}
+		geminiTools = []Tool{{FunctionDeclarations: declarations}}
+	}
+
+	log.Debug("sending messages to Gemini",
+		"contents", contents,
+		"num_tools", len(tools))
+
+	req := CreateRequest{
+		Contents: contents,
+		Tools:    geminiTools,
+		GenerationConfig: &GenerationConfig{
+			MaxOutputTokens: p.options.MaxTokensOrDefault(),
+			Temperature:     p.options.Temperature,
+			TopP:            p.options.TopP,
+			StopSequences:   p.options.Stop,
+		},
+	}
+	if len(systemPrompts) > 0 {
+		req.SystemInstruction = &Content{
+			Parts: []Part{{Text: strings.Join(systemPrompts, "\n\n")}},
+		}
+	}
+	return req, nil
+}
+
+// functionResponsePart wraps a tool result in the object Gemini expects
+// as function response
+func functionResponsePart(name string, text string, isError bool) Part {
+	if text == "" {
+		text = "No content returned from function"
+	}
+
+	key := "result"
+	if isError {
+		key = "error"
+	}
+	return Part{FunctionResponse: &FunctionResponse{
+		Name:     name,
+		Response: map[string]interface{}{key: text},
+	}}
+}
+
+// toolResultParts returns the text of a tool_result block and its images
+// as inline data parts. Models without vision get a placeholder for
+// images.
+func (p *Provider) toolResultParts(block history.ContentBlock) (string, []Part) {
+	vision := llm.SupportsVision(p.model)
+
+	var texts []string
+	var images []Part
+	for _, part := range history.ToolResultParts(block) {
+		switch {
+		case part.IsImage() && vision:
+			texts = append(texts, fmt.Sprintf("[%s image attached]", part.MIMEType))
+			images = append(images, Part{InlineData: &Blob{
+				MimeType: part.MIMEType,
+				Data:     part.Data,
+			}})
+		case part.IsImage():
+			texts = append(texts, history.ImagePlaceholder(part.MIMEType))
+		default:
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n"), images
+}
+
+// toolCallID creates an ID for the nth function call of a response, as
+// Gemini does not always send one
+func toolCallID(n int) string {
+	return fmt.Sprintf("call_%d_%d", time.Now().UnixNano(), n)
+}
+
+// SetGenerationOptions sets the options used for all following requests
+func (p *Provider) SetGenerationOptions(options llm.GenerationOptions) {
+	p.options = options
+}
+
+func (p *Provider) SupportsTools() bool {
+	return true
+}
+
+func (p *Provider) Name() string {
+	return "gemini"
+}
+
+func (p *Provider) CreateToolResponse(
+	toolCallID string,
+	content interface{},
+) (llm.Message, error) {
+	log.Debug("creating tool response",
+		"tool_call_id", toolCallID,
+		"content_type", fmt.Sprintf("%T", content),
+		"content", content)
+
+	var contentStr string
+	switch v := content.(type) {
+	case string:
+		contentStr = v
+	case []byte:
+		contentStr = string(v)
+	default:
+		jsonBytes, err := json.Marshal(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal tool response: %w", err)
+		}
+		contentStr = string(jsonBytes)
+	}
+
+	return &ToolResponse{ToolCallID: toolCallID, Content: contentStr}, nil
+}
+
+// Message implements the llm.Message interface
+type Message struct {
+	Resp      *APIResponse
+	Candidate *Candidate
+}
+
+// newMessage wraps the first candidate of a response, giving IDs to its
+// function calls
+func newMessage(resp *APIResponse) (*Message, error) {
+	if len(resp.Candidates) == 0 {
+		if resp.PromptFeedback != nil && resp.PromptFeedback.BlockReason != "" {
+			return nil, fmt.Errorf("prompt was blocked: %s", resp.PromptFeedback.BlockReason)
+		}
+		return nil, fmt.Errorf("no candidates in response")
+	}
+
+	candidate := &resp.Candidates[0]
+	for i, part := range candidate.Content.Parts {
+		if part.FunctionCall != nil && part.FunctionCall.ID == "" {
+			part.FunctionCall.ID = toolCallID(i)
+		}
+	}
+	return &Message{Resp: resp, Candidate: candidate}, nil
+}
+
+func (m *Message) GetRole() string {
+	return "assistant"
+}
+
+func (m *Message) GetContent() string {
+	var texts []string
+	for _, part := range m.Candidate.Content.Parts {
+		if part.Text != "" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "")
+}
+
+func (m *Message) GetToolCalls() []llm.ToolCall {
+	var calls []llm.ToolCall
+	for _, part := range m.Candidate.Content.Parts {
+		if part.FunctionCall != nil {
+			calls = append(calls, &ToolCallWrapper{
+				Call:      *part.FunctionCall,
+				Signature: part.ThoughtSignature,
+			})
+		}
+	}
+	return calls
+}
+
+func (m *Message) IsToolResponse() bool {
+	return false
+}
+
+func (m *Message) GetToolResponseID() string {
+	return ""
+}
+
+func (m *Message) GetUsage() (int, int) {
+	return m.Resp.UsageMetadata.PromptTokenCount, m.Resp.UsageMetadata.CandidatesTokenCount
+}
+
+// ToolResponse implements llm.Message for a function response
+type ToolResponse struct {
+	ToolCallID string
+	Content    string
+}
+
+func (m *ToolResponse) GetRole() string {
+	return "user"
+}
+
+func (m *ToolResponse) GetContent() string {
+	return m.Content
+}
+
+func (m *ToolResponse) GetToolCalls() []llm.ToolCall {
+	return nil
+}
+
+func (m *ToolResponse) IsToolResponse() bool {
+	return true
+}
+
+func (m *ToolResponse) GetToolResponseID() string {
+	return m.ToolCallID
+}
+
+func (m *ToolResponse) GetUsage() (int, int) {
+	return 0, 0
+}
+
+// ToolCallWrapper implements llm.SignedToolCall
+type ToolCallWrapper struct {
+	Call      FunctionCall
+	Signature string
+}
+
+func (t *ToolCallWrapper) GetSignature() string {
+	return t.Signature
+}
+
+func (t *ToolCallWrapper) GetID() string {
+	return t.Call.ID
+}
+
+func (t *ToolCallWrapper) GetName() string {
+	return t.Call.Name
+}
+
+func (t *ToolCallWrapper) GetArguments() map[string]interface{} {
+	var args map[string]interface{}
+	if err := json.Unmarshal(t.Call.Args, &args); err != nil {
+		return make(map[string]interface{})
+	}
+	return args
+}
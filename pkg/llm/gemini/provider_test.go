@@ -0,0 +1,388 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vincent-pli/mcphost/pkg/history"
+	"github.com/vincent-pli/mcphost/pkg/llm"
+)
+
+var readTool = llm.Tool{
+	Name:        "fs__read",
+	Description: "Read a file",
+	InputSchema: llm.Schema{
+		Type:       "object",
+		Properties: map[string]interface{}{"path": map[string]interface{}{"type": "string"}},
+		Required:   []string{"path"},
+	},
+}
+
+// geminiServer answers requests for gemini-2.0-flash with handler after
+// checking the API key and method, and records the request bodies
+func geminiServer(t *testing.T, method string, handler func(w http.ResponseWriter)) (*httptest.Server, *[]CreateRequest) {
+	t.Helper()
+	var requests []CreateRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if want := "/v1beta/models/gemini-2.0-flash:" + method; r.URL.Path != want {
+			t.Errorf("path = %s, want %s", r.URL.Path, want)
+		}
+		if got := r.Header.Get("x-goog-api-key"); got != "test-key" {
+			t.Errorf("x-goog-api-key = %q", got)
+		}
+		var req CreateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("error decoding request: %v", err)
+		}
+		requests = append(requests, req)
+		handler(w)
+	}))
+	return server, &requests
+}
+
+func userMessage(text string) *history.HistoryMessage {
+	return &history.HistoryMessage{
+		Role:    "user",
+		Content: []history.ContentBlock{{Type: "text", Text: text}},
+	}
+}
+
+func TestCreateMessageFunctionCallRoundTrip(t *testing.T) {
+	responses := []string{
+		`{
+			"candidates": [{"content": {"role": "model", "parts": [
+				{"text": "Let me read it."},
+				{"functionCall": {"name": "fs__read", "args": {"path": "a.txt"}}, "thoughtSignature": "c2lnbmF0dXJl"}
+			]}, "finishReason": "STOP", "index": 0}],
+			"usageMetadata": {"promptTokenCount": 20, "candidatesTokenCount": 8}
+		}`,
+		`{
+			"candidates": [{"content": {"role": "model", "parts": [{"text": "It says hello."}]}, "finishReason": "STOP", "index": 0}],
+			"usageMetadata": {"promptTokenCount": 40, "candidatesTokenCount": 4}
+		}`,
+	}
+	server, requests := geminiServer(t, "generateContent", func(w http.ResponseWriter) {
+		io.WriteString(w, responses[0])
+		responses = responses[1:]
+	})
+	defer server.Close()
+
+	provider := NewProvider("test-key", server.URL, "gemini-2.0-flash")
+	messages := []llm.Message{
+		&history.HistoryMessage{Role: "system", Content: []history.ContentBlock{{Type: "text", Text: "Be brief."}}},
+		userMessage("What is in a.txt?"),
+	}
+
+	response, err := provider.CreateMessage(context.Background(), "", messages, []llm.Tool{readTool})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.GetContent() != "Let me read it." {
+		t.Errorf("content = %q", response.GetContent())
+	}
+	calls := response.GetToolCalls()
+	if len(calls) != 1 || calls[0].GetName() != "fs__read" || calls[0].GetArguments()["path"] != "a.txt" {
+		t.Fatalf("tool calls = %+v", calls)
+	}
+	if calls[0].GetID() == "" {
+		t.Error("tool call has no ID")
+	}
+	signed, ok := calls[0].(llm.SignedToolCall)
+	if !ok || signed.GetSignature() != "c2lnbmF0dXJl" {
+		t.Errorf("tool call %+v has no thought signature", calls[0])
+	}
+	if input, output := response.GetUsage(); input != 20 || output != 8 {
+		t.Errorf("usage = %d, %d", input, output)
+	}
+
+	first := (*requests)[0]
+	if first.SystemInstruction == nil || first.SystemInstruction.Parts[0].Text != "Be brief." {
+		t.Errorf("system instruction = %+v", first.SystemInstruction)
+	}
+	if len(first.Contents) != 1 || first.Contents[0].Role != "user" {
+		t.Errorf("contents = %+v", first.Contents)
+	}
+	if len(first.Tools) != 1 || first.Tools[0].FunctionDeclarations[0].Name != "fs__read" {
+		t.Errorf("tools = %+v", first.Tools)
+	}
+	if params := first.Tools[0].FunctionDeclarations[0].Parameters; params == nil ||
+		params.Type != "OBJECT" || params.Properties["path"].Type != "STRING" {
+		t.Errorf("parameters = %+v", params)
+	}
+
+	// The call and its result go back as history messages, the call with
+	// its signature
+	args, _ := json.Marshal(calls[0].GetArguments())
+	messages = append(messages,
+		&history.HistoryMessage{Role: "assistant", Content: []history.ContentBlock{
+			{Type: "text", Text: "Let me read it."},
+			{Type: "tool_use", ID: calls[0].GetID(), Name: calls[0].GetName(), Input: args, Signature: signed.GetSignature()},
+		}},
+		&history.HistoryMessage{Role: "user", Content: []history.ContentBlock{{
+			Type:      "tool_result",
+			ToolUseID: calls[0].GetID(),
+			Content:   []interface{}{map[string]interface{}{"type": "text", "text": "hello"}},
+		}}},
+	)
+	response, err = provider.CreateMessage(context.Background(), "", messages, []llm.Tool{readTool})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.GetContent() != "It says hello." {
+		t.Errorf("content = %q", response.GetContent())
+	}
+
+	second := (*requests)[1]
+	if len(second.Contents) != 3 {
+		t.Fatalf("contents = %+v", second.Contents)
+	}
+	model := second.Contents[1]
+	if model.Role != "model" || len(model.Parts) != 2 || model.Parts[1].FunctionCall == nil {
+		t.Fatalf("model content = %+v", model)
+	}
+	if call := model.Parts[1].FunctionCall; call.Name != "fs__read" || string(call.Args) != `{"path":"a.txt"}` {
+		t.Errorf("function call = %+v", call)
+	}
+	if model.Parts[1].ThoughtSignature != "c2lnbmF0dXJl" {
+		t.Errorf("thought signature = %q, want it sent back", model.Parts[1].ThoughtSignature)
+	}
+	if model.Parts[0].ThoughtSignature != "" {
+		t.Errorf("text part has thought signature %q", model.Parts[0].ThoughtSignature)
+	}
+	result := second.Contents[2]
+	if result.Role != "user" || len(result.Parts) != 1 || result.Parts[0].FunctionResponse == nil {
+		t.Fatalf("function response content = %+v", result)
+	}
+	if fr := result.Parts[0].FunctionResponse; fr.Name != "fs__read" || fr.Response["result"] != "hello" {
+		t.Errorf("function response = %+v", fr)
+	}
+}
+
+func TestCreateMessageGenerationConfig(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		io.WriteString(w, `{"candidates": [{"content": {"parts": [{"text": "ok"}]}, "index": 0}]}`)
+	}))
+	defer server.Close()
+
+	provider := NewProvider("test-key", server.URL, "gemini-2.0-flash")
+	messages := []llm.Message{userMessage("Hi")}
+
+	if _, err := provider.CreateMessage(context.Background(), "", messages, nil); err != nil {
+		t.Fatal(err)
+	}
+	config, _ := body["generationConfig"].(map[string]interface{})
+	if _, ok := config["temperature"]; ok {
+		t.Errorf("temperature sent without being set: %v", config)
+	}
+
+	temperature, topP := 0.0, 0.9
+	provider.SetGenerationOptions(llm.GenerationOptions{
+		Temperature: &temperature,
+		TopP:        &topP,
+		Stop:        []string{"END"},
+	})
+	if _, err := provider.CreateMessage(context.Background(), "", messages, nil); err != nil {
+		t.Fatal(err)
+	}
+	config, _ = body["generationConfig"].(map[string]interface{})
+	if config["temperature"] != 0.0 || config["topP"] != 0.9 {
+		t.Errorf("generation config = %v", config)
+	}
+	if stop, _ := config["stopSequences"].([]interface{}); len(stop) != 1 || stop[0] != "END" {
+		t.Errorf("stop sequences = %v", config["stopSequences"])
+	}
+}
+
+func TestCreateMessageError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `{"error": {"code": 400, "message": "API key not valid.", "status": "INVALID_ARGUMENT",
+			"details": [{"reason": "API_KEY_INVALID"}]}}`)
+	}))
+	defer server.Close()
+
+	provider := NewProvider("test-key", server.URL, "gemini-2.0-flash")
+	_, err := provider.CreateMessage(context.Background(), "", []llm.Message{userMessage("Hi")}, nil)
+	if !errors.Is(err, llm.ErrAuthFailed) {
+		t.Errorf("error = %v, want an authentication error", err)
+	}
+}
+
+func TestCreateMessageInvalidToolSchema(t *testing.T) {
+	provider := NewProvider("test-key", "http://127.0.0.1:0", "gemini-2.0-flash")
+	tool := llm.Tool{
+		Name: "db__query",
+		InputSchema: llm.Schema{
+			Type:       "object",
+			Properties: map[string]interface{}{"filter": map[string]interface{}{"$ref": "#/$defs/Filter"}},
+		},
+	}
+
+	_, err := provider.CreateMessage(context.Background(), "", []llm.Message{userMessage("Hi")}, []llm.Tool{tool})
+	if err == nil || !strings.Contains(err.Error(), "db__query") || !strings.Contains(err.Error(), "#/$defs/Filter") {
+		t.Errorf("error = %v, want one naming the tool and the reference", err)
+	}
+}
+
+func TestStreamMessage(t *testing.T) {
+	server, _ := geminiServer(t, "streamGenerateContent", func(w http.ResponseWriter) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range []string{
+			`{"candidates": [{"content": {"role": "model", "parts": [{"text": "Reading"}]}, "index": 0}]}`,
+			`{"candidates": [{"content": {"role": "model", "parts": [{"text": " the file."}]}, "index": 0}]}`,
+			`{"candidates": [{"content": {"role": "model", "parts": [{"functionCall": {"name": "fs__read", "args": {"path": "a.txt"}}, "thoughtSignature": "c2lnbmF0dXJl"}]}, "index": 0}]}`,
+			`{"candidates": [{"content": {"role": "model", "parts": []}, "finishReason": "STOP", "index": 0}],
+			  "usageMetadata": {"promptTokenCount": 15, "candidatesTokenCount": 9}}`,
+		} {
+			io.WriteString(w, "data: "+strings.ReplaceAll(chunk, "\n", "")+"\n\n")
+		}
+	})
+	defer server.Close()
+
+	provider := NewProvider("test-key", server.URL, "gemini-2.0-flash")
+	events, err := provider.StreamMessage(context.Background(), "", []llm.Message{userMessage("Read a.txt")}, []llm.Tool{readTool})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var text strings.Builder
+	var toolEvents []llm.StreamEvent
+	var done llm.Message
+	for event := range events {
+		switch event.Type {
+		case llm.StreamEventText:
+			text.WriteString(event.Text)
+		case llm.StreamEventToolCall:
+			toolEvents = append(toolEvents, event)
+		case llm.StreamEventError:
+			t.Fatal(event.Err)
+		case llm.StreamEventDone:
+			done = event.Message
+		}
+	}
+
+	if text.String() != "Reading the file." {
+		t.Errorf("streamed text = %q", text.String())
+	}
+	if len(toolEvents) != 1 || toolEvents[0].ToolName != "fs__read" {
+		t.Fatalf("tool call events = %+v", toolEvents)
+	}
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(toolEvents[0].ToolArguments), &args); err != nil || args["path"] != "a.txt" {
+		t.Errorf("tool call arguments = %s", toolEvents[0].ToolArguments)
+	}
+	if done == nil {
+		t.Fatal("stream ended without a message")
+	}
+	if done.GetContent() != "Reading the file." {
+		t.Errorf("content = %q", done.GetContent())
+	}
+	calls := done.GetToolCalls()
+	if len(calls) != 1 || calls[0].GetID() != toolEvents[0].ToolCallID {
+		t.Fatalf("tool calls = %+v, want the streamed call", calls)
+	}
+	if signed, ok := calls[0].(llm.SignedToolCall); !ok || signed.GetSignature() != "c2lnbmF0dXJl" {
+		t.Errorf("tool call %+v has no thought signature", calls[0])
+	}
+	if input, output := done.GetUsage(); input != 15 || output != 9 {
+		t.Errorf("usage = %d, %d", input, output)
+	}
+}
+
+func TestStreamMessageError(t *testing.T) {
+	server, _ := geminiServer(t, "streamGenerateContent", func(w http.ResponseWriter) {
+		io.WriteString(w, "data: {\"candidates\": [{\"content\": {\"parts\": [{\"text\": \"Hi\"}]}, \"index\": 0}]}\n\n")
+		io.WriteString(w, "data: {\"error\": {\"code\": 503, \"message\": \"The model is overloaded.\", \"status\": \"UNAVAILABLE\"}}\n\n")
+	})
+	defer server.Close()
+
+	provider := NewProvider("test-key", server.URL, "gemini-2.0-flash")
+	events, err := provider.StreamMessage(context.Background(), "", []llm.Message{userMessage("Hi")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var streamErr error
+	for event := range events {
+		if event.Type == llm.StreamEventError {
+			streamErr = event.Err
+		}
+	}
+	if streamErr == nil || !strings.Contains(streamErr.Error(), "The model is overloaded.") {
+		t.Errorf("error = %v", streamErr)
+	}
+}
+
+func TestStreamMessageEnd(t *testing.T) {
+	tests := []struct {
+		name         string
+		chunks       []string
+		wantComplete bool
+	}{
+		{
+			name: "finish reason",
+			chunks: []string{
+				`{"candidates": [{"content": {"role": "model", "parts": [{"text": "Hi"}]}, "index": 0}]}`,
+				`{"candidates": [{"content": {"role": "model", "parts": [{"text": "."}]}, "finishReason": "STOP", "index": 0}]}`,
+			},
+			wantComplete: true,
+		},
+		{
+			name: "blocked prompt",
+			chunks: []string{
+				`{"promptFeedback": {"blockReason": "SAFETY"}}`,
+			},
+			wantComplete: true,
+		},
+		{
+			name: "no finish reason",
+			chunks: []string{
+				`{"candidates": [{"content": {"role": "model", "parts": [{"text": "Half an"}]}, "index": 0}]}`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := geminiServer(t, "streamGenerateContent", func(w http.ResponseWriter) {
+				for _, chunk := range tt.chunks {
+					io.WriteString(w, "data: "+chunk+"\n\n")
+				}
+			})
+			defer server.Close()
+
+			provider := NewProvider("test-key", server.URL, "gemini-2.0-flash")
+			events, err := provider.StreamMessage(context.Background(), "", []llm.Message{userMessage("Hi")}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var streamErr error
+			for event := range events {
+				if event.Type == llm.StreamEventError {
+					streamErr = event.Err
+				}
+			}
+			if tt.wantComplete {
+				// A blocked prompt is reported, but not as a broken stream
+				if errors.Is(streamErr, llm.ErrTransient) {
+					t.Errorf("error = %v, want a complete stream", streamErr)
+				}
+				return
+			}
+			var providerErr *llm.ProviderError
+			if !errors.As(streamErr, &providerErr) || !errors.Is(streamErr, llm.ErrTransient) || !providerErr.Retryable() {
+				t.Errorf("error = %v, want a retryable %v", streamErr, llm.ErrTransient)
+			}
+		})
+	}
+}
@@ -0,0 +1,328 @@
+package gemini
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vincent-pli/mcphost/pkg/llm"
+)
+
+// Gemini only accepts an OpenAPI subset of JSON Schema. It rejects unknown
+// keywords such as additionalProperties or $schema, takes types in upper
+// case, has no type unions or references and only allows enums of strings.
+
+// supportedFormats lists the formats Gemini accepts per type
+var supportedFormats = map[string][]string{
+	"STRING":  {"enum", "date-time"},
+	"NUMBER":  {"float", "double"},
+	"INTEGER": {"int32", "int64"},
+}
+
+// convertSchema converts a tool's input schema to function parameters. It
+// returns nil for tools without parameters, as Gemini rejects objects
+// without properties. Gemini has no references, so $ref is replaced by the
+// schema it points to. References that cannot be inlined, e.g. to other
+// documents or recursive ones, are an error.
+func convertSchema(schema llm.Schema) (*Schema, error) {
+	if len(schema.Properties) == 0 {
+		return nil, nil
+	}
+
+	root := map[string]interface{}{
+		"type":       "object",
+		"properties": schema.Properties,
+		"required":   toInterfaces(schema.Required),
+	}
+	if len(schema.Defs) > 0 {
+		root["$defs"] = schema.Defs
+	}
+
+	converter := &schemaConverter{root: root, resolving: make(map[string]bool)}
+	return converter.convert(root)
+}
+
+// schemaConverter converts the schemas of a document, resolving the
+// references in it
+type schemaConverter struct {
+	root map[string]interface{}
+
+	// resolving holds the references being inlined, to detect recursion
+	resolving map[string]bool
+}
+
+func (c *schemaConverter) convert(property map[string]interface{}) (*Schema, error) {
+	if ref, ok := property["$ref"].(string); ok {
+		return c.convertRef(ref, property)
+	}
+
+	schema := &Schema{}
+	schema.Description, _ = property["description"].(string)
+
+	// Unions become anyOf, a null member makes the schema nullable
+	var types []string
+	switch t := property["type"].(type) {
+	case string:
+		types = []string{t}
+	case []interface{}:
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+	}
+	for _, key := range []string{"anyOf", "oneOf"} {
+		variants, ok := property[key].([]interface{})
+		if !ok {
+			continue
+		}
+		for _, variant := range variants {
+			v, ok := variant.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if v["type"] == "null" {
+				schema.Nullable = true
+				continue
+			}
+			converted, err := c.convert(v)
+			if err != nil {
+				return nil, err
+			}
+			schema.AnyOf = append(schema.AnyOf, converted)
+		}
+	}
+	types = removeNull(types, &schema.Nullable)
+	if len(schema.AnyOf) == 1 {
+		// A single variant left after removing null needs no union
+		variant := schema.AnyOf[0]
+		variant.Nullable = variant.Nullable || schema.Nullable
+		if variant.Description == "" {
+			variant.Description = schema.Description
+		}
+		return variant, nil
+	}
+	if len(schema.AnyOf) > 0 {
+		return schema, nil
+	}
+	if len(types) > 1 {
+		for _, t := range types {
+			variant, err := c.convert(withType(property, t))
+			if err != nil {
+				return nil, err
+			}
+			variant.Description = ""
+			schema.AnyOf = append(schema.AnyOf, variant)
+		}
+		return schema, nil
+	}
+
+	var typ string
+	if len(types) == 1 {
+		typ = types[0]
+	} else {
+		typ = inferType(property)
+	}
+	schema.Type = strings.ToUpper(typ)
+
+	if format, ok := property["format"].(string); ok {
+		for _, supported := range supportedFormats[schema.Type] {
+			if format == supported {
+				schema.Format = format
+			}
+		}
+	}
+
+	if values := enumValues(property); len(values) > 0 {
+		if schema.Type == "STRING" {
+			schema.Enum = values
+		} else {
+			// Other types cannot have an enum, tell the model instead
+			schema.Description = strings.TrimSpace(fmt.Sprintf(
+				"%s (one of: %s)", schema.Description, strings.Join(values, ", ")))
+		}
+	}
+
+	switch schema.Type {
+	case "OBJECT":
+		properties, _ := property["properties"].(map[string]interface{})
+		if len(properties) > 0 {
+			schema.Properties = make(map[string]*Schema, len(properties))
+		}
+		for name, p := range properties {
+			p, ok := p.(map[string]interface{})
+			if !ok {
+				schema.Properties[name] = &Schema{Type: "STRING"}
+				continue
+			}
+			converted, err := c.convert(p)
+			if err != nil {
+				return nil, err
+			}
+			schema.Properties[name] = converted
+		}
+
+		// Gemini rejects required properties that are not defined
+		required, _ := property["required"].([]interface{})
+		for _, name := range required {
+			if name, ok := name.(string); ok && schema.Properties[name] != nil {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+		sort.Strings(schema.Required)
+
+	case "ARRAY":
+		if items, ok := property["items"].(map[string]interface{}); ok {
+			converted, err := c.convert(items)
+			if err != nil {
+				return nil, err
+			}
+			schema.Items = converted
+		} else {
+			schema.Items = &Schema{Type: "STRING"}
+		}
+		schema.MinItems = intValue(property["minItems"])
+		schema.MaxItems = intValue(property["maxItems"])
+
+	case "NUMBER", "INTEGER":
+		schema.Minimum = floatValue(property["minimum"])
+		schema.Maximum = floatValue(property["maximum"])
+	}
+
+	return schema, nil
+}
+
+// convertRef converts the schema a $ref points to. A description next to
+// the $ref replaces the one of the referenced schema.
+func (c *schemaConverter) convertRef(ref string, property map[string]interface{}) (*Schema, error) {
+	if c.resolving[ref] {
+		return nil, fmt.Errorf("recursive schema reference %s is not supported by Gemini", ref)
+	}
+	target, err := c.resolve(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	c.resolving[ref] = true
+	schema, err := c.convert(target)
+	delete(c.resolving, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if description, ok := property["description"].(string); ok && description != "" {
+		schema.Description = description
+	}
+	return schema, nil
+}
+
+// resolve returns the schema a reference within the document points to,
+// e.g. "#/$defs/Address"
+func (c *schemaConverter) resolve(ref string) (map[string]interface{}, error) {
+	pointer, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil, fmt.Errorf("schema reference %s to another document is not supported", ref)
+	}
+
+	var current interface{} = c.root
+	if pointer != "" {
+		for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+			token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+			switch value := current.(type) {
+			case map[string]interface{}:
+				current = value[token]
+			case []interface{}:
+				index, err := strconv.Atoi(token)
+				if err != nil || index < 0 || index >= len(value) {
+					return nil, fmt.Errorf("schema reference %s not found", ref)
+				}
+				current = value[index]
+			default:
+				current = nil
+			}
+			if current == nil {
+				return nil, fmt.Errorf("schema reference %s not found", ref)
+			}
+		}
+	}
+
+	target, ok := current.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("schema reference %s does not point to a schema", ref)
+	}
+	return target, nil
+}
+
+// inferType guesses the type of a schema that does not declare one
+func inferType(property map[string]interface{}) string {
+	switch {
+	case property["properties"] != nil:
+		return "object"
+	case property["items"] != nil:
+		return "array"
+	default:
+		return "string"
+	}
+}
+
+// enumValues returns the values of enum or const as strings
+func enumValues(property map[string]interface{}) []string {
+	var values []string
+	if enum, ok := property["enum"].([]interface{}); ok {
+		for _, value := range enum {
+			if value != nil {
+				values = append(values, fmt.Sprint(value))
+			}
+		}
+	}
+	if value, ok := property["const"]; ok && value != nil {
+		values = append(values, fmt.Sprint(value))
+	}
+	return values
+}
+
+func removeNull(types []string, nullable *bool) []string {
+	var result []string
+	for _, t := range types {
+		if t == "null" {
+			*nullable = true
+			continue
+		}
+		result = append(result, t)
+	}
+	return result
+}
+
+// withType returns a copy of property with a single type
+func withType(property map[string]interface{}, typ string) map[string]interface{} {
+	result := make(map[string]interface{}, len(property))
+	for key, value := range property {
+		result[key] = value
+	}
+	result["type"] = typ
+	return result
+}
+
+func toInterfaces(values []string) []interface{} {
+	result := make([]interface{}, len(values))
+	for i, value := range values {
+		result[i] = value
+	}
+	return result
+}
+
+func floatValue(value interface{}) *float64 {
+	if f, ok := value.(float64); ok {
+		return &f
+	}
+	return nil
+}
+
+func intValue(value interface{}) *int64 {
+	if f, ok := value.(float64); ok {
+		i := int64(f)
+		return &i
+	}
+	return nil
+}
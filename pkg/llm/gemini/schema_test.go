@@ -0,0 +1,242 @@
+package gemini
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/vincent-pli/mcphost/pkg/llm"
+)
+
+// parseSchema decodes a tool's input schema like the MCP client does
+func parseSchema(t *testing.T, data string) llm.Schema {
+	t.Helper()
+	var schema struct {
+		Properties map[string]interface{} `json:"properties"`
+		Required   []string               `json:"required"`
+		Defs       map[string]interface{} `json:"$defs"`
+	}
+	if err := json.Unmarshal([]byte(data), &schema); err != nil {
+		t.Fatal(err)
+	}
+	return llm.Schema{Type: "object", Properties: schema.Properties, Required: schema.Required, Defs: schema.Defs}
+}
+
+func TestConvertSchema(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		want   string
+	}{
+		{
+			name:   "no properties",
+			schema: `{"properties": {}}`,
+			want:   `null`,
+		},
+		{
+			name: "types and formats",
+			schema: `{
+				"properties": {
+					"path": {"type": "string", "description": "File path"},
+					"when": {"type": "string", "format": "date-time"},
+					"email": {"type": "string", "format": "email"},
+					"count": {"type": "integer", "format": "int32", "minimum": 1, "maximum": 10},
+					"ratio": {"type": "number"},
+					"force": {"type": "boolean"}
+				},
+				"required": ["path", "missing"],
+				"additionalProperties": false,
+				"$schema": "http://json-schema.org/draft-07/schema#"
+			}`,
+			want: `{"type": "OBJECT", "properties": {
+				"path": {"type": "STRING", "description": "File path"},
+				"when": {"type": "STRING", "format": "date-time"},
+				"email": {"type": "STRING"},
+				"count": {"type": "INTEGER", "format": "int32", "minimum": 1, "maximum": 10},
+				"ratio": {"type": "NUMBER"},
+				"force": {"type": "BOOLEAN"}
+			}, "required": ["path"]}`,
+		},
+		{
+			name: "nullable type union",
+			schema: `{"properties": {
+				"name": {"type": ["string", "null"], "description": "Optional name"}
+			}}`,
+			want: `{"type": "OBJECT", "properties": {
+				"name": {"type": "STRING", "description": "Optional name", "nullable": true}
+			}}`,
+		},
+		{
+			name: "type union",
+			schema: `{"properties": {
+				"value": {"type": ["string", "number"], "description": "A value"}
+			}}`,
+			want: `{"type": "OBJECT", "properties": {
+				"value": {"description": "A value", "anyOf": [{"type": "STRING"}, {"type": "NUMBER"}]}
+			}}`,
+		},
+		{
+			name: "anyOf with null",
+			schema: `{"properties": {
+				"limit": {"anyOf": [{"type": "integer"}, {"type": "null"}], "description": "Limit"}
+			}}`,
+			want: `{"type": "OBJECT", "properties": {
+				"limit": {"type": "INTEGER", "description": "Limit", "nullable": true}
+			}}`,
+		},
+		{
+			name: "oneOf",
+			schema: `{"properties": {
+				"target": {"oneOf": [
+					{"type": "string"},
+					{"type": "object", "properties": {"id": {"type": "integer"}}, "required": ["id"]}
+				]}
+			}}`,
+			want: `{"type": "OBJECT", "properties": {
+				"target": {"anyOf": [
+					{"type": "STRING"},
+					{"type": "OBJECT", "properties": {"id": {"type": "INTEGER"}}, "required": ["id"]}
+				]}
+			}}`,
+		},
+		{
+			name: "enums",
+			schema: `{"properties": {
+				"mode": {"type": "string", "enum": ["read", "write", null]},
+				"level": {"type": "integer", "enum": [1, 2, 3], "description": "Level"},
+				"kind": {"const": "file"}
+			}}`,
+			want: `{"type": "OBJECT", "properties": {
+				"mode": {"type": "STRING", "enum": ["read", "write"]},
+				"level": {"type": "INTEGER", "description": "Level (one of: 1, 2, 3)"},
+				"kind": {"type": "STRING", "enum": ["file"]}
+			}}`,
+		},
+		{
+			name: "nested objects and arrays",
+			schema: `{"properties": {
+				"options": {
+					"properties": {
+						"tags": {"type": "array", "items": {"type": "string"}, "minItems": 1, "maxItems": 5},
+						"owner": {"type": "object", "properties": {"name": {"type": "string"}}, "required": ["name"]}
+					}
+				},
+				"ids": {"type": "array"}
+			}}`,
+			want: `{"type": "OBJECT", "properties": {
+				"options": {"type": "OBJECT", "properties": {
+					"tags": {"type": "ARRAY", "items": {"type": "STRING"}, "minItems": 1, "maxItems": 5},
+					"owner": {"type": "OBJECT", "properties": {"name": {"type": "STRING"}}, "required": ["name"]}
+				}},
+				"ids": {"type": "ARRAY", "items": {"type": "STRING"}}
+			}}`,
+		},
+		{
+			name: "references",
+			schema: `{
+				"properties": {
+					"home": {"$ref": "#/$defs/Address", "description": "Home address"},
+					"work": {"anyOf": [{"$ref": "#/$defs/Address"}, {"type": "null"}]},
+					"previous": {"type": "array", "items": {"$ref": "#/$defs/Address"}},
+					"copy": {"$ref": "#/properties/home"}
+				},
+				"$defs": {
+					"Address": {
+						"type": "object",
+						"description": "An address",
+						"properties": {
+							"street": {"type": "string"},
+							"country": {"$ref": "#/$defs/Country"}
+						},
+						"required": ["street"]
+					},
+					"Country": {"type": "string", "enum": ["DE", "FR"]}
+				}
+			}`,
+			want: `{"type": "OBJECT", "properties": {
+				"home": {"type": "OBJECT", "description": "Home address", "properties": {
+					"street": {"type": "STRING"},
+					"country": {"type": "STRING", "enum": ["DE", "FR"]}
+				}, "required": ["street"]},
+				"work": {"type": "OBJECT", "description": "An address", "nullable": true, "properties": {
+					"street": {"type": "STRING"},
+					"country": {"type": "STRING", "enum": ["DE", "FR"]}
+				}, "required": ["street"]},
+				"previous": {"type": "ARRAY", "items": {"type": "OBJECT", "description": "An address", "properties": {
+					"street": {"type": "STRING"},
+					"country": {"type": "STRING", "enum": ["DE", "FR"]}
+				}, "required": ["street"]}},
+				"copy": {"type": "OBJECT", "description": "Home address", "properties": {
+					"street": {"type": "STRING"},
+					"country": {"type": "STRING", "enum": ["DE", "FR"]}
+				}, "required": ["street"]}
+			}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := convertSchema(parseSchema(t, tt.schema))
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, _ := json.Marshal(schema)
+
+			// Both are marshaled from maps to compare them regardless of
+			// the order of their keys
+			var gotValue, wantValue interface{}
+			json.Unmarshal(data, &gotValue)
+			if err := json.Unmarshal([]byte(tt.want), &wantValue); err != nil {
+				t.Fatal(err)
+			}
+			got, _ := json.Marshal(gotValue)
+			want, _ := json.Marshal(wantValue)
+			if string(got) != string(want) {
+				t.Errorf("convertSchema:\n got %s\nwant %s", got, want)
+			}
+		})
+	}
+}
+
+func TestConvertSchemaInvalidReferences(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		wantErr string
+	}{
+		{
+			name:    "missing definition",
+			schema:  `{"properties": {"a": {"$ref": "#/$defs/Missing"}}}`,
+			wantErr: "schema reference #/$defs/Missing not found",
+		},
+		{
+			name:    "other document",
+			schema:  `{"properties": {"a": {"$ref": "https://example.com/schema.json"}}}`,
+			wantErr: "to another document is not supported",
+		},
+		{
+			name: "recursive",
+			schema: `{
+				"properties": {"tree": {"$ref": "#/$defs/Node"}},
+				"$defs": {"Node": {"type": "object", "properties": {
+					"children": {"type": "array", "items": {"$ref": "#/$defs/Node"}}
+				}}}
+			}`,
+			wantErr: "recursive schema reference #/$defs/Node",
+		},
+		{
+			name:    "not a schema",
+			schema:  `{"properties": {"a": {"$ref": "#/required/0"}}, "required": ["a"]}`,
+			wantErr: "does not point to a schema",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := convertSchema(parseSchema(t, tt.schema))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package gemini
+
+import "encoding/json"
+
+type CreateRequest struct {
+	SystemInstruction *Content          `json:"systemInstruction,omitempty"`
+	Contents          []Content         `json:"contents"`
+	Tools             []Tool            `json:"tools,omitempty"`
+	GenerationConfig  *GenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type Content struct {
+	Role  string `json:"role,omitempty"`
+	Parts []Part `json:"parts"`
+}
+
+// Part is one piece of a content, exactly one of its fields except
+// ThoughtSignature is set
+type Part struct {
+	Text             string            `json:"text,omitempty"`
+	FunctionCall     *FunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *FunctionResponse `json:"functionResponse,omitempty"`
+	InlineData       *Blob             `json:"inlineData,omitempty"`
+
+	// ThoughtSignature is set by thinking models on function calls and
+	// must be sent back with the call
+	ThoughtSignature string `json:"thoughtSignature,omitempty"`
+}
+
+// Blob is inline data of a part, e.g. an image
+type Blob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type FunctionCall struct {
+	// ID is only set by some models, calls are matched by name otherwise
+	ID   string          `json:"id,omitempty"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type FunctionResponse struct {
+	ID       string                 `json:"id,omitempty"`
+	Name     string                 `json:"name"`
+	Response map[string]interface{} `json:"response"`
+}
+
+type Tool struct {
+	FunctionDeclarations []FunctionDeclaration `json:"functionDeclarations"`
+}
+
+type FunctionDeclaration struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	Parameters  *Schema `json:"parameters,omitempty"`
+}
+
+// Schema is the OpenAPI subset Gemini accepts for function parameters
+type Schema struct {
+	Type        string             `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Nullable    bool               `json:"nullable,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	AnyOf       []*Schema          `json:"anyOf,omitempty"`
+	Minimum     *float64           `json:"minimum,omitempty"`
+	Maximum     *float64           `json:"maximum,omitempty"`
+	MinItems    *int64             `json:"minItems,omitempty"`
+	MaxItems    *int64             `json:"maxItems,omitempty"`
+}
+
+type GenerationConfig struct {
+	MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
+	Temperature     *float64 `json:"temperature,omitempty"`
+	TopP            *float64 `json:"topP,omitempty"`
+	StopSequences   []string `json:"stopSequences,omitempty"`
+}
+
+type APIResponse struct {
+	Candidates     []Candidate     `json:"candidates"`
+	PromptFeedback *PromptFeedback `json:"promptFeedback,omitempty"`
+	UsageMetadata  UsageMetadata   `json:"usageMetadata"`
+	ModelVersion   string          `json:"modelVersion,omitempty"`
+
+	// Error is only set on errors reported inside a stream
+	Error *ErrorDetail `json:"error,omitempty"`
+}
+
+type Candidate struct {
+	Content      Content `json:"content"`
+	FinishReason string  `json:"finishReason,omitempty"`
+	Index        int     `json:"index"`
+}
+
+type PromptFeedback struct {
+	BlockReason string `json:"blockReason,omitempty"`
+}
+
+type UsageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
+}
+
+type ErrorDetail struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+	Details []struct {
+		Reason string `json:"reason,omitempty"`
+	} `json:"details,omitempty"`
+}
+
+// errorType returns the most specific name of the error. The reason, e.g.
+// API_KEY_INVALID, is more specific than the status.
+func (e ErrorDetail) errorType() string {
+	for _, detail := range e.Details {
+		if detail.Reason != "" {
+			return detail.Reason
+		}
+	}
+	return e.Status
+}
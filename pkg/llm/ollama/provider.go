@@ -2,7 +2,9 @@ package ollama
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 
@@ -18,8 +20,9 @@ func boolPtr(b bool) *bool {
 
 // Provider implements the Provider interface for Ollama
 type Provider struct {
-	client *api.Client
-	model  string
+	client  *api.Client
+	model   string
+	options llm.GenerationOptions
 }
 
 // NewProvider creates a new Ollama provider
@@ -40,6 +43,140 @@ func (p *Provider) CreateMessage(
 	messages []llm.Message,
 	tools []llm.Tool,
 ) (llm.Message, error) {
+	req := p.createRequest(prompt, messages, tools)
+	req.Stream = boolPtr(false)
+
+	var response api.Message
+	err := p.client.Chat(ctx, req, func(r api.ChatResponse) error {
+		if r.Done {
+			response = r.Message
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, providerError(err)
+	}
+
+	return &OllamaMessage{Message: response}, nil
+}
+
+func (p *Provider) StreamMessage(
+	ctx context.Context,
+	prompt string,
+	messages []llm.Message,
+	tools []llm.Tool,
+) (<-chan llm.StreamEvent, error) {
+	req := p.createRequest(prompt, messages, tools)
+	req.Stream = boolPtr(true)
+
+	events := make(chan llm.StreamEvent)
+	send := func(event llm.StreamEvent) error {
+		select {
+		case events <- event:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	go func() {
+		defer close(events)
+
+		response := api.Message{Role: "assistant"}
+		var content strings.Builder
+		// The last chunk is marked done
+		done := false
+
+		err := p.client.Chat(ctx, req, func(r api.ChatResponse) error {
+			done = done || r.Done
+			if r.Message.Content != "" {
+				content.WriteString(r.Message.Content)
+				if err := send(llm.StreamEvent{
+					Type: llm.StreamEventText,
+					Text: r.Message.Content,
+				}); err != nil {
+					return err
+				}
+			}
+
+			// Ollama sends every tool call complete in a single chunk
+			for _, call := range r.Message.ToolCalls {
+				response.ToolCalls = append(response.ToolCalls, call)
+				args, _ := json.Marshal(call.Function.Arguments)
+				if err := send(llm.StreamEvent{
+					Type:          llm.StreamEventToolCall,
+					ToolName:      call.Function.Name,
+					ToolArguments: string(args),
+				}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			_ = send(llm.StreamEvent{Type: llm.StreamEventError, Err: providerError(err)})
+			return
+		}
+		if !done {
+			_ = send(llm.StreamEvent{Type: llm.StreamEventError, Err: llm.NewIncompleteStreamError()})
+			return
+		}
+
+		response.Content = content.String()
+		_ = send(llm.StreamEvent{
+			Type:    llm.StreamEventDone,
+			Message: &OllamaMessage{Message: response},
+		})
+	}()
+
+	return events, nil
+}
+
+// toolResultMessages converts the tool results of a message to one tool
+// message each, with the images of the result attached. Models without
+// vision get a placeholder for images.
+func (p *Provider) toolResultMessages(msg *history.HistoryMessage) []api.Message {
+	vision := llm.SupportsVision(p.model)
+
+	var messages []api.Message
+	for _, block := range msg.Content {
+		if block.Type != "tool_result" {
+			continue
+		}
+
+		var texts []string
+		var images []api.ImageData
+		for _, part := range history.ToolResultParts(block) {
+			if !part.IsImage() {
+				texts = append(texts, part.Text)
+				continue
+			}
+			data, err := base64.StdEncoding.DecodeString(part.Data)
+			if err != nil || !vision {
+				texts = append(texts, history.ImagePlaceholder(part.MIMEType))
+				continue
+			}
+			images = append(images, data)
+		}
+
+		if len(texts) == 0 && len(images) == 0 {
+			continue
+		}
+		messages = append(messages, api.Message{
+			Role:    "tool",
+			Content: strings.Join(texts, "\n"),
+			Images:  images,
+		})
+	}
+	return messages
+}
+
+func (p *Provider) createRequest(
+	prompt string,
+	messages []llm.Message,
+	tools []llm.Tool,
+) *api.ChatRequest {
 	log.Debug("creating message",
 		"prompt", prompt,
 		"num_messages", len(messages),
@@ -52,22 +189,13 @@ func (p *Provider) CreateMessage(
 	for _, msg := range messages {
 		// Handle tool responses
 		if msg.IsToolResponse() {
-			var content string
-
-			// Handle HistoryMessage format
+			// Every result of a history message becomes a tool message
 			if historyMsg, ok := msg.(*history.HistoryMessage); ok {
-				for _, block := range historyMsg.Content {
-					if block.Type == "tool_result" {
-						content = block.Text
-						break
-					}
-				}
+				ollamaMessages = append(ollamaMessages, p.toolResultMessages(historyMsg)...)
+				continue
 			}
 
-			// If no content found yet, try standard content extraction
-			if content == "" {
-				content = msg.GetContent()
-			}
+			content := msg.GetContent()
 
 			if content == "" {
 				continue
@@ -91,6 +219,26 @@ func (p *Provider) CreateMessage(
 			Content: msg.GetContent(),
 		}
 
+		// Images are attached to the message, text files inlined
+		if historyMsg, ok := msg.(*history.HistoryMessage); ok {
+			var texts []string
+			for _, block := range historyMsg.GetAttachments() {
+				switch {
+				case block.Type == "image":
+					if data, err := base64.StdEncoding.DecodeString(block.Data); err == nil {
+						ollamaMsg.Images = append(ollamaMsg.Images, data)
+					}
+				case block.Data != "":
+					texts = append(texts, fmt.Sprintf("[%s omitted: %s files are not supported]", block.Name, block.MediaType))
+				default:
+					texts = append(texts, fmt.Sprintf("Contents of %s:\n\n%s", block.Name, block.Text))
+				}
+			}
+			if len(texts) > 0 {
+				ollamaMsg.Content = strings.Join(append(texts, ollamaMsg.Content), "\n\n")
+			}
+		}
+
 		// Add tool calls for assistant messages
 		if msg.GetRole() == "assistant" {
 			for _, call := range msg.GetToolCalls() {
@@ -145,33 +293,43 @@ func (p *Provider) CreateMessage(
 		}
 	}
 
-	var response api.Message
-	log.Debug("creating message",
-		"prompt", prompt,
-		"num_messages", len(messages),
-		"num_tools", len(tools))
-
 	log.Debug("sending messages to Ollama",
 		"messages", ollamaMessages,
 		"num_tools", len(tools))
 
-	err := p.client.Chat(ctx, &api.ChatRequest{
+	return &api.ChatRequest{
 		Model:    p.model,
 		Messages: ollamaMessages,
 		Tools:    ollamaTools,
-		Stream:   boolPtr(false),
-	}, func(r api.ChatResponse) error {
-		if r.Done {
-			response = r.Message
-		}
-		return nil
-	})
+		Options:  p.requestOptions(),
+	}
+}
 
-	if err != nil {
-		return nil, err
+// requestOptions maps the generation options to Ollama's option names.
+// Unset options keep the model's defaults.
+func (p *Provider) requestOptions() map[string]interface{} {
+	options := make(map[string]interface{})
+	if p.options.Temperature != nil {
+		options["temperature"] = *p.options.Temperature
+	}
+	if p.options.MaxTokens != nil {
+		options["num_predict"] = *p.options.MaxTokens
+	}
+	if p.options.TopP != nil {
+		options["top_p"] = *p.options.TopP
+	}
+	if p.options.Stop != nil {
+		options["stop"] = p.options.Stop
+	}
+	if len(options) == 0 {
+		return nil
 	}
+	return options
+}
 
-	return &OllamaMessage{Message: response}, nil
+// SetGenerationOptions sets the options used for all following requests
+func (p *Provider) SetGenerationOptions(options llm.GenerationOptions) {
+	p.options = options
 }
 
 func (p *Provider) SupportsTools() bool {
@@ -281,3 +439,13 @@ func getString(m map[string]interface{}, key string) string {
 	}
 	return ""
 }
+
+// providerError maps error responses of the Ollama API and failures to
+// reach it onto llm errors
+func providerError(err error) error {
+	var statusErr api.StatusError
+	if errors.As(err, &statusErr) {
+		return llm.NewProviderError(statusErr.StatusCode, "", statusErr.ErrorMessage)
+	}
+	return llm.NewConnectionError("error calling Ollama", err)
+}
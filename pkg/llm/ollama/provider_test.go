@@ -0,0 +1,161 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	api "github.com/ollama/ollama/api"
+	"github.com/vincent-pli/mcphost/pkg/history"
+	"github.com/vincent-pli/mcphost/pkg/llm"
+)
+
+// chunkProvider returns a provider for a server that answers every chat
+// request with the recorded chunks, one JSON object per line
+func chunkProvider(t *testing.T, chunks []string) (*Provider, func()) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		var req api.ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Stream == nil || !*req.Stream {
+			t.Errorf("request = %+v, %v, want a streaming request", req, err)
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, chunk := range chunks {
+			io.WriteString(w, chunk+"\n")
+		}
+	}))
+
+	base, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Provider{client: api.NewClient(base, server.Client()), model: "qwen2.5:3b"}, server.Close
+}
+
+func TestStreamMessage(t *testing.T) {
+	tests := []struct {
+		name      string
+		chunks    []string
+		wantText  string
+		wantCalls []string
+		wantErr   error
+	}{
+		{
+			name: "text",
+			chunks: []string{
+				`{"model": "qwen2.5:3b", "message": {"role": "assistant", "content": "Hello"}, "done": false}`,
+				`{"model": "qwen2.5:3b", "message": {"role": "assistant", "content": ", world."}, "done": false}`,
+				`{"model": "qwen2.5:3b", "message": {"role": "assistant", "content": ""}, "done": true, "done_reason": "stop"}`,
+			},
+			wantText: "Hello, world.",
+		},
+		{
+			name: "tool calls",
+			chunks: []string{
+				`{"model": "qwen2.5:3b", "message": {"role": "assistant", "content": "", "tool_calls": [{"function": {"name": "fs__read", "arguments": {"path": "a.txt"}}}]}, "done": false}`,
+				`{"model": "qwen2.5:3b", "message": {"role": "assistant", "content": "", "tool_calls": [{"function": {"name": "fs__list", "arguments": {}}}]}, "done": false}`,
+				`{"model": "qwen2.5:3b", "message": {"role": "assistant", "content": ""}, "done": true, "done_reason": "stop"}`,
+			},
+			wantCalls: []string{`fs__read {"path":"a.txt"}`, `fs__list {}`},
+		},
+		{
+			name: "no done chunk",
+			chunks: []string{
+				`{"model": "qwen2.5:3b", "message": {"role": "assistant", "content": "Half an"}, "done": false}`,
+			},
+			wantErr: llm.ErrTransient,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, closeServer := chunkProvider(t, tt.chunks)
+			defer closeServer()
+
+			messages := []llm.Message{&history.HistoryMessage{
+				Role:    "user",
+				Content: []history.ContentBlock{{Type: "text", Text: "Hi"}},
+			}}
+			events, err := provider.StreamMessage(context.Background(), "", messages, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var text string
+			var streamedCalls []string
+			var done llm.Message
+			var streamErr error
+			for event := range events {
+				switch event.Type {
+				case llm.StreamEventText:
+					text += event.Text
+				case llm.StreamEventToolCall:
+					streamedCalls = append(streamedCalls, event.ToolName+" "+event.ToolArguments)
+				case llm.StreamEventError:
+					streamErr = event.Err
+				case llm.StreamEventDone:
+					done = event.Message
+				}
+			}
+
+			if tt.wantErr != nil {
+				var providerErr *llm.ProviderError
+				if !errors.As(streamErr, &providerErr) || !errors.Is(streamErr, tt.wantErr) || !providerErr.Retryable() {
+					t.Errorf("error = %v, want a retryable %v", streamErr, tt.wantErr)
+				}
+				if done != nil {
+					t.Error("stream gave a message despite the error")
+				}
+				return
+			}
+			if streamErr != nil {
+				t.Fatal(streamErr)
+			}
+
+			if text != tt.wantText || done.GetContent() != tt.wantText {
+				t.Errorf("streamed text = %q, content = %q, want %q", text, done.GetContent(), tt.wantText)
+			}
+			var calls []string
+			for _, call := range done.GetToolCalls() {
+				args, _ := json.Marshal(call.GetArguments())
+				calls = append(calls, call.GetName()+" "+string(args))
+			}
+			if strings.Join(calls, "|") != strings.Join(tt.wantCalls, "|") ||
+				strings.Join(streamedCalls, "|") != strings.Join(tt.wantCalls, "|") {
+				t.Errorf("tool calls = %q, streamed %q, want %q", calls, streamedCalls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestStreamMessageUnreachable(t *testing.T) {
+	// A server that is not running is a transient error
+	server := httptest.NewServer(http.NotFoundHandler())
+	base, _ := url.Parse(server.URL)
+	server.Close()
+
+	provider := &Provider{client: api.NewClient(base, http.DefaultClient), model: "qwen2.5:3b"}
+	events, err := provider.StreamMessage(context.Background(), "Hi", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var streamErr error
+	for event := range events {
+		if event.Type == llm.StreamEventError {
+			streamErr = event.Err
+		}
+	}
+	if !errors.Is(streamErr, llm.ErrTransient) {
+		t.Errorf("error = %v, want %v", streamErr, llm.ErrTransient)
+	}
+}
@@ -0,0 +1,256 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vincent-pli/mcphost/pkg/history"
+	"github.com/vincent-pli/mcphost/pkg/llm"
+)
+
+// captureServer answers chat completions and records the request bodies
+func captureServer(t *testing.T) (*httptest.Server, *[]map[string]interface{}) {
+	t.Helper()
+	var bodies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("error decoding request: %v", err)
+		}
+		bodies = append(bodies, body)
+		io.WriteString(w, `{"id": "1", "choices": [{"index": 0, "message": {"role": "assistant", "content": "ok"}, "finish_reason": "stop"}]}`)
+	}))
+	return server, &bodies
+}
+
+func TestProfileParametersAndGenerationOptions(t *testing.T) {
+	server, bodies := captureServer(t)
+	defer server.Close()
+
+	provider := NewProviderWithOptions("", server.URL, "llama-3.1-8b", Options{
+		Parameters: map[string]interface{}{
+			"temperature": 0.3,
+			"max_tokens":  256,
+			"top_k":       20,
+		},
+	})
+	messages := []llm.Message{&history.HistoryMessage{
+		Role:    "user",
+		Content: []history.ContentBlock{{Type: "text", Text: "Hi"}},
+	}}
+
+	// Without generation options the profile's values are used
+	if _, err := provider.CreateMessage(context.Background(), "", messages, nil); err != nil {
+		t.Fatal(err)
+	}
+	body := (*bodies)[0]
+	if body["temperature"] != 0.3 || body["max_tokens"] != float64(256) || body["top_k"] != float64(20) {
+		t.Errorf("body = %v, want the profile's parameters", body)
+	}
+
+	// Generation options take precedence over the profile
+	temperature, maxTokens := 0.0, 1000
+	provider.SetGenerationOptions(llm.GenerationOptions{Temperature: &temperature, MaxTokens: &maxTokens})
+	if _, err := provider.CreateMessage(context.Background(), "", messages, nil); err != nil {
+		t.Fatal(err)
+	}
+	body = (*bodies)[1]
+	if body["temperature"] != 0.0 || body["max_tokens"] != float64(1000) || body["top_k"] != float64(20) {
+		t.Errorf("body = %v, want the generation options", body)
+	}
+	if body["model"] != "llama-3.1-8b" {
+		t.Errorf("model = %v", body["model"])
+	}
+}
+
+func TestUnsetTemperatureIsOmitted(t *testing.T) {
+	server, bodies := captureServer(t)
+	defer server.Close()
+
+	provider := NewProvider("", server.URL, "gpt-4o")
+	messages := []llm.Message{&history.HistoryMessage{
+		Role:    "user",
+		Content: []history.ContentBlock{{Type: "text", Text: "Hi"}},
+	}}
+	if _, err := provider.CreateMessage(context.Background(), "", messages, nil); err != nil {
+		t.Fatal(err)
+	}
+	body := (*bodies)[0]
+	if _, ok := body["temperature"]; ok {
+		t.Errorf("body = %v, want no temperature", body)
+	}
+	if body["max_tokens"] != float64(llm.DefaultMaxTokens) {
+		t.Errorf("max_tokens = %v, want the default", body["max_tokens"])
+	}
+}
+
+func TestNumericErrorCode(t *testing.T) {
+	// vLLM and other OpenAI compatible servers send the HTTP status as code
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `{"error": {"message": "This model's maximum context length is 8192 tokens", "type": "BadRequestError", "code": 400}}`)
+	}))
+	defer server.Close()
+
+	provider := NewProvider("", server.URL, "llama-3.1-8b")
+	messages := []llm.Message{&history.HistoryMessage{
+		Role:    "user",
+		Content: []history.ContentBlock{{Type: "text", Text: "Hi"}},
+	}}
+	_, err := provider.CreateMessage(context.Background(), "", messages, nil)
+
+	var providerErr *llm.ProviderError
+	if !errors.As(err, &providerErr) {
+		t.Fatalf("error = %v, want a *llm.ProviderError", err)
+	}
+	if providerErr.Type != "BadRequestError" || !strings.Contains(providerErr.Message, "maximum context length") {
+		t.Errorf("error = %+v, want the type and message of the response", providerErr)
+	}
+	if !errors.Is(err, llm.ErrContextTooLong) {
+		t.Errorf("error %v is not %v", err, llm.ErrContextTooLong)
+	}
+}
+
+// chunkServer answers every request with the recorded stream chunks, given
+// as the data of their server-sent events
+func chunkServer(t *testing.T, chunks []string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req CreateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !req.Stream {
+			t.Errorf("request = %+v, %v, want a streaming request", req, err)
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range chunks {
+			io.WriteString(w, "data: "+chunk+"\n\n")
+		}
+	}))
+}
+
+func TestStreamMessage(t *testing.T) {
+	tests := []struct {
+		name       string
+		chunks     []string
+		wantText   string
+		wantCalls  []string
+		wantFinish string
+		wantUsage  [2]int
+		wantErr    error
+	}{
+		{
+			name: "text",
+			chunks: []string{
+				`{"id": "1", "choices": [{"index": 0, "delta": {"role": "assistant", "content": ""}}]}`,
+				`{"id": "1", "choices": [{"index": 0, "delta": {"content": "Hello"}}]}`,
+				`{"id": "1", "choices": [{"index": 0, "delta": {"content": ", world."}}]}`,
+				`{"id": "1", "choices": [{"index": 0, "delta": {}, "finish_reason": "stop"}]}`,
+				`{"id": "1", "choices": [], "usage": {"prompt_tokens": 12, "completion_tokens": 5, "total_tokens": 17}}`,
+				`[DONE]`,
+			},
+			wantText:   "Hello, world.",
+			wantFinish: "stop",
+			wantUsage:  [2]int{12, 5},
+		},
+		{
+			name: "tool calls split across deltas",
+			chunks: []string{
+				`{"id": "2", "choices": [{"index": 0, "delta": {"role": "assistant", "tool_calls": [{"index": 0, "id": "call_1", "type": "function", "function": {"name": "fs__read", "arguments": ""}}]}}]}`,
+				`{"id": "2", "choices": [{"index": 0, "delta": {"tool_calls": [{"index": 0, "function": {"arguments": "{\"path\": "}}]}}]}`,
+				`{"id": "2", "choices": [{"index": 0, "delta": {"tool_calls": [{"index": 0, "function": {"arguments": "\"a.txt\"}"}}]}}]}`,
+				`{"id": "2", "choices": [{"index": 0, "delta": {"tool_calls": [{"index": 1, "id": "call_2", "type": "function", "function": {"name": "fs__list", "arguments": "{}"}}]}}]}`,
+				`{"id": "2", "choices": [{"index": 0, "delta": {}, "finish_reason": "tool_calls"}]}`,
+				`[DONE]`,
+			},
+			wantCalls:  []string{`call_1 fs__read {"path":"a.txt"}`, `call_2 fs__list {}`},
+			wantFinish: "tool_calls",
+		},
+		{
+			name: "no [DONE]",
+			chunks: []string{
+				`{"id": "3", "choices": [{"index": 0, "delta": {"role": "assistant", "content": "Half an"}}]}`,
+			},
+			wantErr: llm.ErrTransient,
+		},
+		{
+			name: "error chunk",
+			chunks: []string{
+				`{"id": "4", "choices": [{"index": 0, "delta": {"content": "Hi"}}]}`,
+				`{"error": {"message": "Rate limit reached", "type": "requests", "code": "429"}}`,
+			},
+			wantErr: llm.ErrRateLimited,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := chunkServer(t, tt.chunks)
+			defer server.Close()
+
+			provider := NewProvider("test-key", server.URL, "gpt-4o")
+			messages := []llm.Message{&history.HistoryMessage{
+				Role:    "user",
+				Content: []history.ContentBlock{{Type: "text", Text: "Hi"}},
+			}}
+			events, err := provider.StreamMessage(context.Background(), "", messages, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var text string
+			var done llm.Message
+			var streamErr error
+			for event := range events {
+				switch event.Type {
+				case llm.StreamEventText:
+					text += event.Text
+				case llm.StreamEventError:
+					streamErr = event.Err
+				case llm.StreamEventDone:
+					done = event.Message
+				}
+			}
+
+			if tt.wantErr != nil {
+				var providerErr *llm.ProviderError
+				if !errors.As(streamErr, &providerErr) || !errors.Is(streamErr, tt.wantErr) || !providerErr.Retryable() {
+					t.Errorf("error = %v, want a retryable %v", streamErr, tt.wantErr)
+				}
+				if done != nil {
+					t.Error("stream gave a message despite the error")
+				}
+				return
+			}
+			if streamErr != nil {
+				t.Fatal(streamErr)
+			}
+
+			if text != tt.wantText || done.GetContent() != tt.wantText {
+				t.Errorf("streamed text = %q, content = %q, want %q", text, done.GetContent(), tt.wantText)
+			}
+			var calls []string
+			for _, call := range done.GetToolCalls() {
+				args, _ := json.Marshal(call.GetArguments())
+				calls = append(calls, call.GetID()+" "+call.GetName()+" "+string(args))
+			}
+			if strings.Join(calls, "|") != strings.Join(tt.wantCalls, "|") {
+				t.Errorf("tool calls = %q, want %q", calls, tt.wantCalls)
+			}
+			if finish := done.(*Message).Choice.FinishReason; finish != tt.wantFinish {
+				t.Errorf("finish reason = %q, want %q", finish, tt.wantFinish)
+			}
+			if input, output := done.GetUsage(); input != tt.wantUsage[0] || output != tt.wantUsage[1] {
+				t.Errorf("usage = %d, %d, want %v", input, output, tt.wantUsage)
+			}
+		})
+	}
+}
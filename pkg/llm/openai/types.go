@@ -1,11 +1,50 @@
 package openai
 
+import (
+	"encoding/json"
+
+	"github.com/vincent-pli/mcphost/pkg/llm"
+)
+
 type CreateRequest struct {
-	Model       string         `json:"model"`
-	Messages    []MessageParam `json:"messages"`
-	Tools       []Tool         `json:"tools,omitempty"`
-	MaxTokens   int            `json:"max_tokens,omitempty"`
-	Temperature float32        `json:"temperature,omitempty"`
+	Model         string         `json:"model"`
+	Messages      []MessageParam `json:"messages"`
+	Tools         []Tool         `json:"tools,omitempty"`
+	MaxTokens     int            `json:"max_tokens,omitempty"`
+	Temperature   *float64       `json:"temperature,omitempty"`
+	Stream        bool           `json:"stream,omitempty"`
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+	TopP          *float64       `json:"top_p,omitempty"`
+	Stop          []string       `json:"stop,omitempty"`
+
+	// Parameters are extra body fields. The fields above that are set take
+	// precedence over them.
+	Parameters map[string]interface{} `json:"-"`
+}
+
+// ReservedParameters are the body fields the provider sets itself, which
+// Parameters must not contain
+var ReservedParameters = []string{"model", "messages", "tools", "stream", "stream_options"}
+
+func (r CreateRequest) MarshalJSON() ([]byte, error) {
+	type request CreateRequest
+	data, err := json.Marshal(request(r))
+	if err != nil || len(r.Parameters) == 0 {
+		return data, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	body := make(map[string]interface{}, len(r.Parameters)+len(fields))
+	for key, value := range r.Parameters {
+		body[key] = value
+	}
+	for key, value := range fields {
+		body[key] = value
+	}
+	return json.Marshal(body)
 }
 
 type MessageParam struct {
@@ -15,6 +54,42 @@ type MessageParam struct {
 	ToolCalls    []ToolCall    `json:"tool_calls,omitempty"`
 	Name         string        `json:"name,omitempty"`
 	ToolCallID   string        `json:"tool_call_id,omitempty"`
+
+	// Parts replace Content in messages with images
+	Parts []ContentPart `json:"-"`
+}
+
+func (m MessageParam) MarshalJSON() ([]byte, error) {
+	type message MessageParam
+	if len(m.Parts) == 0 {
+		return json.Marshal(message(m))
+	}
+	return json.Marshal(struct {
+		message
+		Content []ContentPart `json:"content"`
+	}{
+		message: message(m),
+		Content: m.Parts,
+	})
+}
+
+// ContentPart is a part of a message with text and images
+type ContentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+	File     *FilePart `json:"file,omitempty"`
+}
+
+// FilePart holds a file, e.g. a PDF, as data URL
+type FilePart struct {
+	Filename string `json:"filename"`
+	FileData string `json:"file_data"`
+}
+
+// ImageURL holds an image as URL, or as data URL with the image inlined
+type ImageURL struct {
+	URL string `json:"url"`
 }
 
 type ToolCall struct {
@@ -59,3 +134,53 @@ type Usage struct {
 	CompletionTokens int `json:"completion_tokens"`
 	TotalTokens      int `json:"total_tokens"`
 }
+
+type ErrorDetail struct {
+	Message string        `json:"message"`
+	Type    string        `json:"type"`
+	Code    llm.ErrorCode `json:"code"`
+}
+
+// errorType returns the most specific name of the error. The code, e.g.
+// context_length_exceeded, is more specific than the type, unless it is a
+// number like the HTTP status some servers send.
+func (e ErrorDetail) errorType() string {
+	if e.Code != "" && (e.Type == "" || !e.Code.IsNumber()) {
+		return string(e.Code)
+	}
+	return e.Type
+}
+
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// StreamChunk is a single chunk of a streaming chat completion
+type StreamChunk struct {
+	ID      string         `json:"id"`
+	Object  string         `json:"object"`
+	Created int64          `json:"created"`
+	Model   string         `json:"model"`
+	Usage   *Usage         `json:"usage,omitempty"`
+	Choices []StreamChoice `json:"choices"`
+	Error   *ErrorDetail   `json:"error,omitempty"`
+}
+
+type StreamChoice struct {
+	Index        int         `json:"index"`
+	Delta        StreamDelta `json:"delta"`
+	FinishReason *string     `json:"finish_reason"`
+}
+
+type StreamDelta struct {
+	Role      string          `json:"role,omitempty"`
+	Content   *string         `json:"content,omitempty"`
+	ToolCalls []ToolCallDelta `json:"tool_calls,omitempty"`
+}
+
+type ToolCallDelta struct {
+	Index    int          `json:"index"`
+	ID       string       `json:"id,omitempty"`
+	Type     string       `json:"type,omitempty"`
+	Function FunctionCall `json:"function"`
+}
@@ -0,0 +1,59 @@
+package openai
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCreateRequestMarshalParameters(t *testing.T) {
+	temperature := 0.2
+	req := CreateRequest{
+		Model:       "llama-3.1-8b",
+		Messages:    []MessageParam{},
+		MaxTokens:   1024,
+		Temperature: &temperature,
+		Parameters: map[string]interface{}{
+			"temperature": 0.9,
+			"top_p":       0.8,
+			"top_k":       40,
+			"max_tokens":  512,
+		},
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{
+		// Set fields take precedence over the parameters
+		"temperature": 0.2,
+		"max_tokens":  float64(1024),
+		// Parameters fill in fields that are not set
+		"top_p": 0.8,
+		"top_k": float64(40),
+		"model": "llama-3.1-8b",
+	}
+	for key, value := range want {
+		if body[key] != value {
+			t.Errorf("%s = %v, want %v", key, body[key], value)
+		}
+	}
+	if _, ok := body["messages"]; !ok {
+		t.Error("messages are missing")
+	}
+}
+
+func TestCreateRequestMarshalWithoutParameters(t *testing.T) {
+	data, err := json.Marshal(CreateRequest{Model: "gpt-4o", Messages: []MessageParam{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"model":"gpt-4o","messages":[]}` {
+		t.Errorf("body = %s", data)
+	}
+}
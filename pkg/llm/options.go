@@ -0,0 +1,55 @@
+package llm
+
+// DefaultMaxTokens is the response length used when MaxTokens is not set
+const DefaultMaxTokens = 4096
+
+// GenerationOptions control how a model generates a response. Unset fields
+// use the provider's defaults.
+type GenerationOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	MaxTokens   *int     `json:"maxTokens,omitempty"`
+	TopP        *float64 `json:"topP,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+
+	// ThinkingBudget enables extended thinking with up to this many tokens
+	// of reasoning on models that support it
+	ThinkingBudget *int `json:"thinkingBudget,omitempty"`
+}
+
+// Merge returns the options with the fields set in other replacing their
+// own
+func (o GenerationOptions) Merge(other GenerationOptions) GenerationOptions {
+	if other.Temperature != nil {
+		o.Temperature = other.Temperature
+	}
+	if other.MaxTokens != nil {
+		o.MaxTokens = other.MaxTokens
+	}
+	if other.TopP != nil {
+		o.TopP = other.TopP
+	}
+	if other.Stop != nil {
+		o.Stop = other.Stop
+	}
+	if other.ThinkingBudget != nil {
+		o.ThinkingBudget = other.ThinkingBudget
+	}
+	return o
+}
+
+// MaxTokensOrDefault returns MaxTokens, or DefaultMaxTokens if it is unset
+func (o GenerationOptions) MaxTokensOrDefault() int {
+	if o.MaxTokens != nil {
+		return *o.MaxTokens
+	}
+	return DefaultMaxTokens
+}
+
+// ConfigurableProvider is implemented by providers that accept generation
+// options
+type ConfigurableProvider interface {
+	Provider
+
+	// SetGenerationOptions sets the options used for all following requests
+	SetGenerationOptions(options GenerationOptions)
+}
@@ -23,6 +23,31 @@ type Message interface {
 	GetUsage() (input int, output int)
 }
 
+// ThinkingBlock is a block of the model's reasoning before its answer
+type ThinkingBlock struct {
+	// Thinking is the reasoning text, Signature verifies it when the block
+	// is sent back to the provider
+	Thinking  string
+	Signature string
+
+	// RedactedData holds the encrypted reasoning of a redacted block
+	RedactedData string
+}
+
+// ThinkingMessage is implemented by messages that can carry the model's
+// reasoning. The blocks must be sent back unchanged with the message.
+type ThinkingMessage interface {
+	GetThinking() []ThinkingBlock
+}
+
+// CacheUsageMessage is implemented by messages of providers with prompt
+// caching. The input tokens of GetUsage do not include these tokens.
+type CacheUsageMessage interface {
+	// GetCacheUsage returns the input tokens written to and read from the
+	// cache
+	GetCacheUsage() (creation int, read int)
+}
+
 // ToolCall represents a tool invocation
 type ToolCall interface {
 	// GetName returns the tool's name
@@ -35,6 +60,16 @@ type ToolCall interface {
 	GetID() string
 }
 
+// SignedToolCall is implemented by tool calls that carry a signature of
+// the model's reasoning, e.g. the thought signature of Gemini. It must be
+// sent back with the call.
+type SignedToolCall interface {
+	ToolCall
+
+	// GetSignature returns the signature, empty if the call has none
+	GetSignature() string
+}
+
 // Tool represents a tool definition
 type Tool struct {
 	Name        string `json:"name"`
@@ -47,6 +82,9 @@ type Schema struct {
 	Type       string                 `json:"type"`
 	Properties map[string]interface{} `json:"properties"`
 	Required   []string               `json:"required"`
+
+	// Defs holds the definitions $ref in the properties can point to
+	Defs map[string]interface{} `json:"$defs,omitempty"`
 }
 
 // Provider defines the interface for LLM providers
@@ -63,3 +101,53 @@ type Provider interface {
 	// Name returns the provider's name
 	Name() string
 }
+
+// StreamEventType identifies the kind of a StreamEvent
+type StreamEventType string
+
+const (
+	// StreamEventText carries a chunk of assistant text
+	StreamEventText StreamEventType = "text"
+
+	// StreamEventThinking carries a chunk of the model's reasoning
+	StreamEventThinking StreamEventType = "thinking"
+
+	// StreamEventToolCall carries a partial tool call. The first event for a
+	// call has the ID and name set; later events carry argument fragments.
+	StreamEventToolCall StreamEventType = "tool_call"
+
+	// StreamEventDone carries the fully assembled response message
+	StreamEventDone StreamEventType = "done"
+
+	// StreamEventError carries the error that ended the stream
+	StreamEventError StreamEventType = "error"
+)
+
+// StreamEvent is a single event emitted while a response is streamed
+type StreamEvent struct {
+	Type StreamEventType
+
+	// Text is the text delta of a StreamEventText or StreamEventThinking
+	Text string
+
+	// ToolCallID, ToolName and ToolArguments describe a StreamEventToolCall.
+	// ToolArguments is the raw JSON fragment received with this event.
+	ToolCallID    string
+	ToolName      string
+	ToolArguments string
+
+	// Message is the assembled response of a StreamEventDone
+	Message Message
+
+	// Err is the error of a StreamEventError
+	Err error
+}
+
+// StreamingProvider is implemented by providers that can stream responses
+type StreamingProvider interface {
+	Provider
+
+	// StreamMessage sends a message to the LLM and streams the response back.
+	// The channel is closed after a StreamEventDone or StreamEventError event.
+	StreamMessage(ctx context.Context, prompt string, messages []Message, tools []Tool) (<-chan StreamEvent, error)
+}
@@ -0,0 +1,82 @@
+package llm
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultContextWindow is assumed for models with an unknown context window
+const DefaultContextWindow = 8192
+
+// contextWindows maps model name prefixes to context window sizes in tokens.
+// More specific prefixes must come before the prefixes they extend.
+var contextWindows = []struct {
+	prefix string
+	tokens int
+}{
+	{"claude-", 200000},
+	{"gpt-5", 400000},
+	{"gpt-4.1", 1047576},
+	{"gpt-4o", 128000},
+	{"gpt-4-turbo", 128000},
+	{"gpt-4-32k", 32768},
+	{"gpt-4", 8192},
+	{"gpt-3.5-turbo", 16385},
+	{"o1", 200000},
+	{"o3", 200000},
+	{"o4", 200000},
+	{"gemini-1.5-pro", 2097152},
+	{"gemini-", 1048576},
+	{"deepseek", 64000},
+	{"llama3.1", 131072},
+	{"llama3.2", 131072},
+	{"llama3.3", 131072},
+	{"qwen2.5", 32768},
+	{"qwen3", 40960},
+	{"mistral", 32768},
+}
+
+// ContextWindow returns the context window of a model in tokens
+func ContextWindow(model string) int {
+	model = strings.ToLower(model)
+
+	// Bedrock model IDs are prefixed with the vendor and, for inference
+	// profiles, the region, e.g. us.anthropic.claude-3-5-sonnet-...
+	if _, name, ok := strings.Cut(model, "anthropic."); ok {
+		model = name
+	}
+	for _, w := range contextWindows {
+		if strings.HasPrefix(model, w.prefix) {
+			return w.tokens
+		}
+	}
+	return DefaultContextWindow
+}
+
+// EstimateTokens approximates the number of tokens in text. ASCII text
+// averages about four characters per token, other scripts closer to one
+// token per character.
+func EstimateTokens(text string) int {
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+// EstimateToolTokens approximates the tokens used by tool definitions
+func EstimateToolTokens(tools []Tool) int {
+	if len(tools) == 0 {
+		return 0
+	}
+	data, err := json.Marshal(tools)
+	if err != nil {
+		return 0
+	}
+	return EstimateTokens(string(data))
+}
@@ -0,0 +1,52 @@
+package llm
+
+import "strings"
+
+// visionModels maps model name prefixes to whether the models accept
+// images. More specific prefixes must come before the prefixes they
+// extend. Unknown models are assumed to take text only.
+var visionModels = []struct {
+	prefix string
+	vision bool
+}{
+	{"claude-2", false},
+	{"claude-instant", false},
+	{"claude-", true},
+	{"gpt-5", true},
+	{"gpt-4.1", true},
+	{"gpt-4o", true},
+	{"gpt-4-turbo", true},
+	{"gpt-4-vision", true},
+	{"o1-mini", false},
+	{"o1", true},
+	{"o3-mini", false},
+	{"o3", true},
+	{"o4", true},
+	{"gemini-", true},
+	{"llava", true},
+	{"bakllava", true},
+	{"llama3.2-vision", true},
+	{"llama4", true},
+	{"gemma3", true},
+	{"qwen2.5vl", true},
+	{"qwen2.5-vl", true},
+	{"minicpm-v", true},
+	{"moondream", true},
+	{"granite3.2-vision", true},
+	{"mistral-small3.1", true},
+	{"pixtral", true},
+}
+
+// SupportsVision reports whether a model accepts images
+func SupportsVision(model string) bool {
+	model = strings.ToLower(model)
+	if _, name, ok := strings.Cut(model, "anthropic."); ok {
+		model = name
+	}
+	for _, m := range visionModels {
+		if strings.HasPrefix(model, m.prefix) {
+			return m.vision
+		}
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vincent-pli/mcphost/pkg/history"
+)
+
+// Session is a saved conversation together with the setup it was held with
+type Session struct {
+	Name      string                   `json:"name"`
+	Model     string                   `json:"model"`
+	Servers   []string                 `json:"servers,omitempty"`
+	CreatedAt time.Time                `json:"created_at"`
+	UpdatedAt time.Time                `json:"updated_at"`
+	Messages  []history.HistoryMessage `json:"messages"`
+}
+
+// Summary describes a stored session without its messages
+type Summary struct {
+	Name      string
+	Model     string
+	Messages  int
+	UpdatedAt time.Time
+}
+
+// Store keeps sessions as JSON files in a directory
+type Store struct {
+	dir string
+}
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// DefaultDir returns the default sessions directory, ~/.mcphost/sessions
+func DefaultDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error getting home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".mcphost", "sessions"), nil
+}
+
+// NewStore creates a store in dir, or in DefaultDir if dir is empty
+func NewStore(dir string) (*Store, error) {
+	if dir == "" {
+		var err error
+		if dir, err = DefaultDir(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating sessions directory: %w", err)
+	}
+
+	return &Store{dir: dir}, nil
+}
+
+// NewName returns a name for a new session based on the current time
+func NewName() string {
+	return "session-" + time.Now().Format("20060102-150405")
+}
+
+// Save writes the session, replacing any session with the same name
+func (s *Store) Save(session *Session) error {
+	path, err := s.path(session.Name)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = now
+	}
+	session.UpdatedAt = now
+
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding session: %w", err)
+	}
+
+	// Write to a temporary file first so a failed write never corrupts
+	// an existing session
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("error writing session file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("error writing session file: %w", err)
+	}
+
+	return nil
+}
+
+// Load reads the session with the given name
+func (s *Store) Load(name string) (*Session, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("session not found: %s", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading session file: %w", err)
+	}
+
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("error parsing session %s: %w", name, err)
+	}
+	session.Name = name
+
+	return &session, nil
+}
+
+// Latest reads the most recently updated session
+func (s *Store) Latest() (*Session, error) {
+	summaries, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(summaries) == 0 {
+		return nil, fmt.Errorf("no saved sessions in %s", s.dir)
+	}
+	return s.Load(summaries[0].Name)
+}
+
+// List returns all stored sessions, most recently updated first
+func (s *Store) List() ([]Summary, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading sessions directory: %w", err)
+	}
+
+	var summaries []Summary
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		session, err := s.Load(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			// Skip files that are not sessions
+			continue
+		}
+
+		summaries = append(summaries, Summary{
+			Name:      session.Name,
+			Model:     session.Model,
+			Messages:  len(session.Messages),
+			UpdatedAt: session.UpdatedAt,
+		})
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].UpdatedAt.After(summaries[j].UpdatedAt)
+	})
+
+	return summaries, nil
+}
+
+func (s *Store) path(name string) (string, error) {
+	if !validName.MatchString(name) {
+		return "", fmt.Errorf(
+			"invalid session name %q: use letters, digits, '.', '_' and '-'",
+			name,
+		)
+	}
+	return filepath.Join(s.dir, name+".json"), nil
+}
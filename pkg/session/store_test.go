@@ -0,0 +1,164 @@
+package session
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/vincent-pli/mcphost/pkg/history"
+)
+
+func testMessages() []history.HistoryMessage {
+	return []history.HistoryMessage{
+		{
+			Role: "user",
+			Content: []history.ContentBlock{
+				{Type: "text", Text: "What is in data.csv?"},
+				{Type: "image", MediaType: "image/png", Name: "chart.png", Data: "iVBORw0KGgo="},
+				{Type: "document", MediaType: "text/plain", Name: "notes.txt", Text: "notes"},
+			},
+		},
+		{
+			Role: "assistant",
+			Content: []history.ContentBlock{
+				{Type: "thinking", Thinking: "I should read the file.", Signature: "sig=="},
+				{Type: "tool_use", ID: "toolu_1", Name: "fs__read_file", Input: json.RawMessage(`{"path":"data.csv"}`)},
+			},
+			InputTokens:  50,
+			OutputTokens: 20,
+		},
+		{
+			Role: "user",
+			Content: []history.ContentBlock{{
+				Type:      "tool_result",
+				ToolUseID: "toolu_1",
+				Content: []mcp.Content{
+					mcp.NewTextContent("a,b"),
+					mcp.NewImageContent("iVBORw0KGgo=", "image/png"),
+					mcp.NewEmbeddedResource(mcp.TextResourceContents{URI: "file:///data.csv", MIMEType: "text/csv", Text: "a,b"}),
+				},
+			}},
+		},
+		{
+			Role:    "assistant",
+			Content: []history.ContentBlock{{Type: "text", Text: "It has two columns."}},
+		},
+	}
+}
+
+// compactInputs compacts the tool inputs, which are indented in the file
+func compactInputs(t *testing.T, messages []history.HistoryMessage) {
+	t.Helper()
+	for _, message := range messages {
+		for i, block := range message.Content {
+			if block.Input == nil {
+				continue
+			}
+			var buf bytes.Buffer
+			if err := json.Compact(&buf, block.Input); err != nil {
+				t.Fatal(err)
+			}
+			message.Content[i].Input = buf.Bytes()
+		}
+	}
+}
+
+func TestStoreRoundTrip(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved := &Session{
+		Name:     "review",
+		Model:    "anthropic:claude-3-5-sonnet-latest",
+		Servers:  []string{"filesystem"},
+		Messages: testMessages(),
+	}
+	if err := store.Save(saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.CreatedAt.IsZero() || saved.UpdatedAt.IsZero() {
+		t.Error("Save did not set the timestamps")
+	}
+
+	loaded, err := store.Load("review")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compactInputs(t, loaded.Messages)
+
+	if loaded.Name != saved.Name || loaded.Model != saved.Model || !reflect.DeepEqual(loaded.Servers, saved.Servers) {
+		t.Errorf("loaded %+v, want %+v", loaded, saved)
+	}
+	if !loaded.CreatedAt.Equal(saved.CreatedAt) || !loaded.UpdatedAt.Equal(saved.UpdatedAt) {
+		t.Errorf("timestamps = %v, %v, want %v, %v", loaded.CreatedAt, loaded.UpdatedAt, saved.CreatedAt, saved.UpdatedAt)
+	}
+	if len(loaded.Messages) != len(saved.Messages) {
+		t.Fatalf("loaded %d messages, want %d", len(loaded.Messages), len(saved.Messages))
+	}
+	for i := range saved.Messages {
+		if !reflect.DeepEqual(loaded.Messages[i], saved.Messages[i]) {
+			t.Errorf("message %d:\n got %#v\nwant %#v", i, loaded.Messages[i], saved.Messages[i])
+		}
+	}
+}
+
+func TestStoreListAndLatest(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"older", "newer"} {
+		if err := store.Save(&Session{Name: name, Model: "ollama:qwen2.5:3b", Messages: testMessages()[:1]}); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Files that are not sessions are skipped
+	if err := os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	summaries, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 2 || summaries[0].Name != "newer" || summaries[1].Name != "older" {
+		t.Fatalf("summaries = %+v", summaries)
+	}
+	if summaries[0].Messages != 1 || summaries[0].Model != "ollama:qwen2.5:3b" {
+		t.Errorf("summary = %+v", summaries[0])
+	}
+
+	latest, err := store.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Name != "newer" {
+		t.Errorf("latest = %s", latest.Name)
+	}
+}
+
+func TestStoreInvalidName(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"", "../escape", ".hidden", "a/b"} {
+		if _, err := store.Load(name); err == nil || !strings.Contains(err.Error(), "invalid session name") {
+			t.Errorf("Load(%q) error = %v, want invalid session name", name, err)
+		}
+	}
+	if _, err := store.Load("missing"); err == nil || !strings.Contains(err.Error(), "session not found") {
+		t.Errorf("error = %v, want session not found", err)
+	}
+}